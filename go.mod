@@ -3,11 +3,19 @@ module badger
 go 1.22.2
 
 require (
+	github.com/gorilla/securecookie v1.1.2
 	github.com/nbd-wtf/go-nostr v0.35.0
-	golang.org/x/net v0.28.0
+	github.com/skip2/go-qrcode v0.0.0-20200617195104-da1b6568686e
+	go.etcd.io/bbolt v1.3.11
+	golang.org/x/sys v0.23.0
 )
 
-require github.com/gorilla/securecookie v1.1.2 // indirect
+require (
+	github.com/btcsuite/btcd/btcutil v1.1.3 // indirect
+	golang.org/x/crypto v0.26.0 // indirect
+	golang.org/x/net v0.28.0 // indirect
+	golang.org/x/text v0.17.0 // indirect
+)
 
 require (
 	github.com/btcsuite/btcd/btcec/v2 v2.3.2 // indirect
@@ -26,5 +34,4 @@ require (
 	github.com/tidwall/match v1.1.1 // indirect
 	github.com/tidwall/pretty v1.2.0 // indirect
 	golang.org/x/exp v0.0.0-20230425010034-47ecfdc1ba53 // indirect
-	golang.org/x/sys v0.23.0 // indirect
 )
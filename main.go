@@ -1,32 +1,80 @@
 package main
 
 import (
+	"badger/src/api"
+	"badger/src/cli"
 	"badger/src/components"
 	"badger/src/handlers"
+	"badger/src/relay"
 	"badger/src/routes"
+	"badger/src/store"
 	"badger/src/utils"
 	"embed"
 
+	"errors"
+	"flag"
 	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
 )
 
 //go:embed web/*
 var staticFiles embed.FS
 
 func main() {
+	// Without a command (or with only flags) badger runs the web server, as before the subcommands
+	command, args := "serve", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	var err error
+	if command == "serve" {
+		err = serve(args)
+	} else {
+		err = cli.Run(command, args)
+	}
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "badger %s: %v\n", command, err)
+		os.Exit(1)
+	}
+}
+
+// serve runs the web server until it stops
+func serve(args []string) error {
 	// Load Configurations
-	cfg, err := utils.LoadConfig()
+	cfg, err := utils.NewConfigFlags("badger serve").Load(args)
 	if err != nil {
-		fmt.Printf("Failed to load config: %v\n", err)
-		return
+		return fmt.Errorf("failed to load config: %w", err)
+	}
+
+	// Open the local event store so badges load from disk between relay syncs
+	eventStore, err := store.Open(filepath.Join(cfg.DataDir, "events.db"))
+	if err != nil {
+		return fmt.Errorf("failed to open event store: %v", err)
+	}
+	defer eventStore.Close()
+	store.Default = eventStore
+
+	// Sessions live in the store, the cookie only carries the signed session id
+	if err := handlers.ConfigureSessions(cfg, eventStore); err != nil {
+		return fmt.Errorf("failed to configure sessions: %v", err)
 	}
 
 	mux := http.NewServeMux()
 	// Login / Logout
 	mux.HandleFunc("/login", routes.Login) // Login route
+	mux.HandleFunc("/login-challenge", handlers.LoginChallengeHandler)
 	mux.HandleFunc("/do-login", handlers.LoginHandler)
+	mux.HandleFunc("/bunker-login", handlers.BunkerLoginHandler)
+	mux.HandleFunc("/sign-event", handlers.SignEventHandler)
 	mux.HandleFunc("/logout", handlers.LogoutHandler) // Logout process
+	mux.HandleFunc("/logout-everywhere", handlers.LogoutEverywhereHandler)
 	mux.HandleFunc("/update-badge", handlers.UpdateBadgeHandler)
 
 	// Initialize Routes
@@ -34,6 +82,22 @@ func main() {
 	mux.HandleFunc("/badgeform", routes.BadgeForm)
 	mux.HandleFunc("/update", routes.UpdateBadgeForm)
 	mux.HandleFunc("/relay-list", routes.RelayList)
+	mux.HandleFunc("/award", routes.AwardBadgeForm)
+	mux.HandleFunc("/claims", routes.ClaimCampaigns)
+	mux.HandleFunc("/claims/print", routes.ClaimSheet)
+
+	// Public pages, no login required
+	mux.HandleFunc("/b/", routes.PublicBadge)
+	mux.HandleFunc("/p/", routes.PublicProfile)
+	mux.HandleFunc("/claim/", routes.ClaimBadge)
+	mux.HandleFunc("/claim-badge", handlers.ClaimBadgeHandler)
+	mux.HandleFunc("/qr/", handlers.QRCodeHandler)
+
+	// Admin pages, limited to admin_pubkeys
+	mux.HandleFunc("/admin/sessions", routes.AdminSessions)
+	mux.HandleFunc("/admin/revoke-session", handlers.RevokeSessionHandler)
+	mux.HandleFunc("/admin/issuers", routes.AdminIssuers)
+	mux.HandleFunc("/admin/issuer-action", handlers.AdminIssuersHandler)
 
 	// Render component htmls
 	mux.HandleFunc("/profile-badges", components.RenderProfileBadgeEvent)
@@ -44,6 +108,24 @@ func main() {
 	mux.HandleFunc("/create-badge", handlers.CreateBadgeHandler)
 	mux.HandleFunc("/delete-badge", handlers.DeleteBadgeHandler)
 	mux.HandleFunc("/delete-signed-badge", handlers.DeleteSignedBadgeHandler)
+	mux.HandleFunc("/award-badge", handlers.AwardBadgeHandler)
+	mux.HandleFunc("/award-signed-badges", handlers.AwardSignedBadgesHandler)
+	mux.HandleFunc("/profile-badges-event", handlers.ProfileBadgesHandler)
+	mux.HandleFunc("/profile-badges-signed", handlers.ProfileBadgesSignedHandler)
+	mux.HandleFunc("/create-claim-campaign", handlers.CreateClaimCampaignHandler)
+	mux.HandleFunc("/delete-claim-campaign", handlers.DeleteClaimCampaignHandler)
+
+	// JSON API for tools, documented at /api/v1/openapi.yaml
+	api.Register(mux)
+
+	// Uploaded badge images
+	mux.HandleFunc("/upload-image", handlers.UploadImageHandler)
+	mux.HandleFunc("/images/", handlers.ServeImage)
+
+	// Embedded relay hosting badge events from the local store
+	if cfg.RelayEnabled {
+		mux.Handle("/relay", relay.NewServer(eventStore))
+	}
 
 	// Serve Static Files
 	mux.Handle("/static/", http.StripPrefix("/static/", http.FileServer(http.Dir("web/static"))))
@@ -56,6 +138,12 @@ func main() {
 		fmt.Fprintf(w, `<button class="px-4 py-2 mt-4 text-xs font-semibold text-white bg-red-500 rounded-md hover:bg-red-700">I'm Working on it ⚠️</button>`)
 	})
 
-	fmt.Printf("Server is running on http://localhost:%d\n", cfg.Port)
-	http.ListenAndServe(fmt.Sprintf(":%d", cfg.Port), mux)
+	if cfg.UseTLS() {
+		fmt.Printf("Server is listening on %s (HTTPS)\n", cfg.Address())
+		err = http.ListenAndServeTLS(cfg.Address(), cfg.TLSCert, cfg.TLSKey, mux)
+	} else {
+		fmt.Printf("Server is listening on %s\n", cfg.Address())
+		err = http.ListenAndServe(cfg.Address(), mux)
+	}
+	return fmt.Errorf("server stopped: %v", err)
 }
@@ -0,0 +1,104 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"badger/src/utils"
+)
+
+// Page sizes of list resources
+const (
+	defaultLimit = 50
+	maxLimit     = 500
+)
+
+// errorBody is every API error, the same shape as utils.EventValidationError
+type errorBody struct {
+	Code    string `json:"error"`
+	Message string `json:"message"`
+}
+
+// listBody is a page of a list resource
+type listBody struct {
+	Data       any  `json:"data"`
+	Total      int  `json:"total"`
+	Limit      int  `json:"limit"`
+	Offset     int  `json:"offset"`
+	NextOffset *int `json:"next_offset"` // null on the last page
+}
+
+// Register mounts the JSON API under /api/v1
+func Register(mux *http.ServeMux) {
+	mux.HandleFunc("GET /api/v1/openapi.yaml", serveOpenAPI)
+	mux.HandleFunc("GET /api/v1/me", getMe)
+
+	mux.HandleFunc("GET /api/v1/users/{user}", getUser)
+	mux.HandleFunc("GET /api/v1/users/{user}/relays", getUserRelays)
+	mux.HandleFunc("GET /api/v1/users/{user}/definitions", listUserDefinitions)
+	mux.HandleFunc("GET /api/v1/users/{user}/awards", listUserAwards)
+	mux.HandleFunc("GET /api/v1/users/{user}/profile-badges", listProfileBadges)
+	mux.HandleFunc("PUT /api/v1/users/{user}/profile-badges", putProfileBadges)
+
+	mux.HandleFunc("POST /api/v1/definitions", createDefinition)
+	mux.HandleFunc("GET /api/v1/definitions/{naddr}", getDefinition)
+	mux.HandleFunc("DELETE /api/v1/definitions/{naddr}", deleteDefinition)
+	mux.HandleFunc("GET /api/v1/definitions/{naddr}/recipients", listRecipients)
+
+	mux.HandleFunc("POST /api/v1/awards", createAward)
+
+	// Anything else under the API answers in JSON rather than with the dashboard
+	mux.HandleFunc("/api/v1/", func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, http.StatusNotFound, "not_found", "no such API resource")
+	})
+}
+
+func serveOpenAPI(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/yaml")
+	http.ServeFile(w, r, "web/api/openapi.yaml")
+}
+
+func writeJSON(w http.ResponseWriter, status int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		utils.Warnf("Failed to write API response: %v", err)
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	writeJSON(w, status, errorBody{Code: code, Message: message})
+}
+
+// writePage answers with the page of items selected by the limit and offset query parameters
+func writePage[T any](w http.ResponseWriter, r *http.Request, items []T) {
+	limit, offset := defaultLimit, 0
+	query := r.URL.Query()
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 1 || parsed > maxLimit {
+			writeError(w, http.StatusBadRequest, "invalid_pagination", "limit must be between 1 and 500")
+			return
+		}
+		limit = parsed
+	}
+	if value := query.Get("offset"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < 0 {
+			writeError(w, http.StatusBadRequest, "invalid_pagination", "offset must be a positive number")
+			return
+		}
+		offset = parsed
+	}
+
+	page := listBody{Data: []T{}, Total: len(items), Limit: limit, Offset: offset}
+	if offset < len(items) {
+		end := min(offset+limit, len(items))
+		page.Data = items[offset:end]
+		if end < len(items) {
+			page.NextOffset = &end
+		}
+	}
+	writeJSON(w, http.StatusOK, page)
+}
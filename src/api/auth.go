@@ -0,0 +1,150 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"badger/src/handlers"
+	"badger/src/utils"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// httpAuthWindow is how far the timestamp of a NIP-98 auth event may be from now
+const httpAuthWindow = 60 * time.Second
+
+// errSessionNeedsJSON rejects session authenticated writes other sites could forge with a plain form
+var errSessionNeedsJSON = errors.New("writes authenticated with a session must send Content-Type: application/json")
+
+// NIP-98 events already used, each authorizes a single request within httpAuthWindow
+var usedAuthEvents = struct {
+	sync.Mutex
+	data map[string]time.Time
+}{
+	data: make(map[string]time.Time),
+}
+
+// requireAuth returns the public key the request is made by, or answers 401 and reports false
+func requireAuth(w http.ResponseWriter, r *http.Request) (string, bool) {
+	publicKey, err := authenticate(r)
+	if errors.Is(err, errSessionNeedsJSON) {
+		writeError(w, http.StatusUnsupportedMediaType, "unsupported_media_type", err.Error())
+		return "", false
+	}
+	if err != nil {
+		writeError(w, http.StatusUnauthorized, "not_authenticated", err.Error())
+		return "", false
+	}
+	return publicKey, true
+}
+
+// authenticate accepts a NIP-98 "Authorization: Nostr <base64 kind 27235 event>" header, so tools can
+// call the API without a browser, or else the logged in session. A NIP-98 event authorizes one request
+// and must hash the body of requests that have one in its payload tag.
+func authenticate(r *http.Request) (string, error) {
+	header := r.Header.Get("Authorization")
+	if header == "" {
+		session, _ := handlers.User.Get(r, "session-name")
+		if publicKey, ok := session.Values["publicKey"].(string); ok && publicKey != "" {
+			// The cookie rides along on requests from any site, a JSON body needs a CORS preflight
+			if r.Method != http.MethodGet && r.Method != http.MethodHead && !isJSON(r) {
+				return "", errSessionNeedsJSON
+			}
+			return publicKey, nil
+		}
+		return "", errors.New("log in or send a NIP-98 Authorization header")
+	}
+
+	encoded, found := strings.CutPrefix(header, "Nostr ")
+	if !found {
+		return "", errors.New(`authorization must use the "Nostr" scheme (NIP-98)`)
+	}
+	data, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", errors.New("authorization event is not valid base64")
+	}
+	var event nostr.Event
+	if err := json.Unmarshal(data, &event); err != nil {
+		return "", errors.New("authorization event is not valid JSON")
+	}
+
+	if event.Kind != 27235 {
+		return "", errors.New("authorization event must be kind 27235")
+	}
+	if time.Since(event.CreatedAt.Time()).Abs() > httpAuthWindow {
+		return "", errors.New("authorization event timestamp is out of range")
+	}
+	if tag := event.Tags.GetFirst([]string{"u", ""}); tag == nil || tag.Value() != utils.AppConfig.BaseURL(r)+r.URL.RequestURI() {
+		return "", errors.New("authorization event u tag does not match the request URL")
+	}
+	if tag := event.Tags.GetFirst([]string{"method", ""}); tag == nil || !strings.EqualFold(tag.Value(), r.Method) {
+		return "", errors.New("authorization event method tag does not match the request")
+	}
+	if tag := event.Tags.GetFirst([]string{"payload", ""}); tag != nil {
+		if err := checkPayload(r, tag.Value()); err != nil {
+			return "", err
+		}
+	} else if r.ContentLength != 0 {
+		return "", errors.New("authorization event needs a payload tag with the SHA-256 of the request body")
+	}
+	if event.GetID() != event.ID {
+		return "", errors.New("authorization event id does not match its content")
+	}
+	if ok, err := event.CheckSignature(); err != nil || !ok {
+		return "", errors.New("invalid authorization event signature")
+	}
+	if !useAuthEvent(event.ID) {
+		return "", errors.New("authorization event was already used")
+	}
+
+	return event.PubKey, nil
+}
+
+// useAuthEvent records a NIP-98 event id and reports false when it was already used. Ids are kept for
+// twice the window, after that the timestamp check rejects the event anyway.
+func useAuthEvent(id string) bool {
+	usedAuthEvents.Lock()
+	defer usedAuthEvents.Unlock()
+	for used, at := range usedAuthEvents.data {
+		if time.Since(at) > 2*httpAuthWindow {
+			delete(usedAuthEvents.data, used)
+		}
+	}
+	if _, used := usedAuthEvents.data[id]; used {
+		return false
+	}
+	usedAuthEvents.data[id] = time.Now()
+	return true
+}
+
+// isJSON reports whether the request body is declared as JSON
+func isJSON(r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	return err == nil && mediaType == "application/json"
+}
+
+// checkPayload compares the request body with the SHA-256 in a NIP-98 payload tag, leaving the body
+// readable for the handler
+func checkPayload(r *http.Request, expected string) error {
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxEventBody))
+	if err != nil {
+		return errors.New("failed to read request body")
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	sum := sha256.Sum256(body)
+	if hex.EncodeToString(sum[:]) != strings.ToLower(expected) {
+		return errors.New("authorization event payload tag does not match the request body")
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"badger/src/handlers"
+	"badger/src/store"
+	"badger/src/utils"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+const testURL = "http://badger.test/api/v1/awards"
+
+// authEvent builds a signed NIP-98 event for a POST of body to testURL, change edits it before signing
+func authEvent(t *testing.T, secretKey string, body []byte, change func(event *nostr.Event)) nostr.Event {
+	t.Helper()
+	sum := sha256.Sum256(body)
+	event := nostr.Event{
+		Kind:      27235,
+		CreatedAt: nostr.Now(),
+		Tags: nostr.Tags{
+			{"u", testURL},
+			{"method", "POST"},
+			{"payload", hex.EncodeToString(sum[:])},
+		},
+	}
+	if change != nil {
+		change(&event)
+	}
+	if err := event.Sign(secretKey); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func authRequest(event nostr.Event, body []byte) *http.Request {
+	r := httptest.NewRequest(http.MethodPost, testURL, bytes.NewReader(body))
+	data, _ := json.Marshal(event)
+	r.Header.Set("Authorization", "Nostr "+base64.StdEncoding.EncodeToString(data))
+	return r
+}
+
+func withoutTag(name string) func(event *nostr.Event) {
+	return func(event *nostr.Event) {
+		var tags nostr.Tags
+		for _, tag := range event.Tags {
+			if tag[0] != name {
+				tags = append(tags, tag)
+			}
+		}
+		event.Tags = tags
+	}
+}
+
+func withTag(name, value string) func(event *nostr.Event) {
+	return func(event *nostr.Event) {
+		withoutTag(name)(event)
+		event.Tags = append(event.Tags, nostr.Tag{name, value})
+	}
+}
+
+func TestAuthenticateNIP98(t *testing.T) {
+	secretKey := nostr.GeneratePrivateKey()
+	publicKey, _ := nostr.GetPublicKey(secretKey)
+	body := []byte(`{"kind":8}`)
+
+	tests := []struct {
+		name    string
+		request *http.Request
+		valid   bool
+	}{
+		{"valid", authRequest(authEvent(t, secretKey, body, nil), body), true},
+		{"uppercase payload", authRequest(authEvent(t, secretKey, body, func(e *nostr.Event) {
+			e.Tags[2][1] = strings.ToUpper(e.Tags[2][1])
+		}), body), true},
+		{"wrong u", authRequest(authEvent(t, secretKey, body, withTag("u", "http://badger.test/api/v1/definitions")), body), false},
+		{"other host", authRequest(authEvent(t, secretKey, body, withTag("u", "http://evil.test/api/v1/awards")), body), false},
+		{"no u", authRequest(authEvent(t, secretKey, body, withoutTag("u")), body), false},
+		{"wrong method", authRequest(authEvent(t, secretKey, body, withTag("method", "DELETE")), body), false},
+		{"no method", authRequest(authEvent(t, secretKey, body, withoutTag("method")), body), false},
+		{"wrong payload", authRequest(authEvent(t, secretKey, []byte(`{"kind":5}`), nil), body), false},
+		{"no payload", authRequest(authEvent(t, secretKey, body, withoutTag("payload")), body), false},
+		{"wrong kind", authRequest(authEvent(t, secretKey, body, func(e *nostr.Event) { e.Kind = 22242 }), body), false},
+		{"expired", authRequest(authEvent(t, secretKey, body, func(e *nostr.Event) { e.CreatedAt -= 120 }), body), false},
+		{"from the future", authRequest(authEvent(t, secretKey, body, func(e *nostr.Event) { e.CreatedAt += 120 }), body), false},
+		{"forged", func() *http.Request {
+			event := authEvent(t, secretKey, body, nil)
+			event.PubKey, _ = nostr.GetPublicKey(nostr.GeneratePrivateKey())
+			event.ID = event.GetID()
+			return authRequest(event, body)
+		}(), false},
+		{"not nostr scheme", func() *http.Request {
+			r := authRequest(authEvent(t, secretKey, body, nil), body)
+			r.Header.Set("Authorization", "Bearer "+strings.TrimPrefix(r.Header.Get("Authorization"), "Nostr "))
+			return r
+		}(), false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := authenticate(test.request)
+			if (err == nil) != test.valid {
+				t.Fatalf("authenticate = %v, want valid %v", err, test.valid)
+			}
+			if test.valid && got != publicKey {
+				t.Fatalf("authenticated as %s, want %s", got, publicKey)
+			}
+		})
+	}
+}
+
+func TestAuthenticateRejectsReplays(t *testing.T) {
+	secretKey := nostr.GeneratePrivateKey()
+	body := []byte(`{"kind":8}`)
+	event := authEvent(t, secretKey, body, nil)
+
+	if _, err := authenticate(authRequest(event, body)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := authenticate(authRequest(event, body)); err == nil {
+		t.Fatal("the same authorization event was accepted twice")
+	}
+}
+
+func TestAuthenticateKeepsBody(t *testing.T) {
+	body := []byte(`{"kind":8}`)
+	r := authRequest(authEvent(t, nostr.GeneratePrivateKey(), body, nil), body)
+	if _, err := authenticate(r); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	buf.ReadFrom(r.Body)
+	if buf.String() != string(body) {
+		t.Fatalf("body after authentication = %q", buf.String())
+	}
+}
+
+func TestAuthenticateSession(t *testing.T) {
+	db, err := store.Open(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+	cfg := utils.DefaultConfig()
+	cfg.SessionSecret = strings.Repeat("s", 32)
+	if err := handlers.ConfigureSessions(cfg, db); err != nil {
+		t.Fatal(err)
+	}
+
+	// Log in to get a session cookie
+	login := httptest.NewRequest(http.MethodGet, "/", nil)
+	recorder := httptest.NewRecorder()
+	session, _ := handlers.User.Get(login, "session-name")
+	session.Values["publicKey"] = "abc"
+	if err := session.Save(login, recorder); err != nil {
+		t.Fatal(err)
+	}
+	cookie := recorder.Result().Cookies()[0]
+
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		err         error
+	}{
+		{"read", http.MethodGet, "", nil},
+		{"json write", http.MethodPost, "application/json", nil},
+		{"json write with charset", http.MethodPut, "application/json; charset=utf-8", nil},
+		{"form write", http.MethodPost, "application/x-www-form-urlencoded", errSessionNeedsJSON},
+		{"text write", http.MethodDelete, "text/plain", errSessionNeedsJSON},
+		{"write without type", http.MethodPost, "", errSessionNeedsJSON},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(test.method, testURL, strings.NewReader("{}"))
+			r.AddCookie(cookie)
+			if test.contentType != "" {
+				r.Header.Set("Content-Type", test.contentType)
+			}
+			publicKey, err := authenticate(r)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("authenticate = %v, want %v", err, test.err)
+			}
+			if err == nil && publicKey != "abc" {
+				t.Fatalf("authenticated as %q", publicKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,37 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"badger/src/utils"
+)
+
+// createAward publishes a kind 8 badge award to the issuer's outbox and its recipients' inbox relays
+func createAward(w http.ResponseWriter, r *http.Request) {
+	publicKey, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
+	event, ok := readEvent(w, r)
+	if !ok {
+		return
+	}
+
+	var recipients []string
+	for _, tag := range event.Tags.GetAll([]string{"p"}) {
+		recipients = append(recipients, tag.Value())
+	}
+	if limit := utils.AppConfig.MaxAwardRecipients; len(recipients) > limit {
+		writeError(w, http.StatusUnprocessableEntity, "too_many_recipients", fmt.Sprintf("an award may name at most %d recipients, split it into several", limit))
+		return
+	}
+
+	if !prepareEvent(w, &event, publicKey, 8) {
+		return
+	}
+
+	relays := utils.AwardRelays(authorRelays(event.PubKey), recipients)
+	defer utils.ReleaseRelays(relays)
+	publish(w, event, publicKey, relays, "")
+}
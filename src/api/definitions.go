@@ -0,0 +1,114 @@
+package api
+
+import (
+	"net/http"
+
+	"badger/src/types"
+	"badger/src/utils"
+)
+
+// resolveDefinition fetches the badge definition of the {naddr} path value. The relays it returns come
+// from the request, release them with utils.ReleaseRelays.
+func resolveDefinition(w http.ResponseWriter, r *http.Request) (*types.BadgeDefinition, []string, bool) {
+	issuer, dTag, hints, err := utils.DecodeBadgeAddress(r.PathValue("naddr"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_naddr", err.Error())
+		return nil, nil, false
+	}
+
+	// Relay hints in the naddr come first, the issuer's outbox relays cover addresses without hints
+	relays := utils.PublicPageRelays(hints, issuer)
+	badge, err := utils.FetchBadgeDefinition(issuer, dTag, relays)
+	if err != nil || badge == nil {
+		utils.ReleaseRelays(relays)
+	}
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "relay_error", "failed to fetch badge definition")
+		return nil, nil, false
+	}
+	if badge == nil {
+		writeError(w, http.StatusNotFound, "not_found", "badge definition not found")
+		return nil, nil, false
+	}
+	return badge, relays, true
+}
+
+// getDefinition returns a badge definition
+func getDefinition(w http.ResponseWriter, r *http.Request) {
+	badge, relays, ok := resolveDefinition(w, r)
+	if !ok {
+		return
+	}
+	utils.ReleaseRelays(relays)
+	writeJSON(w, http.StatusOK, newDefinitionBody(*badge))
+}
+
+// listRecipients lists who a badge was awarded to, most recent awards first
+func listRecipients(w http.ResponseWriter, r *http.Request) {
+	badge, relays, ok := resolveDefinition(w, r)
+	if !ok {
+		return
+	}
+	defer utils.ReleaseRelays(relays)
+	pubKeys, err := utils.FetchBadgeRecipients(badge.PubKey, badge.DTag, relays)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "relay_error", "failed to fetch badge awards")
+		return
+	}
+
+	recipients := []recipientBody{}
+	for _, pubKey := range pubKeys {
+		recipients = append(recipients, recipientBody{PubKey: pubKey, NPub: utils.EncodeNPub(pubKey)})
+	}
+	writePage(w, r, recipients)
+}
+
+// createDefinition publishes a kind 30009 badge definition to its author's outbox relays
+func createDefinition(w http.ResponseWriter, r *http.Request) {
+	publicKey, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
+	event, ok := readEvent(w, r)
+	if !ok {
+		return
+	}
+	if !prepareEvent(w, &event, publicKey, 30009) {
+		return
+	}
+
+	relays := authorRelays(event.PubKey)
+	publish(w, event, publicKey, relays.WriteRelays(), utils.BadgeNAddr(event.PubKey, event.Tags.GetD(), relays))
+}
+
+// deleteDefinition publishes a kind 5 deletion (NIP-09) of a badge definition
+func deleteDefinition(w http.ResponseWriter, r *http.Request) {
+	publicKey, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
+	badge, _, ok := resolveDefinition(w, r)
+	if !ok {
+		return
+	}
+	event, ok := readEvent(w, r)
+	if !ok {
+		return
+	}
+	if event.PubKey == "" {
+		event.PubKey = badge.PubKey
+	}
+	if event.PubKey != badge.PubKey {
+		writeError(w, http.StatusForbidden, "forbidden", "only the badge's issuer can delete it")
+		return
+	}
+	if !prepareEvent(w, &event, publicKey, 5) {
+		return
+	}
+	if !event.Tags.ContainsAny("e", []string{badge.ID}) {
+		writeError(w, http.StatusUnprocessableEntity, "invalid_tag", "the deletion must reference the definition's event id in an e tag")
+		return
+	}
+
+	publish(w, event, publicKey, authorRelays(event.PubKey).WriteRelays(), "")
+}
@@ -0,0 +1,100 @@
+package api
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"badger/src/utils"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// maxEventBody limits the size of an event posted to the API
+const maxEventBody = 1 << 20
+
+// publishBody reports how the relays received a published event
+type publishBody struct {
+	EventID  string              `json:"event_id"`
+	NAddr    string              `json:"naddr,omitempty"` // Set for badge definitions
+	Accepted int                 `json:"accepted"`
+	Relays   []utils.RelayResult `json:"relays"`
+}
+
+// publishErrorBody is the error returned when no relay accepted an event, listing each relay's answer
+type publishErrorBody struct {
+	errorBody
+	Relays []utils.RelayResult `json:"relays"`
+}
+
+// readEvent decodes the event in the request body, answering 400 and reporting false when it isn't one
+func readEvent(w http.ResponseWriter, r *http.Request) (nostr.Event, bool) {
+	var event nostr.Event
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxEventBody)).Decode(&event); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid_event", "request body must be a nostr event")
+		return nostr.Event{}, false
+	}
+	return event, true
+}
+
+// prepareEvent checks the caller may publish the event as its author. Events of an organization issuer
+// the caller is a member of may be sent unsigned, Badger signs them with the issuer key.
+func prepareEvent(w http.ResponseWriter, event *nostr.Event, publicKey string, kind int) bool {
+	if event.PubKey == "" {
+		event.PubKey = publicKey
+	}
+	if event.PubKey != publicKey {
+		if !utils.CanIssueAs(publicKey, event.PubKey) {
+			writeError(w, http.StatusForbidden, "forbidden_issuer", "you may only publish as yourself or an organization issuer you are a member of")
+			return false
+		}
+		if event.Sig == "" {
+			if event.CreatedAt == 0 {
+				event.CreatedAt = nostr.Now()
+			}
+			if err := utils.SignAsIssuer(event, publicKey); err != nil {
+				utils.Errorf("Failed to sign API event as issuer %s: %v", event.PubKey, err)
+				writeError(w, http.StatusInternalServerError, "signing_failed", err.Error())
+				return false
+			}
+		}
+	}
+
+	if verr := utils.ValidateSignedEvent(*event, event.PubKey, kind); verr != nil {
+		utils.WriteValidationError(w, verr)
+		return false
+	}
+	return true
+}
+
+// authorRelays returns the NIP-65 relay list of an event's author, empty when it can't be fetched
+func authorRelays(publicKey string) utils.RelayList {
+	relays, err := utils.FetchUserRelays(publicKey, utils.AppConfig.BootstrapRelays)
+	if err != nil {
+		utils.Warnf("Failed to fetch relays of %s: %v", publicKey, err)
+		return utils.RelayList{}
+	}
+	return *relays
+}
+
+// publish sends the event to the relays and answers 201 with each relay's result, or 502 when none
+// accepted it
+func publish(w http.ResponseWriter, event nostr.Event, publicKey string, relays []string, naddr string) {
+	results := utils.PublishEvent(event, relays)
+	accepted := utils.CountAccepted(results)
+	if accepted > 0 {
+		utils.StoreEvent(event)
+	}
+	if event.PubKey != publicKey {
+		utils.RecordIssuerPublish(event, publicKey, results)
+	}
+
+	if accepted == 0 {
+		writeJSON(w, http.StatusBadGateway, publishErrorBody{
+			errorBody: errorBody{Code: "not_published", Message: "no relay accepted the event"},
+			Relays:    results,
+		})
+		return
+	}
+	writeJSON(w, http.StatusCreated, publishBody{EventID: event.ID, NAddr: naddr, Accepted: accepted, Relays: results})
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"badger/src/types"
+	"badger/src/utils"
+)
+
+// userBody is a nostr user with their profile metadata (kind 0) and relay list (NIP-65)
+type userBody struct {
+	PubKey      string     `json:"pubkey"`
+	NPub        string     `json:"npub"`
+	Name        string     `json:"name"`
+	DisplayName string     `json:"display_name"`
+	Picture     string     `json:"picture"`
+	About       string     `json:"about"`
+	NIP05       string     `json:"nip05"`
+	Relays      relaysBody `json:"relays"`
+}
+
+// relaysBody is a NIP-65 relay list, relays in "both" are read and written
+type relaysBody struct {
+	Read  []string `json:"read"`
+	Write []string `json:"write"`
+	Both  []string `json:"both"`
+}
+
+// definitionBody is a kind 30009 badge definition
+type definitionBody struct {
+	NAddr       string `json:"naddr"`
+	EventID     string `json:"event_id"`
+	Issuer      string `json:"issuer"`
+	IssuerNPub  string `json:"issuer_npub"`
+	DTag        string `json:"d"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Image       string `json:"image"`
+	Thumb       string `json:"thumb"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+// awardBody is a badge someone received through a kind 8 award
+type awardBody struct {
+	EventID     string `json:"event_id"`
+	NAddr       string `json:"naddr"`
+	Issuer      string `json:"issuer"`
+	IssuerNPub  string `json:"issuer_npub"`
+	DTag        string `json:"d"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Image       string `json:"image"`
+	Thumb       string `json:"thumb"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+// profileBadgeBody is one badge a user accepted onto their profile (kind 30008), in display order
+type profileBadgeBody struct {
+	NAddr        string `json:"naddr"`
+	Issuer       string `json:"issuer"`
+	DTag         string `json:"d"`
+	AwardEventID string `json:"award_event_id"`
+	AwardRelay   string `json:"award_relay"`
+	Name         string `json:"name"`
+	Image        string `json:"image"`
+	Thumb        string `json:"thumb"`
+}
+
+// recipientBody is a user a badge was awarded to
+type recipientBody struct {
+	PubKey string `json:"pubkey"`
+	NPub   string `json:"npub"`
+}
+
+func newRelaysBody(relays utils.RelayList) relaysBody {
+	// Empty lists are [] rather than null, so clients can always iterate them
+	body := relaysBody{Read: []string{}, Write: []string{}, Both: []string{}}
+	body.Read = append(body.Read, relays.Read...)
+	body.Write = append(body.Write, relays.Write...)
+	body.Both = append(body.Both, relays.Both...)
+	return body
+}
+
+func newDefinitionBody(badge types.BadgeDefinition) definitionBody {
+	return definitionBody{
+		NAddr:       utils.BadgeNAddr(badge.PubKey, badge.DTag, utils.RelayList{}),
+		EventID:     badge.ID,
+		Issuer:      badge.PubKey,
+		IssuerNPub:  utils.EncodeNPub(badge.PubKey),
+		DTag:        badge.DTag,
+		Name:        badge.Name,
+		Description: badge.Description,
+		Image:       badge.ImageURL,
+		Thumb:       badge.ThumbURL,
+		CreatedAt:   badge.CreatedAt,
+	}
+}
+
+func newAwardBody(badge utils.AwardedBadge) awardBody {
+	return awardBody{
+		EventID:     badge.EventID,
+		NAddr:       utils.BadgeNAddr(badge.AwardedBy, badge.Dtag, utils.RelayList{}),
+		Issuer:      badge.AwardedBy,
+		IssuerNPub:  utils.EncodeNPub(badge.AwardedBy),
+		DTag:        badge.Dtag,
+		Name:        badge.Name,
+		Description: badge.Description,
+		Image:       badge.ImageURL,
+		Thumb:       badge.ThumbURL,
+		CreatedAt:   badge.CreatedAt,
+	}
+}
@@ -0,0 +1,180 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+
+	"badger/src/utils"
+)
+
+// resolveUser reads the {user} path value, an npub, nprofile, hex public key or NIP-05 identifier. Anyone
+// may call these endpoints, so NIP-05 identifiers are only resolved from earlier lookups.
+func resolveUser(w http.ResponseWriter, r *http.Request) (string, bool) {
+	publicKey := utils.ResolveCachedPubKey(r.PathValue("user"))
+	if publicKey == "" {
+		writeError(w, http.StatusNotFound, "unknown_user", "expected an npub, nprofile, hex public key or a known NIP-05 identifier")
+		return "", false
+	}
+	return publicKey, true
+}
+
+// getMe returns the authenticated user
+func getMe(w http.ResponseWriter, r *http.Request) {
+	publicKey, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
+	writeUser(w, publicKey)
+}
+
+// getUser returns a user's profile and relays
+func getUser(w http.ResponseWriter, r *http.Request) {
+	publicKey, ok := resolveUser(w, r)
+	if !ok {
+		return
+	}
+	writeUser(w, publicKey)
+}
+
+func writeUser(w http.ResponseWriter, publicKey string) {
+	relays := authorRelays(publicKey)
+	body := userBody{
+		PubKey: publicKey,
+		NPub:   utils.EncodeNPub(publicKey),
+		Relays: newRelaysBody(relays),
+	}
+
+	outbox := utils.PublicPageRelays(nil, publicKey)
+	defer utils.ReleaseRelays(outbox)
+	metadata, err := utils.FetchUserMetadata(publicKey, outbox)
+	if err != nil {
+		utils.Warnf("Failed to fetch metadata of %s: %v", publicKey, err)
+	}
+	if metadata != nil {
+		body.Name = metadata.Name
+		body.DisplayName = metadata.DisplayName
+		body.Picture = metadata.Picture
+		body.About = metadata.About
+		body.NIP05 = metadata.NIP05
+	}
+	writeJSON(w, http.StatusOK, body)
+}
+
+// getUserRelays returns a user's NIP-65 relay list
+func getUserRelays(w http.ResponseWriter, r *http.Request) {
+	publicKey, ok := resolveUser(w, r)
+	if !ok {
+		return
+	}
+	writeJSON(w, http.StatusOK, newRelaysBody(authorRelays(publicKey)))
+}
+
+// listUserDefinitions lists the badge definitions a user created
+func listUserDefinitions(w http.ResponseWriter, r *http.Request) {
+	publicKey, ok := resolveUser(w, r)
+	if !ok {
+		return
+	}
+	relays := utils.PublicPageRelays(nil, publicKey)
+	defer utils.ReleaseRelays(relays)
+	badges, err := utils.FetchCreatedBadges(publicKey, relays)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "relay_error", "failed to fetch badge definitions")
+		return
+	}
+
+	definitions := []definitionBody{}
+	for _, badge := range badges {
+		definitions = append(definitions, newDefinitionBody(badge))
+	}
+	writePage(w, r, definitions)
+}
+
+// listUserAwards lists the badges a user was awarded, newest first
+func listUserAwards(w http.ResponseWriter, r *http.Request) {
+	publicKey, ok := resolveUser(w, r)
+	if !ok {
+		return
+	}
+
+	// Awards are sent to the user's inbox relays (NIP-65), though not every issuer follows that
+	awardRelays := utils.PublicInboxRelays(publicKey)
+	defer utils.ReleaseRelays(awardRelays)
+	definitionRelays := append(utils.PublicPageRelays(nil, publicKey), awardRelays...)
+	defer utils.ReleaseRelays(definitionRelays)
+	badges, err := utils.FetchAwardedBadges(publicKey, awardRelays, definitionRelays)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "relay_error", "failed to fetch awards")
+		return
+	}
+
+	awards := []awardBody{}
+	for _, badge := range badges {
+		awards = append(awards, newAwardBody(badge))
+	}
+	writePage(w, r, awards)
+}
+
+// listProfileBadges lists the badges a user shows on their profile, in their order
+func listProfileBadges(w http.ResponseWriter, r *http.Request) {
+	publicKey, ok := resolveUser(w, r)
+	if !ok {
+		return
+	}
+	relays := utils.PublicPageRelays(nil, publicKey)
+	defer utils.ReleaseRelays(relays)
+	profileBadges, err := utils.FetchProfileBadges(publicKey, relays)
+	if err != nil {
+		writeError(w, http.StatusBadGateway, "relay_error", "failed to fetch profile badges")
+		return
+	}
+	definitions, _ := utils.FetchBadgeDefinitions(profileBadges, relays)
+
+	badges := []profileBadgeBody{}
+	for _, event := range profileBadges {
+		for _, badge := range event.Badges {
+			body := profileBadgeBody{
+				NAddr:        utils.BadgeNAddr(badge.BadgeAwardedBy, badge.BadgeAwardDTag, utils.RelayList{}),
+				Issuer:       badge.BadgeAwardedBy,
+				DTag:         badge.BadgeAwardDTag,
+				AwardEventID: badge.AwardEventID,
+				AwardRelay:   badge.AwardRelayURL,
+			}
+			if definition, found := definitions[fmt.Sprintf("%s:%s", badge.BadgeAwardedBy, badge.BadgeAwardDTag)]; found {
+				body.Name = definition.Name
+				body.Image = definition.ImageURL
+				body.Thumb = definition.ThumbURL
+			}
+			badges = append(badges, body)
+		}
+	}
+	writePage(w, r, badges)
+}
+
+// putProfileBadges publishes the authenticated user's signed kind 30008 profile badges event
+func putProfileBadges(w http.ResponseWriter, r *http.Request) {
+	publicKey, ok := requireAuth(w, r)
+	if !ok {
+		return
+	}
+	user, ok := resolveUser(w, r)
+	if !ok {
+		return
+	}
+	if user != publicKey {
+		writeError(w, http.StatusForbidden, "forbidden", "you may only change your own profile badges")
+		return
+	}
+
+	event, ok := readEvent(w, r)
+	if !ok {
+		return
+	}
+	// Profile badges are always the user's own, so they are never signed by Badger
+	if verr := utils.ValidateSignedEvent(event, publicKey, 30008); verr != nil {
+		utils.WriteValidationError(w, verr)
+		return
+	}
+
+	publish(w, event, publicKey, authorRelays(publicKey).WriteRelays(), "")
+}
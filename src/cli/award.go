@@ -0,0 +1,118 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"badger/src/utils"
+)
+
+// award signs and publishes kind 8 awards of a badge, split into batches of max_award_recipients
+func award(args []string) error {
+	flags := utils.NewConfigFlags("badger award")
+	signerFlags := addSignerFlags(flags)
+	recipientsFile := flags.String("recipients-file", "", "file listing more recipients, one per line or CSV with an optional relay hint (- for stdin)")
+	asJSON := flags.Bool("json", false, "print JSON")
+	cfg, err := flags.Load(args)
+	if err != nil {
+		return err
+	}
+	if flags.NArg() == 0 {
+		return errors.New("expected the badge naddr followed by its recipients")
+	}
+
+	issuer, dTag, hints, err := utils.DecodeBadgeAddress(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+	recipientList := strings.Join(flags.Args()[1:], "\n")
+	if *recipientsFile != "" {
+		data, err := readInput(*recipientsFile)
+		if err != nil {
+			return fmt.Errorf("failed to read recipients file: %v", err)
+		}
+		recipientList += "\n" + string(data)
+	}
+
+	issuerRelays, err := userRelays(issuer)
+	if err != nil {
+		return err
+	}
+	recipients, invalid, err := utils.ParseRecipients(recipientList, utils.PreferredRelayHint(issuerRelays))
+	if err != nil {
+		return err
+	}
+	if len(invalid) > 0 {
+		return fmt.Errorf("could not resolve recipients: %s", strings.Join(invalid, ", "))
+	}
+	if len(recipients) == 0 {
+		return errors.New("no recipients given")
+	}
+
+	badge, err := utils.FetchBadgeDefinition(issuer, dTag, append(hints, issuerRelays.WriteRelays()...))
+	if err != nil {
+		return fmt.Errorf("failed to fetch badge definition: %v", err)
+	}
+	if badge == nil {
+		return errors.New("badge definition not found")
+	}
+
+	signer, err := signerFlags.open()
+	if err != nil {
+		return err
+	}
+	defer signer.Close()
+	if signer.PublicKey() != issuer {
+		return fmt.Errorf("the badge was issued by %s, sign with its key", utils.EncodeNPub(issuer))
+	}
+
+	var outputs []publishOutput
+	failed := 0
+	for _, batch := range utils.BatchRecipients(recipients, cfg.MaxAwardRecipients) {
+		event := utils.BuildBadgeAwardEvent(issuer, dTag, batch)
+		if err := signer.Sign(event); err != nil {
+			return fmt.Errorf("failed to sign award: %v", err)
+		}
+		if verr := utils.ValidateSignedEvent(*event, issuer, 8); verr != nil {
+			return verr
+		}
+
+		var pubKeys []string
+		for _, recipient := range batch {
+			pubKeys = append(pubKeys, recipient.PubKey)
+		}
+		relays := utils.AwardRelays(issuerRelays, pubKeys)
+		results := utils.PublishEvent(*event, relays)
+		utils.ReleaseRelays(relays)
+		output := newPublishOutput(event.ID, "", results)
+		if output.Accepted == 0 {
+			failed++
+		}
+		outputs = append(outputs, output)
+		if !*asJSON {
+			fmt.Printf("award %s to %d recipients:\n", event.ID, len(batch))
+			printResults(results)
+		}
+	}
+
+	if *asJSON {
+		if err := printJSON(outputs); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d awards were not accepted by any relay", failed, len(outputs))
+	}
+	return nil
+}
+
+// readInput reads a file, or stdin for "-"
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"fmt"
+	"time"
+
+	"badger/src/utils"
+)
+
+// badgeOutput is a single badge printed by badger badges list
+type badgeOutput struct {
+	NAddr       string `json:"naddr"`
+	Issuer      string `json:"issuer"`
+	D           string `json:"d"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Image       string `json:"image,omitempty"`
+	EventID     string `json:"event_id,omitempty"`
+	CreatedAt   int64  `json:"created_at,omitempty"`
+}
+
+// listBadges prints the badges a user was awarded, created or shows on their profile
+func listBadges(args []string) error {
+	flags := utils.NewConfigFlags("badger badges list")
+	listType := flags.String("type", "awarded", "badges to list: awarded, created or profile")
+	asJSON := flags.Bool("json", false, "print JSON")
+	if _, err := flags.Load(args); err != nil {
+		return err
+	}
+	publicKey, err := userArg(flags.Args())
+	if err != nil {
+		return err
+	}
+	relays, err := userRelays(publicKey)
+	if err != nil {
+		return err
+	}
+
+	var badges []badgeOutput
+	switch *listType {
+	case "awarded":
+		badges, err = awardedBadges(publicKey, relays)
+	case "created":
+		badges, err = createdBadges(publicKey, relays)
+	case "profile":
+		badges, err = profileBadges(publicKey, relays)
+	default:
+		return fmt.Errorf("unknown -type %q, expected awarded, created or profile", *listType)
+	}
+	if err != nil {
+		return err
+	}
+
+	if *asJSON {
+		if badges == nil {
+			badges = []badgeOutput{}
+		}
+		return printJSON(badges)
+	}
+	if len(badges) == 0 {
+		fmt.Println("no badges found")
+		return nil
+	}
+	rows := [][]string{{"NAME", "ISSUER", "DATE", "NADDR"}}
+	for _, badge := range badges {
+		date := ""
+		if badge.CreatedAt > 0 {
+			date = time.Unix(badge.CreatedAt, 0).Format("2006-01-02")
+		}
+		rows = append(rows, []string{badge.Name, utils.ShortNPub(badge.Issuer), date, badge.NAddr})
+	}
+	printTable(rows)
+	return nil
+}
+
+func awardedBadges(publicKey string, relays utils.RelayList) ([]badgeOutput, error) {
+	awardRelays := utils.WithFallbackRelays(relays.ReadRelays())
+	definitionRelays := utils.WithFallbackRelays(relays.AllRelays())
+	awarded, err := utils.FetchAwardedBadges(publicKey, awardRelays, definitionRelays)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch awarded badges: %v", err)
+	}
+
+	var badges []badgeOutput
+	for _, badge := range awarded {
+		badges = append(badges, badgeOutput{
+			NAddr:       utils.BadgeNAddr(badge.AwardedBy, badge.Dtag, utils.RelayList{}),
+			Issuer:      badge.AwardedBy,
+			D:           badge.Dtag,
+			Name:        badge.Name,
+			Description: badge.Description,
+			Image:       badge.ImageURL,
+			EventID:     badge.EventID,
+			CreatedAt:   badge.CreatedAt,
+		})
+	}
+	return badges, nil
+}
+
+func createdBadges(publicKey string, relays utils.RelayList) ([]badgeOutput, error) {
+	created, err := utils.FetchCreatedBadges(publicKey, utils.WithFallbackRelays(relays.WriteRelays()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch created badges: %v", err)
+	}
+
+	var badges []badgeOutput
+	for _, badge := range created {
+		badges = append(badges, badgeOutput{
+			NAddr:       utils.BadgeNAddr(badge.PubKey, badge.DTag, relays),
+			Issuer:      badge.PubKey,
+			D:           badge.DTag,
+			Name:        badge.Name,
+			Description: badge.Description,
+			Image:       badge.ImageURL,
+			EventID:     badge.ID,
+			CreatedAt:   badge.CreatedAt,
+		})
+	}
+	return badges, nil
+}
+
+func profileBadges(publicKey string, relays utils.RelayList) ([]badgeOutput, error) {
+	outbox := utils.WithFallbackRelays(relays.WriteRelays())
+	events, err := utils.FetchProfileBadges(publicKey, outbox)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch profile badges: %v", err)
+	}
+	definitions, _ := utils.FetchBadgeDefinitions(events, outbox)
+
+	var badges []badgeOutput
+	for _, event := range events {
+		for _, badge := range event.Badges {
+			output := badgeOutput{
+				NAddr:   utils.BadgeNAddr(badge.BadgeAwardedBy, badge.BadgeAwardDTag, utils.RelayList{}),
+				Issuer:  badge.BadgeAwardedBy,
+				D:       badge.BadgeAwardDTag,
+				EventID: badge.AwardEventID,
+			}
+			if definition, found := definitions[fmt.Sprintf("%s:%s", badge.BadgeAwardedBy, badge.BadgeAwardDTag)]; found {
+				output.Name = definition.Name
+				output.Description = definition.Description
+				output.Image = definition.ImageURL
+			}
+			badges = append(badges, output)
+		}
+	}
+	return badges, nil
+}
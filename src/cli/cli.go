@@ -0,0 +1,91 @@
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"badger/src/utils"
+)
+
+// Usage lists the subcommands, each also accepts every config flag (see "badger serve -h")
+const Usage = `Usage: badger <command> [flags] [arguments]
+
+Commands:
+  serve                            run the web server (the default without a command)
+  badges list [-type t] <npub>     list the badges a user was awarded, created or shows on their profile
+  award <naddr> <npub...>          award a badge to one or more users
+  definition create -f def.json    publish a badge definition
+  relays <npub>                    show a user's NIP-65 relay list
+
+award and definition create sign with -key <file> (nsec, hex or ncryptsec) or -bunker <bunker://...>.
+Run "badger <command> -h" for a command's flags.
+`
+
+// errUsage is returned for a command line that doesn't name a known command
+var errUsage = errors.New("unknown command, run badger help")
+
+// Run executes a subcommand other than serve with the arguments following it
+func Run(command string, args []string) error {
+	switch command {
+	case "badges":
+		if len(args) == 0 || args[0] != "list" {
+			return errors.New(`expected "badger badges list <npub>"`)
+		}
+		return listBadges(args[1:])
+	case "award":
+		return award(args)
+	case "definition":
+		if len(args) == 0 || args[0] != "create" {
+			return errors.New(`expected "badger definition create -f def.json"`)
+		}
+		return createDefinition(args[1:])
+	case "relays":
+		return showRelays(args)
+	case "help", "-h", "-help", "--help":
+		fmt.Print(Usage)
+		return nil
+	}
+	return errUsage
+}
+
+// printJSON writes value to stdout for scripts reading -json output
+func printJSON(value any) error {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(value)
+}
+
+// printTable writes tab separated rows to stdout as aligned columns
+func printTable(rows [][]string) {
+	table := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintln(table, strings.Join(row, "\t"))
+	}
+	table.Flush()
+}
+
+// publishOutput is the -json output of a published event, shaped like the API's publish response
+type publishOutput struct {
+	EventID  string              `json:"event_id"`
+	NAddr    string              `json:"naddr,omitempty"`
+	Accepted int                 `json:"accepted"`
+	Relays   []utils.RelayResult `json:"relays"`
+}
+
+func newPublishOutput(eventID, naddr string, results []utils.RelayResult) publishOutput {
+	return publishOutput{EventID: eventID, NAddr: naddr, Accepted: utils.CountAccepted(results), Relays: results}
+}
+
+// printResults prints how each relay answered a published event
+func printResults(results []utils.RelayResult) {
+	var rows [][]string
+	for _, result := range results {
+		rows = append(rows, []string{"  " + result.Relay, result.Status, result.Message})
+	}
+	printTable(rows)
+	fmt.Printf("  accepted by %d of %d relays\n", utils.CountAccepted(results), len(results))
+}
@@ -0,0 +1,121 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"badger/src/utils"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// definitionFile describes a badge definition to publish, read from the -f JSON file
+type definitionFile struct {
+	D               string `json:"d"`
+	Name            string `json:"name"`
+	Description     string `json:"description"`
+	Image           string `json:"image"`
+	ImageDimensions string `json:"image_dimensions"`
+	Thumbs          []struct {
+		URL        string `json:"url"`
+		Dimensions string `json:"dimensions"`
+	} `json:"thumbs"`
+}
+
+// event builds the unsigned kind 30009 badge definition (NIP-58)
+func (d definitionFile) event(publicKey string) (*nostr.Event, error) {
+	if d.D == "" || d.Name == "" || d.Image == "" {
+		return nil, errors.New("the definition needs at least d, name and image")
+	}
+
+	image := nostr.Tag{"image", d.Image}
+	if d.ImageDimensions != "" {
+		image = append(image, d.ImageDimensions)
+	}
+	tags := nostr.Tags{
+		nostr.Tag{"d", d.D},
+		nostr.Tag{"name", d.Name},
+		nostr.Tag{"description", d.Description},
+		image,
+	}
+	for _, thumb := range d.Thumbs {
+		tag := nostr.Tag{"thumb", thumb.URL}
+		if thumb.Dimensions != "" {
+			tag = append(tag, thumb.Dimensions)
+		}
+		tags = append(tags, tag)
+	}
+
+	return &nostr.Event{
+		PubKey:    publicKey,
+		CreatedAt: nostr.Timestamp(time.Now().Unix()),
+		Kind:      30009, // Badge definition event kind (NIP-58)
+		Tags:      tags,
+	}, nil
+}
+
+// createDefinition signs a badge definition and publishes it to the signer's outbox relays
+func createDefinition(args []string) error {
+	flags := utils.NewConfigFlags("badger definition create")
+	signerFlags := addSignerFlags(flags)
+	path := flags.String("f", "", `JSON file with d, name, description, image, image_dimensions and thumbs (- for stdin)`)
+	asJSON := flags.Bool("json", false, "print JSON")
+	if _, err := flags.Load(args); err != nil {
+		return err
+	}
+	if *path == "" {
+		return errors.New("expected -f def.json")
+	}
+
+	data, err := readInput(*path)
+	if err != nil {
+		return fmt.Errorf("failed to read definition: %v", err)
+	}
+	var definition definitionFile
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&definition); err != nil {
+		return fmt.Errorf("failed to decode definition: %v", err)
+	}
+
+	signer, err := signerFlags.open()
+	if err != nil {
+		return err
+	}
+	defer signer.Close()
+
+	event, err := definition.event(signer.PublicKey())
+	if err != nil {
+		return err
+	}
+	if err := signer.Sign(event); err != nil {
+		return fmt.Errorf("failed to sign definition: %v", err)
+	}
+	if verr := utils.ValidateSignedEvent(*event, signer.PublicKey(), 30009); verr != nil {
+		return verr
+	}
+
+	relays, err := userRelays(signer.PublicKey())
+	if err != nil {
+		return err
+	}
+	results := utils.PublishEvent(*event, relays.WriteRelays())
+	naddr := utils.BadgeNAddr(event.PubKey, definition.D, relays)
+
+	if *asJSON {
+		if err := printJSON(newPublishOutput(event.ID, naddr, results)); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf("definition %s:\n", event.ID)
+		printResults(results)
+		fmt.Println(naddr)
+	}
+	if utils.CountAccepted(results) == 0 {
+		return errors.New("no relay accepted the definition")
+	}
+	return nil
+}
@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd)
+
+package cli
+
+import "errors"
+
+// readPassword can't turn off echo here, so passwords only come from $BADGER_KEY_PASSWORD
+func readPassword(prompt string) (string, error) {
+	return "", errors.New("no terminal prompt on this platform")
+}
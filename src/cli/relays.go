@@ -0,0 +1,74 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+
+	"badger/src/utils"
+)
+
+// relaysOutput is the -json output of badger relays
+type relaysOutput struct {
+	PubKey string   `json:"pubkey"`
+	Read   []string `json:"read"`
+	Write  []string `json:"write"`
+	Both   []string `json:"both"`
+}
+
+// showRelays prints a user's NIP-65 relay list
+func showRelays(args []string) error {
+	flags := utils.NewConfigFlags("badger relays")
+	asJSON := flags.Bool("json", false, "print JSON")
+	if _, err := flags.Load(args); err != nil {
+		return err
+	}
+	publicKey, err := userArg(flags.Args())
+	if err != nil {
+		return err
+	}
+
+	relays, err := userRelays(publicKey)
+	if err != nil {
+		return err
+	}
+	if *asJSON {
+		return printJSON(relaysOutput{PubKey: publicKey, Read: relays.Read, Write: relays.Write, Both: relays.Both})
+	}
+
+	var rows [][]string
+	for _, group := range []struct {
+		marker string
+		relays []string
+	}{{"read", relays.Read}, {"write", relays.Write}, {"both", relays.Both}} {
+		for _, url := range group.relays {
+			rows = append(rows, []string{group.marker, url})
+		}
+	}
+	if len(rows) == 0 {
+		fmt.Println("no relay list found")
+		return nil
+	}
+	printTable(rows)
+	return nil
+}
+
+// userArg resolves the single user argument of a command
+func userArg(args []string) (string, error) {
+	if len(args) != 1 {
+		return "", errors.New("expected one npub, nprofile, hex public key or NIP-05 identifier")
+	}
+	publicKey := utils.ResolvePubKey(args[0])
+	if publicKey == "" {
+		return "", fmt.Errorf("unknown user %q", args[0])
+	}
+	return publicKey, nil
+}
+
+// userRelays looks up a user's relay list on the bootstrap relays
+func userRelays(publicKey string) (utils.RelayList, error) {
+	relays, err := utils.FetchUserRelays(publicKey, utils.AppConfig.BootstrapRelays)
+	if err != nil {
+		return utils.RelayList{}, fmt.Errorf("failed to fetch relay list: %v", err)
+	}
+	return *relays, nil
+}
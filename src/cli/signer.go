@@ -0,0 +1,113 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"badger/src/utils"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// signer signs events as the user running the command
+type signer interface {
+	PublicKey() string
+	Sign(event *nostr.Event) error
+	Close()
+}
+
+// signerFlags choose how a command signs: a local key file or a NIP-46 remote signer
+type signerFlags struct {
+	keyFile *string
+	bunker  *string
+}
+
+func addSignerFlags(flags *utils.ConfigFlags) signerFlags {
+	return signerFlags{
+		keyFile: flags.String("key", os.Getenv("BADGER_KEY_FILE"), "file holding the secret key to sign with, as nsec, hex or ncryptsec (or $BADGER_KEY_FILE)"),
+		bunker:  flags.String("bunker", os.Getenv("BADGER_BUNKER"), "bunker:// URI of a NIP-46 remote signer to sign with (or $BADGER_BUNKER)"),
+	}
+}
+
+// open returns the signer chosen by the flags
+func (f signerFlags) open() (signer, error) {
+	switch {
+	case *f.keyFile != "" && *f.bunker != "":
+		return nil, errors.New("use either -key or -bunker, not both")
+	case *f.keyFile != "":
+		data, err := os.ReadFile(*f.keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %v", err)
+		}
+		key := strings.TrimSpace(string(data))
+		var password string
+		if strings.HasPrefix(key, "ncryptsec1") {
+			if password, err = keyPassword(); err != nil {
+				return nil, err
+			}
+		}
+		secretKey, err := utils.DecodeSecretKey(key, password)
+		if err != nil {
+			return nil, err
+		}
+		publicKey, err := nostr.GetPublicKey(secretKey)
+		if err != nil {
+			return nil, fmt.Errorf("invalid secret key: %v", err)
+		}
+		return keySigner{secretKey: secretKey, publicKey: publicKey}, nil
+	case *f.bunker != "":
+		bunker, publicKey, err := utils.ConnectBunker(*f.bunker)
+		if err != nil {
+			return nil, err
+		}
+		return bunkerSigner{bunker: bunker, publicKey: publicKey}, nil
+	}
+	return nil, errors.New("sign with -key <file> or -bunker <bunker://...>")
+}
+
+// keyPassword returns the password of an ncryptsec key file from $BADGER_KEY_PASSWORD, or asks for it
+// on the terminal. There is no flag for it, command lines show up in ps and the shell history.
+func keyPassword() (string, error) {
+	if password := os.Getenv("BADGER_KEY_PASSWORD"); password != "" {
+		return password, nil
+	}
+	password, err := readPassword("Password of the key file: ")
+	if err != nil {
+		return "", fmt.Errorf("failed to read the key password, set $BADGER_KEY_PASSWORD instead: %v", err)
+	}
+	return password, nil
+}
+
+// keySigner signs with a secret key read from a file
+type keySigner struct {
+	secretKey string
+	publicKey string
+}
+
+func (s keySigner) PublicKey() string { return s.publicKey }
+
+func (s keySigner) Sign(event *nostr.Event) error { return event.Sign(s.secretKey) }
+
+func (s keySigner) Close() {}
+
+// bunkerSigner asks a NIP-46 remote signer, which may wait for the user to approve each event
+type bunkerSigner struct {
+	bunker    utils.BunkerSession
+	publicKey string
+}
+
+func (s bunkerSigner) PublicKey() string { return s.publicKey }
+
+func (s bunkerSigner) Sign(event *nostr.Event) error {
+	if err := s.bunker.SignEvent(event); err != nil {
+		return err
+	}
+	if event.PubKey != s.publicKey {
+		return errors.New("remote signer signed with a different key")
+	}
+	return nil
+}
+
+func (s bunkerSigner) Close() { s.bunker.Close() }
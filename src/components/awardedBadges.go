@@ -5,17 +5,8 @@ import (
 	"badger/src/utils"
 	"html/template"
 	"net/http"
-	"sync"
 )
 
-// Cache for storing awarded badges without expiration
-var awardedBadgesCache = struct {
-	sync.RWMutex
-	data map[string]utils.PageData
-}{
-	data: make(map[string]utils.PageData),
-}
-
 func RenderAwardedBadges(w http.ResponseWriter, r *http.Request) {
 	// Retrieve session
 	session, _ := handlers.User.Get(r, "session-name")
@@ -27,67 +18,46 @@ func RenderAwardedBadges(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if cache should be cleared
-	clearCache := r.URL.Query().Get("clear_cache")
-
-	if clearCache == "true" {
-		// Clear the cache for this user
-		awardedBadgesCache.Lock()
-		delete(awardedBadgesCache.data, publicKey)
-		awardedBadgesCache.Unlock()
+	// Refreshing forgets the user's last relay sync so everything is fetched again
+	if r.URL.Query().Get("clear_cache") == "true" {
+		utils.InvalidateCache(publicKey)
 	}
 
-	// Check cache after potential clearing
-	awardedBadgesCache.RLock()
-	cachedData, found := awardedBadgesCache.data[publicKey]
-	awardedBadgesCache.RUnlock()
+	// Retrieve relays from session
+	relays, _ := session.Values["relays"].(utils.RelayList)
 
-	if found && clearCache != "true" {
-		// Serve from cache
-		renderAwardedBadges(w, cachedData)
+	// Awards are sent to the user's inbox relays (NIP-65), though not every issuer follows that
+	awardRelays := utils.WithFallbackRelays(relays.ReadRelays())
+	definitionRelays := utils.WithFallbackRelays(relays.AllRelays())
+	awardedBadges, err := utils.FetchAwardedBadges(publicKey, awardRelays, definitionRelays)
+	if err != nil {
+		http.Error(w, "Failed to fetch awarded badges", http.StatusInternalServerError)
 		return
 	}
 
-	// Cache miss or cleared: Retrieve the public relays
-	// (Assuming a predefined list of public relays)
-	publicRelays := []string{
-		"wss://nos.lol",
-		"wss://relay.damus.io",
-		"wss://relay.nostr.band",
-		"wss://relay.primal.net",
-		"wss://offchain.pub",
-		"wss://nostr.mom",
-		"wss://nostr.oxtr.dev",
-		"wss://nostr.fmt.wiz.biz",
-		"wss://nostr.bitcoiner.social",
-		"wss://relay.snort.social",
-		"wss://soloco.nl",
-		// Add more public relays as needed
+	// Resolve every issuer's profile in one request
+	var issuers []string
+	for _, badge := range awardedBadges {
+		issuers = append(issuers, badge.AwardedBy)
 	}
-
-	// Fetch awarded badges from public relays
-	awardedBadges, err := utils.FetchAwardedBadges(publicKey, publicRelays)
+	awarders, err := utils.FetchUsersMetadata(issuers, utils.AppConfig.BootstrapRelays)
 	if err != nil {
-		http.Error(w, "Failed to fetch awarded badges", http.StatusInternalServerError)
-		return
+		utils.Warnf("Failed to resolve awarders: %v", err)
 	}
+	utils.DefaultNIP05Verifier.VerifyAwarders(awarders)
 
 	// Prepare data for the template
 	data := utils.PageData{
 		AwardedBadges: awardedBadges,
+		Awarders:      awarders,
 	}
 
-	// Store in cache
-	awardedBadgesCache.Lock()
-	awardedBadgesCache.data[publicKey] = data
-	awardedBadgesCache.Unlock()
-
 	// Render the component
 	renderAwardedBadges(w, data)
 }
 
 func renderAwardedBadges(w http.ResponseWriter, data utils.PageData) {
-	tmpl := template.Must(template.ParseFiles("web/views/components/awarded-badges.html"))
+	tmpl := template.Must(template.New("").Funcs(utils.TemplateFuncs).ParseFiles("web/views/components/awarded-badges.html", "web/views/components/awarder.html"))
 	err := tmpl.ExecuteTemplate(w, "awardedBadges", data)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -5,17 +5,8 @@ import (
 	"badger/src/utils"
 	"html/template"
 	"net/http"
-	"sync"
 )
 
-// Cache for storing badges without expiration
-var badgesCache = struct {
-	sync.RWMutex
-	data map[string]utils.PageData
-}{
-	data: make(map[string]utils.PageData),
-}
-
 func RenderCreatedBadges(w http.ResponseWriter, r *http.Request) {
 	// Retrieve session
 	session, _ := handlers.User.Get(r, "session-name")
@@ -27,37 +18,20 @@ func RenderCreatedBadges(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// Check if cache should be cleared
-	clearCache := r.URL.Query().Get("clear_cache")
-
-	if clearCache == "true" {
-		// Clear the cache for this user
-		badgesCache.Lock()
-		delete(badgesCache.data, publicKey)
-		badgesCache.Unlock()
-	}
-
-	// Check cache after potential clearing
-	badgesCache.RLock()
-	cachedData, found := badgesCache.data[publicKey]
-	badgesCache.RUnlock()
-
-	if found && clearCache != "true" {
-		// Serve from cache
-		renderCreatedBadges(w, cachedData)
-		return
+	// Refreshing forgets the user's last relay sync so everything is fetched again
+	if r.URL.Query().Get("clear_cache") == "true" {
+		utils.InvalidateCache(publicKey)
 	}
 
-	// Cache miss or cleared: Retrieve relays from session
+	// Retrieve relays from session
 	relays, ok := session.Values["relays"].(utils.RelayList)
 	if !ok {
 		http.Error(w, "No relays found in session", http.StatusInternalServerError)
 		return
 	}
 
-	// Combine all relays into a single slice
-	allRelays := append(relays.Read, relays.Write...)
-	allRelays = append(allRelays, relays.Both...)
+	// The user's badge definitions are on their outbox relays (NIP-65)
+	allRelays := relays.WriteRelays()
 
 	// Fetch the created badges from the relays
 	badges, err := utils.FetchCreatedBadges(publicKey, allRelays)
@@ -66,22 +40,49 @@ func RenderCreatedBadges(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Give every badge a shareable naddr pointing at the user's write relays
+	var createdBadges []utils.BadgeLink
+	for _, badge := range badges {
+		createdBadges = append(createdBadges, utils.BadgeLink{
+			BadgeDefinition: badge,
+			NAddr:           utils.BadgeNAddr(publicKey, badge.DTag, relays),
+			IssuerNPub:      utils.EncodeNPub(publicKey),
+		})
+	}
+
+	// Badges of the organization issuers the user is a member of
+	for _, issuer := range utils.IssuersFor(publicKey) {
+		issuerRelays, err := utils.FetchUserRelays(issuer.PubKey, utils.AppConfig.BootstrapRelays)
+		if err != nil {
+			utils.Warnf("Failed to fetch relays of issuer %s: %v", issuer.Name, err)
+			continue
+		}
+		issuerBadges, err := utils.FetchCreatedBadges(issuer.PubKey, issuerRelays.WriteRelays())
+		if err != nil {
+			utils.Warnf("Failed to fetch badges of issuer %s: %v", issuer.Name, err)
+			continue
+		}
+		for _, badge := range issuerBadges {
+			createdBadges = append(createdBadges, utils.BadgeLink{
+				BadgeDefinition: badge,
+				NAddr:           utils.BadgeNAddr(issuer.PubKey, badge.DTag, *issuerRelays),
+				IssuerNPub:      utils.EncodeNPub(issuer.PubKey),
+				IssuerName:      issuer.Name,
+			})
+		}
+	}
+
 	// Prepare data for the template
 	data := utils.PageData{
-		CreatedBadges: badges,
+		CreatedBadges: createdBadges,
 	}
 
-	// Store in cache
-	badgesCache.Lock()
-	badgesCache.data[publicKey] = data
-	badgesCache.Unlock()
-
 	// Render the component
 	renderCreatedBadges(w, data)
 }
 
 func renderCreatedBadges(w http.ResponseWriter, data utils.PageData) {
-	tmpl := template.Must(template.ParseFiles("web/views/components/created-badges.html"))
+	tmpl := template.Must(template.New("").Funcs(utils.TemplateFuncs).ParseFiles("web/views/components/created-badges.html"))
 	err := tmpl.ExecuteTemplate(w, "createdBadges", data)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
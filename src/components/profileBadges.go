@@ -4,19 +4,11 @@ import (
 	"badger/src/handlers"
 	"badger/src/types"
 	"badger/src/utils"
+	"fmt"
 	"html/template"
 	"net/http"
-	"sync"
 )
 
-// Cache for storing profile badges
-var profileBadgesCache = struct {
-	sync.RWMutex
-	data map[string]utils.PageData
-}{
-	data: make(map[string]utils.PageData),
-}
-
 func RenderProfileBadgeEvent(w http.ResponseWriter, r *http.Request) {
 	// Retrieve session
 	session, _ := handlers.User.Get(r, "session-name")
@@ -28,76 +20,130 @@ func RenderProfileBadgeEvent(w http.ResponseWriter, r *http.Request) {
 		return // Ensure return after http.Error
 	}
 
-	// Check if cache should be cleared
-	clearCache := r.URL.Query().Get("clear_cache")
-
-	if clearCache == "true" {
-		// Clear the cache for this user
-		profileBadgesCache.Lock()
-		delete(profileBadgesCache.data, publicKey)
-		profileBadgesCache.Unlock()
-	}
-
-	// Check cache after potential clearing
-	profileBadgesCache.RLock()
-	cachedData, found := profileBadgesCache.data[publicKey]
-	profileBadgesCache.RUnlock()
-
-	if found && clearCache != "true" {
-		// Serve from cache
-		renderProfileBadge(w, cachedData, cachedData.BadgeDefinitions)
-		return // Ensure return after serving from cache
+	// Refreshing forgets the user's last relay sync so everything is fetched again
+	if r.URL.Query().Get("clear_cache") == "true" {
+		utils.InvalidateCache(publicKey)
 	}
 
-	// Cache miss or cleared: Retrieve relays from session
+	// Retrieve relays from session
 	relays, ok := session.Values["relays"].(utils.RelayList)
 	if !ok {
 		http.Error(w, "No relays found in session", http.StatusInternalServerError)
 		return // Ensure return after http.Error
 	}
 
-	// Combine all relays into a single slice
-	allRelays := append(relays.Read, relays.Write...)
-	allRelays = append(allRelays, relays.Both...)
-
-	// Fetch the profile badges from the relays
-	profileBadgesEvents, err := utils.FetchProfileBadges(publicKey, allRelays)
+	// Fetch the profile badges from the user's outbox relays
+	profileBadgesEvents, err := utils.FetchProfileBadges(publicKey, relays.WriteRelays())
 	if err != nil {
 		http.Error(w, "Failed to fetch profile badges", http.StatusInternalServerError)
 		return
 	}
 
 	// Fetch badge definitions
-	badgeDefinitions, err := utils.FetchBadgeDefinitions(profileBadgesEvents, allRelays)
+	badgeDefinitions, err := utils.FetchBadgeDefinitions(profileBadgesEvents, utils.WithFallbackRelays(relays.AllRelays()))
 	if err != nil {
 		http.Error(w, "Failed to fetch badge definitions", http.StatusInternalServerError)
 		return
 	}
+	// Fetch awarded badges from the user's inbox so the ones not yet in the profile can be accepted
+	awardRelays := utils.WithFallbackRelays(relays.ReadRelays())
+	definitionRelays := utils.WithFallbackRelays(relays.AllRelays())
+	awardedBadges, err := utils.FetchAwardedBadges(publicKey, awardRelays, definitionRelays)
+	if err != nil {
+		utils.Warnf("Failed to fetch awarded badges for profile editor: %v", err)
+	}
+
+	// Resolve the profiles of everyone who awarded a listed badge in one request
+	var issuers []string
+	for _, event := range profileBadgesEvents {
+		for _, badge := range event.Badges {
+			issuers = append(issuers, badge.BadgeAwardedBy)
+		}
+	}
+	for _, badge := range awardedBadges {
+		issuers = append(issuers, badge.AwardedBy)
+	}
+	awarders, err := utils.FetchUsersMetadata(issuers, utils.AppConfig.BootstrapRelays)
+	if err != nil {
+		utils.Warnf("Failed to resolve awarders: %v", err)
+	}
+	utils.DefaultNIP05Verifier.VerifyAwarders(awarders)
+
 	// Prepare data for the template
 	data := utils.PageData{
 		ProfileBadges:    profileBadgesEvents,
 		BadgeDefinitions: badgeDefinitions,
+		AwardedBadges:    awardedBadges,
+		Awarders:         awarders,
 	}
 
-	// Store in cache
-	profileBadgesCache.Lock()
-	profileBadgesCache.data[publicKey] = data
-	profileBadgesCache.Unlock()
-
 	// Render the component
-	renderProfileBadge(w, data, badgeDefinitions)
+	renderProfileBadge(w, data, r.URL.Query().Get("wear"))
 }
 
-func renderProfileBadge(w http.ResponseWriter, data utils.PageData, badgeDefinitions map[string]types.BadgeDefinition) {
-	tmpl := template.Must(template.ParseFiles("web/views/components/profile-badges.html"))
+// renderProfileBadge renders the profile badges editor. wearEventID optionally names an awarded badge
+// that is moved into the (unsaved) profile list, used by the "Wear this Badge!" button.
+func renderProfileBadge(w http.ResponseWriter, data utils.PageData, wearEventID string) {
+	tmpl := template.Must(template.New("").Funcs(utils.TemplateFuncs).ParseFiles("web/views/components/profile-badges.html", "web/views/components/awarder.html"))
+
+	// Copy the profile so the cached data isn't modified
+	var profileBadges []utils.ProfileBadge
+	inProfile := make(map[string]bool)
+	for _, event := range data.ProfileBadges {
+		for _, badge := range event.Badges {
+			profileBadges = append(profileBadges, badge)
+			inProfile[badge.BadgeAwardATag] = true
+		}
+	}
+	badgeDefinitions := make(map[string]types.BadgeDefinition, len(data.BadgeDefinitions))
+	for key, badgeDef := range data.BadgeDefinitions {
+		badgeDefinitions[key] = badgeDef
+	}
+
+	// Awarded badges that aren't shown on the profile yet
+	var availableBadges []utils.AwardedBadge
+	unsaved := false
+	for _, awarded := range data.AwardedBadges {
+		if inProfile[awarded.ATag] {
+			continue
+		}
+		inProfile[awarded.ATag] = true
+
+		if wearEventID == "" || awarded.EventID != wearEventID {
+			availableBadges = append(availableBadges, awarded)
+			continue
+		}
+
+		// Wear the requested badge by adding it to the end of the profile
+		profileBadges = append(profileBadges, utils.ProfileBadge{
+			BadgeAwardATag: awarded.ATag,
+			AwardEventID:   awarded.EventID,
+			BadgeAwardedBy: awarded.AwardedBy,
+			BadgeAwardDTag: awarded.Dtag,
+		})
+		badgeDefinitions[fmt.Sprintf("%s:%s", awarded.AwardedBy, awarded.Dtag)] = types.BadgeDefinition{
+			Name:        awarded.Name,
+			Description: awarded.Description,
+			ImageURL:    awarded.ImageURL,
+			ThumbURL:    awarded.ThumbURL,
+			DTag:        awarded.Dtag,
+		}
+		unsaved = true
+	}
 
 	// Create a struct to pass to the template
 	templateData := struct {
-		ProfileBadgesEvents []utils.ProfileBadgesEvent
-		BadgeDefinitions    map[string]types.BadgeDefinition
+		ProfileBadges    []utils.ProfileBadge
+		BadgeDefinitions map[string]types.BadgeDefinition
+		AvailableBadges  []utils.AwardedBadge
+		Awarders         map[string]utils.Awarder
+		Unsaved          bool
 	}{
-		ProfileBadgesEvents: data.ProfileBadges,
-		BadgeDefinitions:    badgeDefinitions,
+		ProfileBadges:    profileBadges,
+		BadgeDefinitions: badgeDefinitions,
+		AvailableBadges:  availableBadges,
+		Awarders:         data.Awarders,
+		Unsaved:          unsaved,
 	}
 
 	err := tmpl.ExecuteTemplate(w, "profileBadges", templateData)
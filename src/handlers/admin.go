@@ -0,0 +1,23 @@
+package handlers
+
+import (
+	"net/http"
+
+	"badger/src/utils"
+)
+
+// RequireAdmin returns the logged in admin's public key, or answers the request and reports false when
+// the user isn't one of admin_pubkeys
+func RequireAdmin(w http.ResponseWriter, r *http.Request) (string, bool) {
+	session, _ := User.Get(r, "session-name")
+	publicKey, ok := session.Values["publicKey"].(string)
+	if !ok || publicKey == "" {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return "", false
+	}
+	if !utils.AppConfig.IsAdmin(publicKey) {
+		http.Error(w, "Forbidden", http.StatusForbidden)
+		return "", false
+	}
+	return publicKey, true
+}
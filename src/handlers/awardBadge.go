@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"badger/src/utils"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// maxRecipientsUpload limits the size of an uploaded recipients CSV file
+const maxRecipientsUpload = 10 << 20
+
+// AwardBadgeHandler constructs unsigned kind 8 badge award events for one of the user's badge definitions,
+// or one of an organization issuer they are a member of, splitting large recipient lists into batches
+func AwardBadgeHandler(w http.ResponseWriter, r *http.Request) {
+	// Check if user is authenticated
+	session, _ := User.Get(r, "session-name")
+	publicKey, ok := session.Values["publicKey"].(string)
+	if !ok || publicKey == "" {
+		utils.Warnf("User not authenticated")
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Fetch the relay list from the session
+	relays, ok := session.Values["relays"].(utils.RelayList)
+	if !ok {
+		utils.Warnf("No relay list found in session")
+		http.Error(w, "Relay list not found", http.StatusInternalServerError)
+		return
+	}
+
+	// Recipients may be posted as a text list, an uploaded CSV file, or both
+	if err := r.ParseMultipartForm(maxRecipientsUpload); err != nil && err != http.ErrNotMultipart {
+		utils.Warnf("Failed to parse form: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	// The badge may be given by its d tag and issuer, or as an naddr
+	dTag := r.FormValue("dtag")
+	issuer := publicKey
+	if value := r.FormValue("issuer"); value != "" {
+		issuer = value
+	}
+	if naddr := r.FormValue("naddr"); naddr != "" {
+		naddrIssuer, naddrDTag, _, err := utils.DecodeBadgeAddress(naddr)
+		if err != nil {
+			utils.Warnf("Rejected badge address %q: %v", naddr, err)
+			http.Error(w, "Invalid badge address", http.StatusBadRequest)
+			return
+		}
+		issuer, dTag = naddrIssuer, naddrDTag
+	}
+
+	// Only the user's own badges and those of issuers they belong to may be awarded
+	issuerRelays, allowed := authorRelays(publicKey, issuer, relays)
+	if !allowed {
+		http.Error(w, "Badge must be one of yours or of an issuer you are a member of", http.StatusForbidden)
+		return
+	}
+	if dTag == "" {
+		utils.Warnf("Badge dtag is missing")
+		http.Error(w, "Badge dtag is required", http.StatusBadRequest)
+		return
+	}
+
+	// The issuer's badge definitions are on their outbox relays (NIP-65)
+	allRelays := issuerRelays.WriteRelays()
+
+	// Make sure the badge definition being awarded was created by the issuer
+	badges, err := utils.FetchCreatedBadges(issuer, allRelays)
+	if err != nil {
+		utils.Errorf("Failed to fetch created badges: %v", err)
+		http.Error(w, "Failed to fetch badges", http.StatusInternalServerError)
+		return
+	}
+	found := false
+	for _, badge := range badges {
+		if badge.DTag == dTag {
+			found = true
+			break
+		}
+	}
+	if !found {
+		utils.Warnf("Badge definition %s not found for %s", dTag, issuer)
+		http.Error(w, "Badge definition not found", http.StatusNotFound)
+		return
+	}
+
+	recipientList := r.FormValue("recipients")
+	if file, _, err := r.FormFile("recipients_file"); err == nil {
+		defer file.Close()
+		content, err := io.ReadAll(file)
+		if err != nil {
+			utils.Warnf("Failed to read recipients file: %v", err)
+			http.Error(w, "Invalid recipients file", http.StatusBadRequest)
+			return
+		}
+		recipientList += "\n" + string(content)
+	}
+
+	recipients, invalid, err := utils.ParseRecipients(recipientList, utils.PreferredRelayHint(issuerRelays))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(invalid) > 0 {
+		http.Error(w, "Invalid recipients: "+strings.Join(invalid, ", "), http.StatusBadRequest)
+		return
+	}
+	if len(recipients) == 0 {
+		http.Error(w, "At least one recipient is required", http.StatusBadRequest)
+		return
+	}
+
+	// Split recipients into several award events so none exceeds the configured "p" tag count
+	batchSize := utils.AppConfig.MaxAwardRecipients
+	if size, err := strconv.Atoi(r.FormValue("batch_size")); err == nil && size > 0 && size < batchSize {
+		batchSize = size
+	}
+
+	var awardEvents []*nostr.Event
+	for _, batch := range utils.BatchRecipients(recipients, batchSize) {
+		awardEvents = append(awardEvents, utils.BuildBadgeAwardEvent(issuer, dTag, batch))
+	}
+
+	// Return the unsigned events to the client
+	response, err := json.Marshal(awardEvents)
+	if err != nil {
+		utils.Errorf("Failed to marshal award events: %v", err)
+		http.Error(w, "Failed to create award events", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(response)
+}
@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"badger/src/utils"
+	"encoding/json"
+	"net/http"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// awardBatchResult reports how the relays received one batch of a badge award
+type awardBatchResult struct {
+	Batch      int                 `json:"batch"`
+	EventID    string              `json:"event_id"`
+	Recipients int                 `json:"recipients"`
+	Accepted   int                 `json:"accepted"`
+	Relays     []utils.RelayResult `json:"relays"`
+}
+
+// AwardSignedBadgesHandler processes the signed badge award events and sends each batch to relays
+func AwardSignedBadgesHandler(w http.ResponseWriter, r *http.Request) {
+	// Decode the signed events from the client
+	var signedEvents []nostr.Event
+	err := json.NewDecoder(r.Body).Decode(&signedEvents)
+	if err != nil {
+		utils.Warnf("Failed to decode signed award events: %v", err)
+		http.Error(w, "Invalid signed event data", http.StatusBadRequest)
+		return
+	}
+
+	// Get the relay list from session
+	session, _ := User.Get(r, "session-name")
+	relayList, ok := session.Values["relays"].(utils.RelayList)
+	if !ok {
+		utils.Warnf("No relay list found in session")
+		http.Error(w, "No relay list found", http.StatusInternalServerError)
+		return
+	}
+
+	// Reject the whole award if any batch isn't a correctly signed kind 8 event from this user, or an
+	// organization issuer they are a member of
+	publicKey, _ := session.Values["publicKey"].(string)
+	issuerRelays := make(map[string]utils.RelayList)
+	for _, signedEvent := range signedEvents {
+		if _, checked := issuerRelays[signedEvent.PubKey]; !checked {
+			authorList, allowed := authorRelays(publicKey, signedEvent.PubKey, relayList)
+			if !allowed {
+				http.Error(w, "You may not award as this issuer", http.StatusForbidden)
+				return
+			}
+			issuerRelays[signedEvent.PubKey] = authorList
+		}
+		if verr := utils.ValidateSignedEvent(signedEvent, signedEvent.PubKey, 8); verr != nil {
+			utils.Warnf("Rejected award event %s: %v", signedEvent.ID, verr)
+			utils.WriteValidationError(w, verr)
+			return
+		}
+	}
+
+	// Send every batch to the issuer's outbox and its recipients' inboxes, recording which relays accepted it
+	var results []awardBatchResult
+	for i, signedEvent := range signedEvents {
+		var recipients []string
+		for _, tag := range signedEvent.Tags.GetAll([]string{"p"}) {
+			recipients = append(recipients, tag.Value())
+		}
+
+		relays := utils.AwardRelays(issuerRelays[signedEvent.PubKey], recipients)
+		result := awardBatchResult{
+			Batch:      i + 1,
+			EventID:    signedEvent.ID,
+			Recipients: len(recipients),
+			Relays:     sendEventToRelays(signedEvent, relays),
+		}
+		utils.ReleaseRelays(relays)
+		result.Accepted = utils.CountAccepted(result.Relays)
+		if signedEvent.PubKey != publicKey {
+			utils.RecordIssuerPublish(signedEvent, publicKey, result.Relays)
+		}
+		results = append(results, result)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
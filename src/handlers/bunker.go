@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+
+	"badger/src/utils"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// BunkerLoginHandler logs in through a NIP-46 remote signer given as a bunker:// URI
+func BunkerLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	uri := r.FormValue("bunker_uri")
+	if uri == "" {
+		http.Error(w, "A bunker:// URI is required", http.StatusBadRequest)
+		return
+	}
+
+	// Waits for the user to approve the connection in their signer
+	bunker, publicKey, err := utils.ConnectBunker(uri)
+	if err != nil {
+		utils.Warnf("Bunker login failed: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+	utils.Debugf("Remote signer connected for publicKey: %s", publicKey)
+
+	if err := startSession(w, r, publicKey, &bunker); err != nil {
+		bunker.Close()
+		utils.Errorf("Login failed: %v", err)
+		http.Error(w, "Failed to start session", http.StatusInternalServerError)
+		return
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// signableKinds are the events the badge pages publish, the only ones signed on the server: badge
+// definitions, awards, profile badges and deletions
+var signableKinds = map[int]bool{30009: true, 8: true, 30008: true, 5: true}
+
+// SignEventHandler signs an unsigned event on the server: as an organization issuer when the event's
+// pubkey is one the user is a member of, otherwise with the session's remote signer. Sessions without
+// one get 409 Conflict, telling the page to sign with the browser extension instead.
+func SignEventHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !requireJSON(w, r) {
+		return
+	}
+
+	session, _ := User.Get(r, "session-name")
+	publicKey, ok := session.Values["publicKey"].(string)
+	if !ok || publicKey == "" {
+		http.Error(w, "Not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	var event nostr.Event
+	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+	if !signableKinds[event.Kind] {
+		http.Error(w, fmt.Sprintf("Badger doesn't sign kind %d events", event.Kind), http.StatusForbidden)
+		return
+	}
+
+	if event.PubKey != "" && event.PubKey != publicKey {
+		if err := utils.SignAsIssuer(&event, publicKey); err != nil {
+			utils.Errorf("Failed to sign as issuer %s for %s: %v", event.PubKey, publicKey, err)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(event)
+		return
+	}
+
+	bunker, ok := session.Values["bunker"].(utils.BunkerSession)
+	if !ok {
+		http.Error(w, "No remote signer in this session", http.StatusConflict)
+		return
+	}
+	event.PubKey = publicKey
+
+	if err := bunker.SignEvent(&event); err != nil {
+		utils.Warnf("Failed to sign with remote signer: %v", err)
+		http.Error(w, err.Error(), http.StatusBadGateway)
+		return
+	}
+	if event.PubKey != publicKey {
+		utils.Warnf("Remote signer signed as %s instead of %s", event.PubKey, publicKey)
+		http.Error(w, "Remote signer signed with a different key", http.StatusBadGateway)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(event)
+}
+
+// requireJSON rejects requests that aren't sent as JSON. Browsers only send that content type cross-site
+// after a CORS preflight Badger never answers, so other sites can't make a logged in user's browser
+// post to the handler.
+func requireJSON(w http.ResponseWriter, r *http.Request) bool {
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		http.Error(w, "Content-Type must be application/json", http.StatusUnsupportedMediaType)
+		return false
+	}
+	return true
+}
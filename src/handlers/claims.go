@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"badger/src/store"
+	"badger/src/utils"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// claimExpiryLayout is the format of the datetime-local input campaigns are given an expiry with
+const claimExpiryLayout = "2006-01-02T15:04"
+
+// CreateClaimCampaignHandler generates claim codes for a badge of an organization issuer the user is a
+// member of
+func CreateClaimCampaignHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session, _ := User.Get(r, "session-name")
+	publicKey, ok := session.Values["publicKey"].(string)
+	if !ok || publicKey == "" {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+	relays, _ := session.Values["relays"].(utils.RelayList)
+
+	naddr := r.FormValue("naddr")
+	issuer, dTag, hints, err := utils.DecodeBadgeAddress(naddr)
+	if err != nil {
+		http.Error(w, "Invalid badge address", http.StatusBadRequest)
+		return
+	}
+	issuerRelays, allowed := authorRelays(publicKey, issuer, relays)
+	if !allowed {
+		http.Error(w, "Badge must be one of an issuer you are a member of", http.StatusForbidden)
+		return
+	}
+	badge, err := utils.FetchBadgeDefinition(issuer, dTag, append(issuerRelays.WriteRelays(), hints...))
+	if err != nil {
+		utils.Warnf("Failed to fetch badge for claim campaign: %v", err)
+		http.Error(w, "Failed to fetch badge", http.StatusBadGateway)
+		return
+	}
+	if badge == nil {
+		http.Error(w, "Badge definition not found", http.StatusNotFound)
+		return
+	}
+
+	codes, err := strconv.Atoi(r.FormValue("codes"))
+	if err != nil {
+		http.Error(w, "Number of codes must be a number", http.StatusBadRequest)
+		return
+	}
+	uses, err := strconv.Atoi(r.FormValue("uses"))
+	if err != nil {
+		http.Error(w, "Uses per code must be a number", http.StatusBadRequest)
+		return
+	}
+	var expiresAt time.Time
+	if value := r.FormValue("expires"); value != "" {
+		expiresAt, err = time.ParseInLocation(claimExpiryLayout, value, time.Local)
+		if err != nil {
+			http.Error(w, "Invalid expiry", http.StatusBadRequest)
+			return
+		}
+	}
+
+	campaign, err := utils.CreateClaimCampaign(publicKey, *badge, codes, uses, expiresAt)
+	if err != nil {
+		utils.Errorf("Failed to create claim campaign: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	utils.Infof("Claim campaign %s with %d codes created by %s for %s", campaign.ID, codes, publicKey, naddr)
+
+	http.Redirect(w, r, "/claims", http.StatusSeeOther)
+}
+
+// DeleteClaimCampaignHandler stops one of the user's claim campaigns
+func DeleteClaimCampaignHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	session, _ := User.Get(r, "session-name")
+	publicKey, ok := session.Values["publicKey"].(string)
+	if !ok || publicKey == "" {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	if err := utils.DeleteClaimCampaign(publicKey, r.FormValue("campaign")); err != nil {
+		utils.Errorf("Failed to delete claim campaign: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, "/claims", http.StatusSeeOther)
+}
+
+// ClaimBadgeHandler awards a campaign's badge to whoever proves their public key: the logged in user or
+// a signed challenge event (NIP-07). Remote signer users log in first, connecting to a signer from this
+// public endpoint would let anyone hold requests open and make Badger dial relays of their choosing.
+func ClaimBadgeHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	claimant, err := claimantPubKey(r)
+	if err != nil {
+		utils.Warnf("Claim rejected: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	code := r.FormValue("code")
+	campaign, err := utils.LookupClaimCode(code)
+	if err != nil {
+		writeClaimError(w, err)
+		return
+	}
+
+	// Awards go to the issuer's outbox and the claimant's inbox (NIP-65)
+	issuerRelays, err := utils.FetchUserRelays(campaign.Issuer, utils.AppConfig.BootstrapRelays)
+	if err != nil {
+		utils.Warnf("Failed to fetch issuer relays: %v", err)
+		issuerRelays = &utils.RelayList{}
+	}
+
+	campaign, award, err := utils.RedeemClaim(code, claimant, utils.PreferredRelayHint(*issuerRelays))
+	if err != nil {
+		writeClaimError(w, err)
+		return
+	}
+
+	relays := utils.AwardRelays(*issuerRelays, []string{claimant})
+	defer utils.ReleaseRelays(relays)
+	results := sendEventToRelays(award, relays)
+	utils.FinishClaim(campaign, claimant, award, results)
+
+	status := "Badge claimed"
+	if utils.CountAccepted(results) == 0 {
+		status = "Claim failed, try again"
+	}
+	writePublishResults(w, r, status, results)
+}
+
+// claimantPubKey returns the public key a claim is made for
+func claimantPubKey(r *http.Request) (string, error) {
+	if value := r.FormValue("event"); value != "" {
+		var event nostr.Event
+		if err := json.Unmarshal([]byte(value), &event); err != nil {
+			return "", errors.New("invalid claim event")
+		}
+		if err := verifyLoginEvent(r, event, "/claim-badge"); err != nil {
+			return "", err
+		}
+		return event.PubKey, nil
+	}
+
+	session, _ := User.Get(r, "session-name")
+	if publicKey, ok := session.Values["publicKey"].(string); ok && publicKey != "" {
+		return publicKey, nil
+	}
+	return "", errors.New("sign in to claim this badge")
+}
+
+func writeClaimError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, store.ErrClaimNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, store.ErrClaimExpired):
+		http.Error(w, err.Error(), http.StatusGone)
+	case errors.Is(err, store.ErrClaimUsedUp), errors.Is(err, store.ErrAlreadyClaimed), errors.Is(err, store.ErrClaimInProgress):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		utils.Errorf("Failed to claim badge: %v", err)
+		http.Error(w, "Failed to claim badge", http.StatusInternalServerError)
+	}
+}
@@ -2,14 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
-	"log"
 	"net/http"
 
 	"badger/src/utils" // Import the utils package to use RelayList
 
 	"github.com/nbd-wtf/go-nostr"
-	"golang.org/x/net/websocket"
 )
 
 func CreateBadgeHandler(w http.ResponseWriter, r *http.Request) {
@@ -18,85 +15,53 @@ func CreateBadgeHandler(w http.ResponseWriter, r *http.Request) {
 	// Fetch the relay list from the session
 	relays, ok := session.Values["relays"].(utils.RelayList)
 	if !ok {
-		log.Println("No relay list found in session")
+		utils.Warnf("No relay list found in session")
 		http.Error(w, "Relay list not found", http.StatusInternalServerError)
 		return
 	}
 
-	// Combine all user relays (read, write, both)
-	allRelays := append(relays.Read, relays.Write...)
-	allRelays = append(allRelays, relays.Both...)
-
 	var event nostr.Event
 	if err := json.NewDecoder(r.Body).Decode(&event); err != nil {
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
 
-	// Send the event to the user's relays
-	sendEventToRelays(event, allRelays)
-
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "badge sent"})
-}
-
-func sendEventToRelays(event nostr.Event, relayURLs []string) {
-	for _, relayURL := range relayURLs {
-		go func(relayURL string) {
-			ws, err := websocket.Dial(relayURL, "", "http://localhost/")
-			if err != nil {
-				log.Printf("Failed to connect to relay %s: %v", relayURL, err)
-				return
-			}
-			defer ws.Close()
-
-			// Prepare the event message
-			eventMessage := []interface{}{"EVENT", event}
-
-			// Marshal the event message to JSON
-			eventMessageJSON, err := json.Marshal(eventMessage)
-			if err != nil {
-				log.Printf("Failed to marshal event message: %v", err)
-				return
-			}
-
-			// Send the event message
-			err = websocket.Message.Send(ws, string(eventMessageJSON))
-			if err != nil {
-				log.Printf("Failed to send event to relay %s: %v", relayURL, err)
-				return
-			}
-
-			// Wait for a response from the relay
-			var response string
-			err = websocket.Message.Receive(ws, &response)
-			if err != nil {
-				log.Printf("Failed to receive response from relay %s: %v", relayURL, err)
-				return
-			}
+	// Organization issuers publish to their own outbox relays (NIP-65), users to theirs
+	publicKey, _ := session.Values["publicKey"].(string)
+	authorList, allowed := authorRelays(publicKey, event.PubKey, relays)
+	if !allowed {
+		http.Error(w, "You may not publish as this issuer", http.StatusForbidden)
+		return
+	}
+	allRelays := authorList.WriteRelays()
 
-			// Parse the response
-			var responseArray []interface{}
-			err = json.Unmarshal([]byte(response), &responseArray)
-			if err != nil {
-				log.Printf("Failed to parse response from relay %s: %v", relayURL, err)
-				return
-			}
+	// Reject anything that isn't a correctly signed badge definition from this user or issuer
+	if verr := utils.ValidateSignedEvent(event, event.PubKey, 30009); verr != nil {
+		utils.Warnf("Rejected badge definition event: %v", verr)
+		utils.WriteValidationError(w, verr)
+		return
+	}
 
-			// Check if the response is valid and handle it
-			if len(responseArray) >= 4 && responseArray[0] == "OK" {
-				eventID := responseArray[1].(string)
-				success := responseArray[2].(bool)
-				message := responseArray[3].(string)
+	// Send the event to the user's relays and report how each one answered
+	results := sendEventToRelays(event, allRelays)
+	if event.PubKey != publicKey {
+		utils.RecordIssuerPublish(event, publicKey, results)
+	}
+	writePublishResults(w, r, "badge sent", results)
+}
 
-				if success {
-					fmt.Printf("Event %s accepted by relay %s: %s\n", eventID, relayURL, message)
-				} else {
-					fmt.Printf("Event %s rejected by relay %s: %s\n", eventID, relayURL, message)
-				}
-			} else {
-				log.Printf("Unexpected response from relay %s: %v", relayURL, responseArray)
-			}
-		}(relayURL)
+// sendEventToRelays publishes the event and waits for every relay's OK, logging rejections
+func sendEventToRelays(event nostr.Event, relayURLs []string) []utils.RelayResult {
+	results := utils.PublishEvent(event, relayURLs)
+	if utils.CountAccepted(results) > 0 {
+		utils.StoreEvent(event)
+	}
+	for _, result := range results {
+		if result.Accepted {
+			utils.Debugf("Event %s accepted by relay %s: %s", event.ID, result.Relay, result.Message)
+		} else {
+			utils.Debugf("Event %s %s by relay %s: %s", event.ID, result.Status, result.Relay, result.Message)
+		}
 	}
+	return results
 }
@@ -2,10 +2,11 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 	"time"
 
+	"badger/src/utils"
+
 	"github.com/nbd-wtf/go-nostr"
 )
 
@@ -15,7 +16,7 @@ func DeleteBadgeHandler(w http.ResponseWriter, r *http.Request) {
 	session, _ := User.Get(r, "session-name")
 	publicKey, ok := session.Values["publicKey"].(string)
 	if !ok || publicKey == "" {
-		log.Println("Error: User not authenticated")
+		utils.Warnf("User not authenticated")
 		http.Redirect(w, r, "/login", http.StatusSeeOther)
 		return
 	}
@@ -23,14 +24,32 @@ func DeleteBadgeHandler(w http.ResponseWriter, r *http.Request) {
 	// Extract badge ID from request
 	badgeID := r.URL.Query().Get("badge_id")
 	if badgeID == "" {
-		log.Println("Error: Badge ID is missing")
+		utils.Warnf("Badge ID is missing")
 		http.Error(w, "Badge ID is required", http.StatusBadRequest)
 		return
 	}
 
+	// The ID may be hex, a note or an nevent
+	badgeID, _, err := utils.DecodeEventID(badgeID)
+	if err != nil {
+		utils.Warnf("Rejected badge ID: %v", err)
+		http.Error(w, "Invalid badge ID", http.StatusBadRequest)
+		return
+	}
+
+	// Badges of an organization issuer are deleted by the issuer, which signs on the server
+	author := publicKey
+	if issuer := r.URL.Query().Get("issuer"); issuer != "" && issuer != publicKey {
+		if !utils.CanIssueAs(publicKey, issuer) {
+			http.Error(w, "You may not delete badges of this issuer", http.StatusForbidden)
+			return
+		}
+		author = issuer
+	}
+
 	// Create an unsigned deletion event (NIP-09)
 	deletionEvent := &nostr.Event{
-		PubKey:    publicKey,
+		PubKey:    author,
 		CreatedAt: nostr.Timestamp(time.Now().Unix()),
 		Kind:      5, // Deletion event kind (NIP-09)
 		Tags: nostr.Tags{
@@ -42,7 +61,7 @@ func DeleteBadgeHandler(w http.ResponseWriter, r *http.Request) {
 	// Return the unsigned event to the client
 	response, err := json.Marshal(deletionEvent)
 	if err != nil {
-		log.Printf("Failed to marshal deletion event: %v", err)
+		utils.Errorf("Failed to marshal deletion event: %v", err)
 		http.Error(w, "Failed to create deletion event", http.StatusInternalServerError)
 		return
 	}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"badger/src/store"
+	"badger/src/types"
+	"badger/src/utils"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// testIssuerMember sets up sessions and an organization issuer with one member, returning the issuer's
+// public key and the member's
+func testIssuerMember(t *testing.T) (issuer, member string) {
+	t.Helper()
+	db := testSessions(t)
+	savedStore, savedConfig := store.Default, *utils.AppConfig
+	t.Cleanup(func() {
+		store.Default = savedStore
+		*utils.AppConfig = savedConfig
+	})
+	store.Default = db
+	utils.AppConfig.IssuerKeyPassword = "correct horse battery"
+	utils.AppConfig.BootstrapRelays = nil
+	utils.AppConfig.FallbackRelays = nil
+
+	imported, err := utils.ImportIssuer("Org", nostr.GeneratePrivateKey(), "", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	member, _ = nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	if err := utils.SetIssuerMember(imported.PubKey, member, true, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	return imported.PubKey, member
+}
+
+func TestDeleteBadgeAsIssuer(t *testing.T) {
+	issuer, member := testIssuerMember(t)
+	outsider, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	badgeID := nostr.GeneratePrivateKey() // Any 32 byte hex id
+
+	tests := []struct {
+		name      string
+		publicKey string
+		issuer    string
+		status    int
+		author    string
+	}{
+		{"own badge", member, "", http.StatusOK, member},
+		{"own key as issuer", member, member, http.StatusOK, member},
+		{"member of the issuer", member, issuer, http.StatusOK, issuer},
+		{"not a member", outsider, issuer, http.StatusForbidden, ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r, _ := loggedIn(t, test.publicKey)
+			r.Method = http.MethodGet
+			r.URL.RawQuery = "badge_id=" + badgeID + "&issuer=" + test.issuer
+			w := httptest.NewRecorder()
+			DeleteBadgeHandler(w, r)
+
+			if w.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+			if test.status != http.StatusOK {
+				return
+			}
+			var event nostr.Event
+			if err := json.Unmarshal(w.Body.Bytes(), &event); err != nil {
+				t.Fatal(err)
+			}
+			if event.PubKey != test.author || event.Kind != 5 {
+				t.Fatalf("deletion by %s of kind %d, want kind 5 by %s", event.PubKey, event.Kind, test.author)
+			}
+		})
+	}
+}
+
+func TestDeleteSignedBadgeAsIssuer(t *testing.T) {
+	issuer, member := testIssuerMember(t)
+	outsider, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+
+	// Issuer keys only delete events the issuer published
+	definition := nostr.Event{PubKey: issuer, Kind: 30009, CreatedAt: nostr.Now(), Tags: nostr.Tags{{"d", "badge"}, {"name", "Badge"}}}
+	if err := utils.SignAsIssuer(&definition, member); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Default.Save(types.FromNostrEvent(definition)); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		publicKey string
+		status    int
+	}{
+		{"member of the issuer", member, http.StatusOK},
+		{"not a member", outsider, http.StatusForbidden},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			deletion := nostr.Event{PubKey: issuer, Kind: 5, CreatedAt: nostr.Now(), Tags: nostr.Tags{{"e", definition.ID}}}
+			if err := utils.SignAsIssuer(&deletion, member); err != nil {
+				t.Fatal(err)
+			}
+			body, _ := json.Marshal(deletion)
+
+			r, _ := loggedIn(t, test.publicKey)
+			session, _ := User.Get(r, "session-name")
+			session.Values["relays"] = utils.RelayList{}
+			saved := httptest.NewRecorder()
+			if err := session.Save(r, saved); err != nil {
+				t.Fatal(err)
+			}
+
+			r = httptest.NewRequest(http.MethodPost, "/delete-signed-badge", bytes.NewReader(body))
+			r.AddCookie(saved.Result().Cookies()[0])
+			w := httptest.NewRecorder()
+			DeleteSignedBadgeHandler(w, r)
+			if w.Code != test.status {
+				t.Fatalf("status = %d, want %d: %s", w.Code, test.status, w.Body)
+			}
+		})
+	}
+}
@@ -3,8 +3,6 @@ package handlers
 import (
 	"badger/src/utils"
 	"encoding/json"
-	"fmt"
-	"log"
 	"net/http"
 
 	"github.com/nbd-wtf/go-nostr"
@@ -16,7 +14,7 @@ func DeleteSignedBadgeHandler(w http.ResponseWriter, r *http.Request) {
 	var signedEvent nostr.Event
 	err := json.NewDecoder(r.Body).Decode(&signedEvent)
 	if err != nil {
-		log.Printf("Failed to decode signed deletion event: %v", err)
+		utils.Warnf("Failed to decode signed deletion event: %v", err)
 		http.Error(w, "Invalid signed event data", http.StatusBadRequest)
 		return
 	}
@@ -25,27 +23,31 @@ func DeleteSignedBadgeHandler(w http.ResponseWriter, r *http.Request) {
 	session, _ := User.Get(r, "session-name")
 	relayList, ok := session.Values["relays"].(utils.RelayList)
 	if !ok {
-		log.Println("Error: No relay list found in session or incorrect type")
+		utils.Warnf("No relay list found in session")
 		http.Error(w, "No relay list found", http.StatusInternalServerError)
 		return
 	}
 
-	// Combine all relays (Read, Write, Both) into a single slice
-	allRelays := append(relayList.Read, relayList.Write...)
-	allRelays = append(allRelays, relayList.Both...)
+	// Organization issuers publish to their own outbox relays (NIP-65), users to theirs
+	publicKey, _ := session.Values["publicKey"].(string)
+	authorList, allowed := authorRelays(publicKey, signedEvent.PubKey, relayList)
+	if !allowed {
+		http.Error(w, "You may not delete as this issuer", http.StatusForbidden)
+		return
+	}
+	allRelays := authorList.WriteRelays()
 
-	// Send the signed deletion event to all relays
-	for _, relay := range allRelays {
-		err := utils.SendToRelay(relay, signedEvent)
-		if err != nil {
-			log.Printf("Failed to send deletion event to relay %s: %v", relay, err)
-			http.Error(w, fmt.Sprintf("Failed to broadcast deletion event to relay: %s", relay), http.StatusInternalServerError)
-			return
-		}
+	// Reject anything that isn't a correctly signed deletion event from this user or issuer
+	if verr := utils.ValidateSignedEvent(signedEvent, signedEvent.PubKey, 5); verr != nil {
+		utils.Warnf("Rejected deletion event: %v", verr)
+		utils.WriteValidationError(w, verr)
+		return
 	}
 
-	// Respond with success
-	response := map[string]string{"status": "success", "message": "Signed badge deletion event broadcasted successfully"}
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	// Send the signed deletion event to all relays and report how each one answered
+	results := sendEventToRelays(signedEvent, allRelays)
+	if signedEvent.PubKey != publicKey {
+		utils.RecordIssuerPublish(signedEvent, publicKey, results)
+	}
+	writePublishResults(w, r, "badge deleted", results)
 }
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"net/http"
+
+	"badger/src/utils"
+)
+
+// authorRelays returns the relay list to publish an event by author with: the session's list for the
+// user's own events, or the issuer's NIP-65 list for an organization issuer the user is a member of
+func authorRelays(publicKey, author string, relays utils.RelayList) (utils.RelayList, bool) {
+	if author == publicKey {
+		return relays, true
+	}
+	if !utils.CanIssueAs(publicKey, author) {
+		return utils.RelayList{}, false
+	}
+
+	issuerRelays, err := utils.FetchUserRelays(author, utils.AppConfig.BootstrapRelays)
+	if err != nil {
+		// An empty list falls back to the bootstrap relays
+		utils.Warnf("Failed to fetch issuer relays: %v", err)
+		return utils.RelayList{}, true
+	}
+	return *issuerRelays, true
+}
+
+// AdminIssuersHandler lets an admin import organization issuer keys and manage who may use them
+func AdminIssuersHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	admin, ok := RequireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	issuer := r.FormValue("issuer")
+	var err error
+	switch r.FormValue("action") {
+	case "import":
+		// The submitted key is never logged or stored unencrypted
+		_, err = utils.ImportIssuer(r.FormValue("name"), r.FormValue("key"), r.FormValue("key_password"), admin)
+	case "add-member", "remove-member":
+		member, _, decodeErr := utils.DecodePubKey(r.FormValue("member"))
+		if decodeErr != nil {
+			http.Error(w, decodeErr.Error(), http.StatusBadRequest)
+			return
+		}
+		err = utils.SetIssuerMember(issuer, member, r.FormValue("action") == "add-member", admin)
+	case "delete":
+		err = utils.DeleteIssuer(issuer, admin)
+	default:
+		http.Error(w, "Unknown action", http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		utils.Warnf("Issuer %s failed: %v", r.FormValue("action"), err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/issuers", http.StatusSeeOther)
+}
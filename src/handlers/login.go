@@ -2,94 +2,113 @@ package handlers
 
 import (
 	"encoding/gob"
+	"encoding/json"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
 
 	"badger/src/utils"
 
 	"github.com/gorilla/sessions"
+	"github.com/nbd-wtf/go-nostr"
 )
 
-var User = sessions.NewCookieStore([]byte("your-secret-key"))
-
 func init() {
-	// Register the RelayList type with gob
+	// Register the session value types with gob
 	gob.Register(utils.RelayList{})
+	gob.Register(utils.BunkerSession{})
 }
 
 func LoginHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("LoginHandler called")
+	utils.Debugf("LoginHandler called")
 
-	if err := r.ParseForm(); err != nil {
-		log.Printf("Failed to parse form: %v\n", err)
+	// The browser proves it owns the pubkey by signing the challenge issued by LoginChallengeHandler
+	var loginEvent nostr.Event
+	if err := json.NewDecoder(r.Body).Decode(&loginEvent); err != nil {
+		utils.Warnf("Failed to decode login event: %v", err)
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
 
-	publicKey := r.FormValue("publicKey")
-	if publicKey == "" {
-		log.Println("Missing publicKey in form data")
-		http.Error(w, "Missing publicKey", http.StatusBadRequest)
+	if err := verifyLoginEvent(r, loginEvent, "/do-login"); err != nil {
+		utils.Warnf("Login verification failed: %v", err)
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	publicKey := loginEvent.PubKey
+	utils.Debugf("Verified publicKey: %s", publicKey)
+
+	if err := startSession(w, r, publicKey, nil); err != nil {
+		utils.Errorf("Login failed: %v", err)
+		http.Error(w, "Failed to start session", http.StatusInternalServerError)
 		return
 	}
 
-	log.Printf("Received publicKey: %s\n", publicKey)
+	// Redirect to the root ("/")
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+	utils.Debugf("Redirecting to /")
+}
 
+// startSession loads the user's relays and profile into a new session. Users who logged in with a
+// remote signer (NIP-46) also keep the bunker connection details, to sign on the server.
+func startSession(w http.ResponseWriter, r *http.Request, publicKey string, bunker *utils.BunkerSession) error {
 	// Log the public key to a file
 	logPublicKey(publicKey)
 
-	// Fetch user relay list from an initial relay
-	initialRelays := []string{
-		"wss://purplepag.es", "wss://relay.damus.io", "wss://nos.lol", "wss://relay.primal.net", "wss://relay.nostr.band", "wss://offchain.pub", // Add any initial relay URLs here
-	}
-	userRelays, err := utils.FetchUserRelays(publicKey, initialRelays)
+	// Fetch user relay list from the bootstrap relays
+	userRelays, err := utils.FetchUserRelays(publicKey, utils.AppConfig.BootstrapRelays)
 	if err != nil {
-		log.Printf("Failed to fetch user relays: %v\n", err)
-		http.Error(w, "Failed to fetch user relays", http.StatusInternalServerError)
-		return
+		return fmt.Errorf("failed to fetch user relays: %v", err)
 	}
-	log.Printf("Fetched user relays: %+v\n", userRelays)
+	utils.Debugf("Fetched user relays: %+v", userRelays)
 
-	// Combine all relays (read, write, both) into a single slice
-	allRelays := append(userRelays.Read, userRelays.Write...)
-	allRelays = append(allRelays, userRelays.Both...)
-
-	// Fetch user metadata from the combined relay list
-	userContent, err := utils.FetchUserMetadata(publicKey, allRelays)
+	// Fetch user metadata from the user's outbox relays
+	userContent, err := utils.FetchUserMetadata(publicKey, userRelays.WriteRelays())
 	if err != nil {
-		log.Printf("Failed to fetch user metadata: %v\n", err)
-		http.Error(w, "Failed to fetch user metadata", http.StatusInternalServerError)
-		return
+		return fmt.Errorf("failed to fetch user metadata: %v", err)
 	}
-	log.Printf("Fetched user metadata: %+v\n", userContent)
+	utils.Debugf("Fetched user metadata: %+v", userContent)
 
 	// Store the public key, user data, and relays in the session
 	session, _ := User.Get(r, "session-name")
+	if err := renewSession(session); err != nil {
+		return err
+	}
 	session.Values["publicKey"] = publicKey
 	session.Values["displayName"] = userContent.DisplayName
 	session.Values["picture"] = userContent.Picture
 	session.Values["about"] = userContent.About
 	session.Values["relays"] = userRelays // Store the relay list categorized by read, write, and both
+	if bunker != nil {
+		session.Values["bunker"] = *bunker
+	}
 	if err := session.Save(r, w); err != nil {
-		log.Printf("Failed to save session: %v\n", err)
-		http.Error(w, "Failed to save session", http.StatusInternalServerError)
-		return
+		return fmt.Errorf("failed to save session: %v", err)
 	}
 
-	log.Println("Session saved successfully")
+	utils.Debugf("Session saved successfully")
+	return nil
+}
 
-	// Redirect to the root ("/")
-	http.Redirect(w, r, "/", http.StatusSeeOther)
-	log.Println("Redirecting to /")
+// renewSession ends the session a login replaces, closing its remote signer, and leaves an empty
+// session that gets a fresh id when saved
+func renewSession(session *sessions.Session) error {
+	if session.ID != "" {
+		if err := User.Revoke(sessionKey(session.ID)); err != nil {
+			return fmt.Errorf("failed to end previous session: %v", err)
+		}
+	}
+	session.ID = ""
+	session.Values = make(map[interface{}]interface{})
+	return nil
 }
 
 // logPublicKey logs the public key to a text file in the logs directory, avoiding duplicates
 func logPublicKey(publicKey string) {
 	// Create logs directory if it doesn't exist
 	if err := os.MkdirAll("logs", os.ModePerm); err != nil {
-		log.Printf("Error creating logs directory: %v\n", err)
+		utils.Errorf("Error creating logs directory: %v", err)
 		return
 	}
 
@@ -98,26 +117,26 @@ func logPublicKey(publicKey string) {
 	// Read existing keys to check for duplicates
 	existingKeys, err := readExistingKeys(logFilePath)
 	if err != nil {
-		log.Printf("Error reading existing keys: %v\n", err)
+		utils.Errorf("Error reading existing keys: %v", err)
 		return
 	}
 
 	// Check if the public key already exists
 	if _, exists := existingKeys[publicKey]; exists {
-		log.Printf("Public key already logged: %s\n", publicKey)
+		utils.Debugf("Public key already logged: %s", publicKey)
 		return
 	}
 
 	// Open the log file in the logs directory
 	file, err := os.OpenFile(logFilePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
 	if err != nil {
-		log.Printf("Error opening log file: %v\n", err)
+		utils.Errorf("Error opening log file: %v", err)
 		return
 	}
 	defer file.Close()
 
 	if _, err := file.WriteString(fmt.Sprintf("%s\n", publicKey)); err != nil {
-		log.Printf("Error writing to log file: %v\n", err)
+		utils.Errorf("Error writing to log file: %v", err)
 	}
 }
 
@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"badger/src/utils"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// challengeTTL is how long an issued login challenge can be answered
+const challengeTTL = 5 * time.Minute
+
+// Login challenges issued to browsers, each may only be used once
+var loginChallenges = struct {
+	sync.Mutex
+	data map[string]time.Time
+}{
+	data: make(map[string]time.Time),
+}
+
+// LoginChallengeHandler issues a random nonce that the browser must sign to prove it owns a pubkey
+func LoginChallengeHandler(w http.ResponseWriter, r *http.Request) {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		utils.Errorf("Failed to generate login challenge: %v", err)
+		http.Error(w, "Failed to generate challenge", http.StatusInternalServerError)
+		return
+	}
+	challenge := hex.EncodeToString(nonce)
+
+	loginChallenges.Lock()
+	// Drop expired challenges so the map doesn't grow forever
+	for c, issued := range loginChallenges.data {
+		if time.Since(issued) > challengeTTL {
+			delete(loginChallenges.data, c)
+		}
+	}
+	loginChallenges.data[challenge] = time.Now()
+	loginChallenges.Unlock()
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"challenge": challenge})
+}
+
+// verifyLoginEvent checks that a signed kind 22242 (NIP-42) or kind 27235 (NIP-98) event answers an
+// unused challenge, names the endpoint it was made for in its "u" and "method" tags (as NIP-98 does) and
+// is correctly signed by its pubkey
+func verifyLoginEvent(r *http.Request, event nostr.Event, path string) error {
+	if event.Kind != 22242 && event.Kind != 27235 {
+		return errors.New("login event must be kind 22242 or 27235")
+	}
+
+	challengeTag := event.Tags.GetFirst([]string{"challenge", ""})
+	if challengeTag == nil {
+		return errors.New("login event is missing the challenge tag")
+	}
+	challenge := challengeTag.Value()
+
+	loginChallenges.Lock()
+	issued, found := loginChallenges.data[challenge]
+	delete(loginChallenges.data, challenge) // Challenges are single use
+	loginChallenges.Unlock()
+	if !found || time.Since(issued) > challengeTTL {
+		return errors.New("unknown or expired challenge")
+	}
+
+	if time.Since(event.CreatedAt.Time()).Abs() > challengeTTL {
+		return errors.New("login event timestamp is out of range")
+	}
+
+	// An event signed for one endpoint can't be used on another
+	if tag := event.Tags.GetFirst([]string{"u", ""}); tag == nil || tag.Value() != utils.AppConfig.BaseURL(r)+path {
+		return errors.New("login event u tag does not match the request")
+	}
+	if tag := event.Tags.GetFirst([]string{"method", ""}); tag == nil || !strings.EqualFold(tag.Value(), http.MethodPost) {
+		return errors.New("login event method tag must be POST")
+	}
+
+	if event.GetID() != event.ID {
+		return errors.New("login event id does not match its content")
+	}
+	if ok, err := event.CheckSignature(); err != nil || !ok {
+		return errors.New("invalid login event signature")
+	}
+
+	return nil
+}
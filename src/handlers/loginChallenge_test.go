@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// issueChallenge asks LoginChallengeHandler for a fresh challenge
+func issueChallenge(t *testing.T) string {
+	t.Helper()
+	recorder := httptest.NewRecorder()
+	LoginChallengeHandler(recorder, httptest.NewRequest(http.MethodGet, "/login-challenge", nil))
+	var body struct{ Challenge string }
+	if err := json.NewDecoder(recorder.Body).Decode(&body); err != nil || body.Challenge == "" {
+		t.Fatalf("no challenge issued: %v", err)
+	}
+	return body.Challenge
+}
+
+func TestVerifyLoginEvent(t *testing.T) {
+	secretKey := nostr.GeneratePrivateKey()
+
+	// loginEvent answers a new challenge, change lets a case break it before or after signing
+	loginEvent := func(change func(event *nostr.Event), afterSigning func(event *nostr.Event)) nostr.Event {
+		event := nostr.Event{
+			Kind:      22242,
+			CreatedAt: nostr.Now(),
+			Tags: nostr.Tags{
+				{"challenge", issueChallenge(t)},
+				{"u", "http://badger.test/do-login"},
+				{"method", "POST"},
+			},
+		}
+		if change != nil {
+			change(&event)
+		}
+		if err := event.Sign(secretKey); err != nil {
+			t.Fatal(err)
+		}
+		if afterSigning != nil {
+			afterSigning(&event)
+		}
+		return event
+	}
+	setTag := func(name, value string) func(event *nostr.Event) {
+		return func(event *nostr.Event) {
+			for i, tag := range event.Tags {
+				if tag[0] == name {
+					event.Tags[i] = nostr.Tag{name, value}
+				}
+			}
+		}
+	}
+
+	tests := []struct {
+		name  string
+		event nostr.Event
+		path  string
+		valid bool
+	}{
+		{"valid", loginEvent(nil, nil), "/do-login", true},
+		{"nip-98 kind", loginEvent(func(e *nostr.Event) { e.Kind = 27235 }, nil), "/do-login", true},
+		{"lowercase method", loginEvent(setTag("method", "post"), nil), "/do-login", true},
+		{"other endpoint", loginEvent(nil, nil), "/claim-badge", false},
+		{"other host", loginEvent(setTag("u", "http://evil.test/do-login"), nil), "/do-login", false},
+		{"get method", loginEvent(setTag("method", "GET"), nil), "/do-login", false},
+		{"no u tag", loginEvent(func(e *nostr.Event) { e.Tags = e.Tags[:1] }, nil), "/do-login", false},
+		{"other kind", loginEvent(func(e *nostr.Event) { e.Kind = 1 }, nil), "/do-login", false},
+		{"unknown challenge", loginEvent(setTag("challenge", "made up"), nil), "/do-login", false},
+		{"expired", loginEvent(func(e *nostr.Event) { e.CreatedAt -= nostr.Timestamp(challengeTTL/time.Second + 60) }, nil), "/do-login", false},
+		{"tampered", loginEvent(nil, func(e *nostr.Event) { e.Content = "changed" }), "/do-login", false},
+		{"other signer", loginEvent(nil, func(e *nostr.Event) {
+			e.PubKey, _ = nostr.GetPublicKey(nostr.GeneratePrivateKey())
+			e.ID = e.GetID()
+		}), "/do-login", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "http://badger.test"+test.path, nil)
+			if err := verifyLoginEvent(r, test.event, test.path); (err == nil) != test.valid {
+				t.Fatalf("verifyLoginEvent = %v, want valid %v", err, test.valid)
+			}
+		})
+	}
+}
+
+func TestLoginChallengeIsSingleUse(t *testing.T) {
+	event := nostr.Event{
+		Kind:      22242,
+		CreatedAt: nostr.Now(),
+		Tags:      nostr.Tags{{"challenge", issueChallenge(t)}, {"u", "http://badger.test/do-login"}, {"method", "POST"}},
+	}
+	event.Sign(nostr.GeneratePrivateKey())
+
+	r := httptest.NewRequest(http.MethodPost, "http://badger.test/do-login", nil)
+	if err := verifyLoginEvent(r, event, "/do-login"); err != nil {
+		t.Fatal(err)
+	}
+	if err := verifyLoginEvent(r, event, "/do-login"); err == nil {
+		t.Fatal("the same challenge was accepted twice")
+	}
+}
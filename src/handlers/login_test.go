@@ -0,0 +1,92 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"badger/src/store"
+	"badger/src/utils"
+)
+
+// testSessions sets up User on a fresh store
+func testSessions(t *testing.T) *store.Store {
+	t.Helper()
+	db, err := store.Open(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	cfg := utils.DefaultConfig()
+	cfg.SessionSecret = strings.Repeat("s", 32)
+	if err := ConfigureSessions(cfg, db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// loggedIn saves a session for publicKey and returns a request carrying its cookie
+func loggedIn(t *testing.T, publicKey string) (*http.Request, string) {
+	t.Helper()
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	recorder := httptest.NewRecorder()
+	session, _ := User.Get(r, "session-name")
+	session.Values["publicKey"] = publicKey
+	session.Values["issuer"] = "org"
+	if err := session.Save(r, recorder); err != nil {
+		t.Fatal(err)
+	}
+
+	next := httptest.NewRequest(http.MethodPost, "/do-login", nil)
+	next.AddCookie(recorder.Result().Cookies()[0])
+	return next, session.ID
+}
+
+func TestRenewSessionEndsThePreviousLogin(t *testing.T) {
+	db := testSessions(t)
+
+	tests := []struct {
+		name     string
+		loggedIn bool
+	}{
+		{"previous login", true},
+		{"no previous login", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/do-login", nil)
+			var oldID string
+			if test.loggedIn {
+				r, oldID = loggedIn(t, "old")
+			}
+
+			session, _ := User.Get(r, "session-name")
+			if err := renewSession(session); err != nil {
+				t.Fatal(err)
+			}
+			if session.ID != "" || len(session.Values) != 0 {
+				t.Fatalf("session kept id %q and values %v", session.ID, session.Values)
+			}
+			if oldID != "" {
+				if _, found, _ := db.LoadSession(sessionKey(oldID)); found {
+					t.Fatal("the previous session is still stored")
+				}
+			}
+
+			session.Values["publicKey"] = "new"
+			recorder := httptest.NewRecorder()
+			if err := session.Save(r, recorder); err != nil {
+				t.Fatal(err)
+			}
+			if session.ID == "" || session.ID == oldID {
+				t.Fatalf("login did not get a fresh session id")
+			}
+			if _, found, _ := db.LoadSession(sessionKey(session.ID)); !found {
+				t.Fatal("the new session was not stored")
+			}
+		})
+	}
+}
@@ -1,15 +1,13 @@
 package handlers
 
 import (
-	"log"
 	"net/http"
-)
 
-// Assuming User is defined elsewhere, like in login.go
-// var User = sessions.NewCookieStore([]byte("your-secret-key"))
+	"badger/src/utils"
+)
 
 func LogoutHandler(w http.ResponseWriter, r *http.Request) {
-	log.Println("LogoutHandler called")
+	utils.Debugf("LogoutHandler called")
 
 	// Retrieve the session
 	session, _ := User.Get(r, "session-name")
@@ -20,14 +18,45 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 
 	// Save the session to commit the changes
 	if err := session.Save(r, w); err != nil {
-		log.Printf("Failed to clear session: %v\n", err)
+		utils.Errorf("Failed to clear session: %v", err)
 		http.Error(w, "Failed to logout", http.StatusInternalServerError)
 		return
 	}
 
-	log.Println("Session cleared successfully")
+	utils.Debugf("Session cleared successfully")
 
 	// Redirect to the root ("/")
 	http.Redirect(w, r, "/", http.StatusSeeOther)
-	log.Println("Redirecting to / after logout")
+	utils.Debugf("Redirecting to / after logout")
+}
+
+// LogoutEverywhereHandler ends every session of the logged in user, on all of their devices
+func LogoutEverywhereHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	session, _ := User.Get(r, "session-name")
+	publicKey, ok := session.Values["publicKey"].(string)
+	if !ok || publicKey == "" {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	removed, err := User.LogoutEverywhere(publicKey)
+	if err != nil {
+		utils.Errorf("Failed to remove sessions: %v", err)
+		http.Error(w, "Failed to logout", http.StatusInternalServerError)
+		return
+	}
+	utils.Debugf("Removed %d sessions of %s", removed, publicKey)
+
+	// The session is already gone from the store, this only clears the cookie
+	session.Options.MaxAge = -1
+	if err := session.Save(r, w); err != nil {
+		utils.Errorf("Failed to clear session: %v", err)
+	}
+
+	http.Redirect(w, r, "/", http.StatusSeeOther)
 }
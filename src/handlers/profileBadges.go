@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"badger/src/utils"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// profileBadgeEntry is one badge of the edited profile, in display order. "a" may also be an naddr and
+// "e" a note or nevent, whose first relay hint is used when no relay is given.
+type profileBadgeEntry struct {
+	ATag  string `json:"a"`
+	EID   string `json:"e"`
+	Relay string `json:"relay"`
+}
+
+// ProfileBadgesHandler constructs an unsigned kind 30008 event from the user's edited list of profile badges
+func ProfileBadgesHandler(w http.ResponseWriter, r *http.Request) {
+	// Check if user is authenticated
+	session, _ := User.Get(r, "session-name")
+	publicKey, ok := session.Values["publicKey"].(string)
+	if !ok || publicKey == "" {
+		utils.Warnf("User not authenticated")
+		http.Error(w, "User not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	var entries []profileBadgeEntry
+	if err := json.NewDecoder(r.Body).Decode(&entries); err != nil {
+		utils.Warnf("Failed to decode profile badges: %v", err)
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	var badges []utils.ProfileBadge
+	for _, entry := range entries {
+		issuer, dTag, _, err := utils.DecodeBadgeAddress(entry.ATag)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		eventID, hints, err := utils.DecodeEventID(entry.EID)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if entry.Relay == "" && len(hints) > 0 {
+			entry.Relay = hints[0]
+		}
+
+		badges = append(badges, utils.ProfileBadge{
+			BadgeAwardATag: utils.BadgeATag(issuer, dTag),
+			AwardEventID:   eventID,
+			AwardRelayURL:  entry.Relay,
+		})
+	}
+
+	profileEvent, err := utils.BuildProfileBadgesEvent(publicKey, badges)
+	if err != nil {
+		utils.Errorf("Failed to build profile badges event: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Return the unsigned event to the client
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profileEvent)
+}
+
+// ProfileBadgesSignedHandler validates the signed kind 30008 event and broadcasts it to the user's relays
+func ProfileBadgesSignedHandler(w http.ResponseWriter, r *http.Request) {
+	// Decode the signed event from the client
+	var signedEvent nostr.Event
+	if err := json.NewDecoder(r.Body).Decode(&signedEvent); err != nil {
+		utils.Warnf("Failed to decode signed profile badges event: %v", err)
+		http.Error(w, "Invalid signed event data", http.StatusBadRequest)
+		return
+	}
+
+	// Get the relay list from session
+	session, _ := User.Get(r, "session-name")
+	relayList, ok := session.Values["relays"].(utils.RelayList)
+	if !ok {
+		utils.Warnf("No relay list found in session")
+		http.Error(w, "No relay list found", http.StatusInternalServerError)
+		return
+	}
+
+	// Reject anything that isn't a correctly signed profile badges event from this user
+	publicKey, _ := session.Values["publicKey"].(string)
+	if verr := utils.ValidateSignedEvent(signedEvent, publicKey, 30008); verr != nil {
+		utils.Warnf("Rejected profile badges event: %v", verr)
+		utils.WriteValidationError(w, verr)
+		return
+	}
+
+	// Publish to the user's outbox relays (NIP-65)
+	allRelays := relayList.WriteRelays()
+
+	// Send the profile badges to all relays and report how each one answered
+	results := sendEventToRelays(signedEvent, allRelays)
+	writePublishResults(w, r, "profile badges saved", results)
+}
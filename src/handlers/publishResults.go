@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"strings"
+
+	"badger/src/utils"
+)
+
+// publishResponse is returned after broadcasting an event, listing how every relay answered
+type publishResponse struct {
+	Status   string              `json:"status"`
+	Accepted int                 `json:"accepted"`
+	Relays   []utils.RelayResult `json:"relays"`
+}
+
+// writePublishResults answers with an HTML fragment for htmx or HTML requests and JSON otherwise
+func writePublishResults(w http.ResponseWriter, r *http.Request, status string, results []utils.RelayResult) {
+	response := publishResponse{
+		Status:   status,
+		Accepted: utils.CountAccepted(results),
+		Relays:   results,
+	}
+
+	if r.Header.Get("HX-Request") != "" || strings.Contains(r.Header.Get("Accept"), "text/html") {
+		tmpl := template.Must(template.ParseFiles("web/views/components/publish-results.html"))
+		if err := tmpl.ExecuteTemplate(w, "publishResults", response); err != nil {
+			utils.Errorf("Failed to render publish results: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
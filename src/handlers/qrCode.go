@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+
+	"badger/src/utils"
+)
+
+// QRCodeHandler renders /qr/{target}/{value}.{png|svg} with an optional ?size= in pixels. Targets are
+// "naddr" for a nostr:naddr link to a badge definition (NIP-21), "b" for its public badge page and
+// "claim" for a claim code's page.
+func QRCodeHandler(w http.ResponseWriter, r *http.Request) {
+	target, file, found := strings.Cut(strings.TrimPrefix(r.URL.Path, "/qr/"), "/")
+	if !found {
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+	extension := path.Ext(file)
+	value := strings.TrimSuffix(file, extension)
+
+	var content string
+	switch target {
+	case "naddr", "b":
+		if _, _, _, err := utils.DecodeBadgeAddress(value); err != nil {
+			http.Error(w, "Invalid badge address", http.StatusBadRequest)
+			return
+		}
+		content = "nostr:" + value
+		if target == "b" {
+			content = utils.AppConfig.BaseURL(r) + "/b/" + value
+		}
+	case "claim":
+		// Only codes of an existing campaign, the QR code shouldn't outlive it unnoticed
+		if _, err := utils.LookupClaimCode(value); err != nil {
+			http.Error(w, "Unknown claim code", http.StatusNotFound)
+			return
+		}
+		content = utils.AppConfig.BaseURL(r) + "/claim/" + strings.ToLower(value)
+	default:
+		http.Error(w, "Not found", http.StatusNotFound)
+		return
+	}
+
+	size := utils.DefaultQRSize
+	if value := r.URL.Query().Get("size"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed < utils.MinQRSize || parsed > utils.MaxQRSize {
+			http.Error(w, "Size must be between 64 and 1024 pixels", http.StatusBadRequest)
+			return
+		}
+		size = parsed
+	}
+
+	var image []byte
+	var contentType string
+	var err error
+	switch extension {
+	case ".png":
+		image, err = utils.QRCodePNG(content, size)
+		contentType = "image/png"
+	case ".svg":
+		image, err = utils.QRCodeSVG(content, size)
+		contentType = "image/svg+xml"
+	default:
+		http.Error(w, "QR codes are available as .png or .svg", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.Errorf("Failed to render QR code for %s: %v", content, err)
+		http.Error(w, "Failed to render QR code", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("Cache-Control", "public, max-age=86400")
+	w.Write(image)
+}
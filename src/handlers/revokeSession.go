@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"net/http"
+
+	"badger/src/utils"
+)
+
+// RevokeSessionHandler lets an admin end a single session, or every session of a public key
+func RevokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	publicKey, ok := RequireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	key := r.FormValue("key")
+	target := r.FormValue("pubkey")
+	switch {
+	case key != "":
+		if err := User.Revoke(key); err != nil {
+			utils.Errorf("Failed to revoke session: %v", err)
+			http.Error(w, "Failed to revoke session", http.StatusInternalServerError)
+			return
+		}
+		utils.Infof("Admin %s revoked session %.8s", publicKey, key)
+	case target != "":
+		removed, err := User.LogoutEverywhere(target)
+		if err != nil {
+			utils.Errorf("Failed to revoke sessions: %v", err)
+			http.Error(w, "Failed to revoke sessions", http.StatusInternalServerError)
+			return
+		}
+		utils.Infof("Admin %s revoked %d sessions of %s", publicKey, removed, target)
+	default:
+		http.Error(w, "A session key or public key is required", http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, "/admin/sessions", http.StatusSeeOther)
+}
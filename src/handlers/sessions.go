@@ -0,0 +1,245 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/gob"
+	"encoding/hex"
+	"net"
+	"net/http"
+	"time"
+
+	"badger/src/store"
+	"badger/src/utils"
+
+	"github.com/gorilla/securecookie"
+	"github.com/gorilla/sessions"
+)
+
+const (
+	sessionTouchInterval = time.Minute // How often a session's last seen time is written back
+	sessionPruneInterval = time.Hour   // How often expired sessions are removed from the store
+)
+
+// User is the session store, set up by ConfigureSessions at startup
+var User *ServerStore
+
+// ServerStore keeps session values in the event store, the cookie only carries a signed session id.
+// It implements gorilla's sessions.Store.
+type ServerStore struct {
+	Codecs  []securecookie.Codec
+	Options *sessions.Options
+	db      *store.Store
+}
+
+// ConfigureSessions builds the session store from the session keys and cookie options in the config
+func ConfigureSessions(cfg *utils.Config, db *store.Store) error {
+	keyPairs, err := cfg.SessionKeyPairs()
+	if err != nil {
+		return err
+	}
+
+	s := &ServerStore{
+		Codecs: securecookie.CodecsFromPairs(keyPairs...),
+		Options: &sessions.Options{
+			Path:     "/",
+			MaxAge:   cfg.SessionMaxAge,
+			Secure:   cfg.SecureCookies(),
+			HttpOnly: cfg.CookieHTTPOnly,
+			SameSite: cfg.SameSite(),
+		},
+		db: db,
+	}
+	// Signed ids are only accepted for as long as the session lasts
+	for _, codec := range s.Codecs {
+		if sc, ok := codec.(*securecookie.SecureCookie); ok {
+			sc.MaxAge(cfg.SessionMaxAge)
+		}
+	}
+
+	go s.pruneExpired()
+
+	User = s
+	return nil
+}
+
+// Get returns the named session, cached for the rest of the request
+func (s *ServerStore) Get(r *http.Request, name string) (*sessions.Session, error) {
+	return sessions.GetRegistry(r).Get(s, name)
+}
+
+// New loads the session named by the request's cookie, or starts an empty one
+func (s *ServerStore) New(r *http.Request, name string) (*sessions.Session, error) {
+	session := sessions.NewSession(s, name)
+	options := *s.Options
+	session.Options = &options
+	session.IsNew = true
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return session, nil
+	}
+
+	var id string
+	if err := securecookie.DecodeMulti(name, cookie.Value, &id, s.Codecs...); err != nil {
+		return session, err
+	}
+
+	stored, found, err := s.db.LoadSession(sessionKey(id))
+	if err != nil || !found {
+		// Expired, logged out or revoked: carry on with an empty session
+		return session, err
+	}
+	if err := gob.NewDecoder(bytes.NewReader(stored.Values)).Decode(&session.Values); err != nil {
+		return session, err
+	}
+	session.ID = id
+	session.IsNew = false
+
+	// Keep the last seen time roughly current without writing on every request
+	if time.Since(stored.LastSeen) > sessionTouchInterval {
+		stored.LastSeen = time.Now()
+		if err := s.db.SaveSession(stored); err != nil {
+			utils.Errorf("Failed to update session: %v", err)
+		}
+	}
+	return session, nil
+}
+
+// Save stores the session values and sets the id cookie, or deletes both when MaxAge is negative
+func (s *ServerStore) Save(r *http.Request, w http.ResponseWriter, session *sessions.Session) error {
+	if session.Options.MaxAge < 0 {
+		if session.ID != "" {
+			removed, err := s.db.DeleteSession(sessionKey(session.ID))
+			if err != nil {
+				return err
+			}
+			closeBunkers(removed)
+		}
+		http.SetCookie(w, sessions.NewCookie(session.Name(), "", session.Options))
+		return nil
+	}
+
+	if session.ID == "" {
+		session.ID = newSessionID()
+	}
+
+	var values bytes.Buffer
+	if err := gob.NewEncoder(&values).Encode(session.Values); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	key := sessionKey(session.ID)
+	stored, found, err := s.db.LoadSession(key)
+	if err != nil {
+		return err
+	}
+	if !found {
+		stored = store.Session{Key: key, CreatedAt: now}
+	}
+	stored.PubKey, _ = session.Values["publicKey"].(string)
+	stored.Values = values.Bytes()
+	stored.LastSeen = now
+	stored.ExpiresAt = now.Add(time.Duration(session.Options.MaxAge) * time.Second)
+	stored.UserAgent = r.UserAgent()
+	stored.RemoteAddr = remoteHost(r)
+	if err := s.db.SaveSession(stored); err != nil {
+		return err
+	}
+
+	encoded, err := securecookie.EncodeMulti(session.Name(), session.ID, s.Codecs...)
+	if err != nil {
+		return err
+	}
+	http.SetCookie(w, sessions.NewCookie(session.Name(), encoded, session.Options))
+	return nil
+}
+
+// LogoutEverywhere removes every session of a user, on all of their devices
+func (s *ServerStore) LogoutEverywhere(publicKey string) (int, error) {
+	removed, err := s.db.DeleteUserSessions(publicKey)
+	if err != nil {
+		return 0, err
+	}
+	closeBunkers(removed...)
+	return len(removed), nil
+}
+
+// Revoke removes a single session by its stored key
+func (s *ServerStore) Revoke(key string) error {
+	removed, err := s.db.DeleteSession(key)
+	if err != nil {
+		return err
+	}
+	closeBunkers(removed)
+	return nil
+}
+
+// Active lists the sessions that are logged in
+func (s *ServerStore) Active() ([]store.Session, error) {
+	return s.db.ActiveSessions()
+}
+
+// CurrentKey returns the stored key of the request's session, "" when it has none
+func (s *ServerStore) CurrentKey(r *http.Request) string {
+	session, _ := s.Get(r, "session-name")
+	if session.ID == "" {
+		return ""
+	}
+	return sessionKey(session.ID)
+}
+
+func (s *ServerStore) pruneExpired() {
+	for {
+		if pruned, err := s.db.PruneSessions(time.Now()); err != nil {
+			utils.Errorf("Failed to prune sessions: %v", err)
+		} else if len(pruned) > 0 {
+			closeBunkers(pruned...)
+			utils.Debugf("Pruned %d expired sessions", len(pruned))
+		}
+		time.Sleep(sessionPruneInterval)
+	}
+}
+
+// closeBunkers hangs up on the remote signers of removed sessions, whose relay subscriptions would
+// otherwise stay open
+func closeBunkers(removed ...store.Session) {
+	for _, stored := range removed {
+		if len(stored.Values) == 0 {
+			continue
+		}
+		values := make(map[interface{}]interface{})
+		if err := gob.NewDecoder(bytes.NewReader(stored.Values)).Decode(&values); err != nil {
+			continue
+		}
+		if bunker, ok := values["bunker"].(utils.BunkerSession); ok {
+			bunker.Close()
+		}
+	}
+}
+
+// newSessionID returns 256 random bits, unguessable enough to identify a login
+func newSessionID() string {
+	id := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(id)
+}
+
+// sessionKey is what the store indexes sessions by, so a copy of the database can't be replayed as cookies
+func sessionKey(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return hex.EncodeToString(sum[:])
+}
+
+func remoteHost(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
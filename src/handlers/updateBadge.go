@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"encoding/json"
-	"log"
 	"net/http"
 
 	"badger/src/utils"
@@ -17,30 +16,43 @@ func UpdateBadgeHandler(w http.ResponseWriter, r *http.Request) {
 	// Fetch the relay list from the session
 	relays, ok := session.Values["relays"].(utils.RelayList)
 	if !ok {
-		log.Println("No relay list found in session")
+		utils.Warnf("No relay list found in session")
 		http.Error(w, "Relay list not found", http.StatusInternalServerError)
 		return
 	}
 
-	// Combine all user relays (read, write, both)
-	allRelays := append(relays.Read, relays.Write...)
-	allRelays = append(allRelays, relays.Both...)
-
 	// Decode the updated badge event from the request body
 	var updatedEvent nostr.Event
 	err := json.NewDecoder(r.Body).Decode(&updatedEvent)
 	if err != nil {
-		log.Printf("Failed to decode the request body: %v", err)
+		utils.Warnf("Failed to decode the request body: %v", err)
 		http.Error(w, "Invalid request", http.StatusBadRequest)
 		return
 	}
 
-	// Log the updated event for debugging
-	log.Printf("Received updated event: %+v", updatedEvent)
+	// Organization issuers publish to their own outbox relays (NIP-65), users to theirs
+	publicKey, _ := session.Values["publicKey"].(string)
+	authorList, allowed := authorRelays(publicKey, updatedEvent.PubKey, relays)
+	if !allowed {
+		http.Error(w, "You may not publish as this issuer", http.StatusForbidden)
+		return
+	}
+	allRelays := authorList.WriteRelays()
 
-	// Send the updated event to the user's relays
-	sendEventToRelays(updatedEvent, allRelays)
+	// Reject anything that isn't a correctly signed badge definition from this user or issuer
+	if verr := utils.ValidateSignedEvent(updatedEvent, updatedEvent.PubKey, 30009); verr != nil {
+		utils.Warnf("Rejected updated badge event: %v", verr)
+		utils.WriteValidationError(w, verr)
+		return
+	}
+
+	// Log the updated event for debugging
+	utils.Debugf("Received updated event: %+v", updatedEvent)
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]string{"status": "badge updated"})
+	// Send the updated event to the user's relays and report how each one answered
+	results := sendEventToRelays(updatedEvent, allRelays)
+	if updatedEvent.PubKey != publicKey {
+		utils.RecordIssuerPublish(updatedEvent, publicKey, results)
+	}
+	writePublishResults(w, r, "badge updated", results)
 }
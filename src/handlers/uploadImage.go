@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"badger/src/utils"
+)
+
+// UploadImageHandler hosts an uploaded badge image and returns its URL and thumbnails with their dimensions
+func UploadImageHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	// Only logged in users may store images on this server
+	session, _ := User.Get(r, "session-name")
+	publicKey, ok := session.Values["publicKey"].(string)
+	if !ok || publicKey == "" {
+		http.Error(w, "Not logged in", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, utils.MaxImageUploadSize+1<<20) // Room for the multipart framing
+	file, _, err := r.FormFile("image")
+	if err != nil {
+		http.Error(w, "An image file of at most 5 MB is required", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, utils.MaxImageUploadSize+1))
+	if err != nil {
+		utils.Warnf("Failed to read uploaded image: %v", err)
+		http.Error(w, "Failed to read image", http.StatusBadRequest)
+		return
+	}
+	if len(data) > utils.MaxImageUploadSize {
+		http.Error(w, "Images may be at most 5 MB", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	uploaded, err := utils.SaveImage(data, utils.AppConfig.BaseURL(r))
+	if err != nil {
+		utils.Warnf("Rejected image upload from %s: %v", publicKey, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	utils.Debugf("Stored image %s for %s", uploaded.Image.URL, publicKey)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(uploaded)
+}
+
+// ServeImage serves hosted images, which never change since they are named by their SHA-256
+func ServeImage(w http.ResponseWriter, r *http.Request) {
+	path := utils.ImagePath(r.URL.Path[len("/images/"):])
+	if path == "" {
+		http.NotFound(w, r)
+		return
+	}
+
+	w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	http.ServeFile(w, r, path)
+}
@@ -0,0 +1,272 @@
+package relay
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"badger/src/types"
+
+	"github.com/gorilla/websocket"
+)
+
+// subscriptionBuffer is how many events a subscription queues before the read loop drops them, so a
+// slow consumer can't stall every other subscription on the connection
+const subscriptionBuffer = 256
+
+// ErrConnectionClosed is returned to subscriptions and publishes waiting on a relay that disconnected
+var ErrConnectionClosed = errors.New("relay connection closed")
+
+// connection is a single WebSocket to a relay shared by every subscription and publish on it
+type connection struct {
+	url string
+	ws  *websocket.Conn
+
+	writeMu sync.Mutex // gorilla/websocket allows only one concurrent writer
+
+	mu   sync.Mutex
+	subs map[string]*subscription
+	oks  map[string]chan OKResult
+
+	lastUsed atomic.Int64 // Unix nanoseconds of the last subscription or publish, for idle eviction
+	closing  atomic.Bool  // Set when Badger hangs up itself, e.g. on an idle connection
+
+	done chan struct{}
+	err  error
+}
+
+// subscription receives the messages a relay sends for one REQ
+type subscription struct {
+	id     string
+	events chan types.NostrEvent
+	eose   chan struct{}
+	closed chan string
+	stop   chan struct{}
+
+	eoseOnce  sync.Once
+	closeOnce sync.Once
+}
+
+func newConnection(url string, ws *websocket.Conn) *connection {
+	conn := &connection{
+		url:  url,
+		ws:   ws,
+		subs: make(map[string]*subscription),
+		oks:  make(map[string]chan OKResult),
+		done: make(chan struct{}),
+	}
+	conn.touch()
+	return conn
+}
+
+// touch marks the connection as in use
+func (c *connection) touch() {
+	c.lastUsed.Store(time.Now().UnixNano())
+}
+
+// idle reports whether nothing is waiting on the connection and it hasn't been used for timeout
+func (c *connection) idle(timeout time.Duration) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.subs) == 0 && len(c.oks) == 0 && time.Since(time.Unix(0, c.lastUsed.Load())) > timeout
+}
+
+// alive reports whether the read loop is still running
+func (c *connection) alive() bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+		return true
+	}
+}
+
+// send writes a single relay protocol message, e.g. ["REQ", id, filter]
+func (c *connection) send(message ...interface{}) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.ws.WriteJSON(message)
+}
+
+func (c *connection) close() {
+	c.closing.Store(true)
+	c.ws.Close()
+}
+
+// readLoop dispatches relay messages to their subscriptions until the socket fails
+func (c *connection) readLoop(onClose func()) {
+	defer func() {
+		close(c.done)
+		onClose()
+	}()
+
+	for {
+		_, message, err := c.ws.ReadMessage()
+		if err != nil {
+			c.err = err
+			if !c.closing.Load() {
+				log.Printf("Relay %s disconnected: %v\n", c.url, err)
+			}
+			return
+		}
+
+		var envelope []json.RawMessage
+		if err := json.Unmarshal(message, &envelope); err != nil || len(envelope) < 2 {
+			log.Printf("Invalid message from relay %s: %s\n", c.url, message)
+			continue
+		}
+
+		var label string
+		if err := json.Unmarshal(envelope[0], &label); err != nil {
+			continue
+		}
+
+		switch label {
+		case "EVENT":
+			c.handleEvent(envelope)
+		case "EOSE":
+			if sub := c.subscription(envelope[1]); sub != nil {
+				sub.eoseOnce.Do(func() { close(sub.eose) })
+			}
+		case "CLOSED":
+			if sub := c.subscription(envelope[1]); sub != nil {
+				reason := ""
+				if len(envelope) > 2 {
+					json.Unmarshal(envelope[2], &reason)
+				}
+				sub.closeOnce.Do(func() { sub.closed <- reason })
+			}
+		case "OK":
+			c.handleOK(envelope)
+		case "NOTICE":
+			var notice string
+			json.Unmarshal(envelope[1], &notice)
+			log.Printf("NOTICE from relay %s: %s\n", c.url, notice)
+		case "AUTH":
+			log.Printf("Relay %s requested authentication, ignoring\n", c.url)
+		default:
+			log.Printf("Unknown message from relay %s: %s\n", c.url, message)
+		}
+	}
+}
+
+func (c *connection) subscription(rawID json.RawMessage) *subscription {
+	var id string
+	if err := json.Unmarshal(rawID, &id); err != nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.subs[id]
+}
+
+func (c *connection) handleEvent(envelope []json.RawMessage) {
+	if len(envelope) < 3 {
+		return
+	}
+	sub := c.subscription(envelope[1])
+	if sub == nil {
+		return
+	}
+
+	var event types.NostrEvent
+	if err := json.Unmarshal(envelope[2], &event); err != nil {
+		log.Printf("Failed to parse event from relay %s: %v\n", c.url, err)
+		return
+	}
+	// Callers only ever see events that are signed by their author
+	if err := event.Verify(); err != nil {
+		log.Printf("Dropping event %s from relay %s: %v\n", event.ID, c.url, err)
+		return
+	}
+
+	select {
+	case sub.events <- event:
+	case <-sub.stop:
+	default:
+		log.Printf("Subscription %s on %s is not keeping up, dropping event %s\n", sub.id, c.url, event.ID)
+	}
+}
+
+func (c *connection) handleOK(envelope []json.RawMessage) {
+	if len(envelope) < 3 {
+		return
+	}
+
+	var result OKResult
+	json.Unmarshal(envelope[1], &result.EventID)
+	json.Unmarshal(envelope[2], &result.Accepted)
+	if len(envelope) > 3 {
+		json.Unmarshal(envelope[3], &result.Message)
+	}
+
+	c.mu.Lock()
+	waiter, found := c.oks[result.EventID]
+	delete(c.oks, result.EventID)
+	c.mu.Unlock()
+	c.touch()
+
+	if found {
+		waiter <- result
+	}
+}
+
+// subscribe registers a subscription and sends its REQ
+func (c *connection) subscribe(id string, filters []types.SubscriptionFilter) (*subscription, error) {
+	sub := &subscription{
+		id:     id,
+		events: make(chan types.NostrEvent, subscriptionBuffer),
+		eose:   make(chan struct{}),
+		closed: make(chan string, 1),
+		stop:   make(chan struct{}),
+	}
+
+	c.mu.Lock()
+	c.subs[id] = sub
+	c.mu.Unlock()
+
+	message := []interface{}{"REQ", id}
+	for _, filter := range filters {
+		message = append(message, filter)
+	}
+	if err := c.send(message...); err != nil {
+		c.unsubscribe(sub, false)
+		return nil, err
+	}
+	return sub, nil
+}
+
+// unsubscribe forgets a subscription, sending CLOSE unless the relay already closed it
+func (c *connection) unsubscribe(sub *subscription, sendClose bool) {
+	c.mu.Lock()
+	delete(c.subs, sub.id)
+	c.mu.Unlock()
+	close(sub.stop)
+	c.touch()
+
+	if sendClose && c.alive() {
+		if err := c.send("CLOSE", sub.id); err != nil {
+			log.Printf("Failed to close subscription %s on %s: %v\n", sub.id, c.url, err)
+		}
+	}
+}
+
+// expectOK registers interest in the relay's OK response for an event id
+func (c *connection) expectOK(eventID string) chan OKResult {
+	waiter := make(chan OKResult, 1)
+	c.mu.Lock()
+	c.oks[eventID] = waiter
+	c.mu.Unlock()
+	return waiter
+}
+
+func (c *connection) forgetOK(eventID string) {
+	c.mu.Lock()
+	delete(c.oks, eventID)
+	c.mu.Unlock()
+	c.touch()
+}
@@ -0,0 +1,171 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// Pool keeps one WebSocket per relay URL open and shares it between all fetchers and publishers
+type Pool struct {
+	DialTimeout    time.Duration // Time allowed to open a connection
+	QueryTimeout   time.Duration // Time a single relay gets to reach EOSE
+	PublishTimeout time.Duration // Time a single relay gets to answer with OK
+	IdleTimeout    time.Duration // Unused connections are closed after this long
+
+	mu      sync.Mutex
+	conns   map[string]*connection
+	trusted map[string]bool // Relays from the config, dialled without the public address checks
+	janitor sync.Once
+}
+
+// DefaultPool is the pool used by the utils fetchers and senders
+var DefaultPool = NewPool()
+
+// subscriptionCounter makes every subscription id unique for the life of the process
+var subscriptionCounter atomic.Uint64
+
+func NewPool() *Pool {
+	return &Pool{
+		DialTimeout:    5 * time.Second,
+		QueryTimeout:   5 * time.Second,
+		PublishTimeout: 10 * time.Second,
+		IdleTimeout:    2 * time.Minute,
+		conns:          make(map[string]*connection),
+		trusted:        make(map[string]bool),
+	}
+}
+
+// SetTrusted replaces the relays that may be dialled at any address and over ws://, normally the ones
+// from the config. Every other relay must be a public wss:// URL.
+func (p *Pool) SetTrusted(urls []string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.trusted = make(map[string]bool)
+	for _, url := range Unique(urls) {
+		p.trusted[url] = true
+	}
+}
+
+func (p *Pool) isTrusted(url string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.trusted[url]
+}
+
+// NormalizeURL trims whitespace and trailing slashes so the same relay maps to a single connection
+func NormalizeURL(url string) string {
+	return strings.TrimRight(strings.TrimSpace(url), "/")
+}
+
+// connect returns the open connection to a relay, dialing it if needed
+func (p *Pool) connect(ctx context.Context, url string) (*connection, error) {
+	url = NormalizeURL(url)
+
+	p.janitor.Do(func() { go p.evictIdle() })
+
+	p.mu.Lock()
+	if conn, found := p.conns[url]; found && conn.alive() {
+		// Touched under the pool lock so evictIdle can't close it before the caller uses it
+		conn.touch()
+		p.mu.Unlock()
+		return conn, nil
+	}
+	p.mu.Unlock()
+
+	trusted := p.isTrusted(url)
+	if !trusted && !IsPublicURL(url) {
+		return nil, fmt.Errorf("refusing to connect to relay %s: relays outside the config must be public wss:// URLs", url)
+	}
+
+	dialCtx, cancel := context.WithTimeout(ctx, p.DialTimeout)
+	defer cancel()
+
+	dialer := websocket.Dialer{HandshakeTimeout: p.DialTimeout}
+	if !trusted {
+		// Checked on the resolved address, a public name could still point inside the network
+		dialer.NetDialContext = (&net.Dialer{Timeout: p.DialTimeout, Control: PublicOnly}).DialContext
+	}
+	ws, _, err := dialer.DialContext(dialCtx, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to relay %s: %w", url, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	// Another caller may have connected while we were dialing
+	if existing, found := p.conns[url]; found && existing.alive() {
+		ws.Close()
+		existing.touch()
+		return existing, nil
+	}
+
+	conn := newConnection(url, ws)
+	p.conns[url] = conn
+	go conn.readLoop(func() { p.remove(url, conn) })
+	return conn, nil
+}
+
+func (p *Pool) remove(url string, conn *connection) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.conns[url] == conn {
+		delete(p.conns, url)
+	}
+}
+
+// evictIdle closes the connections nobody used for IdleTimeout, so relays dialled once don't stay open
+func (p *Pool) evictIdle() {
+	for {
+		interval := p.IdleTimeout / 2
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		time.Sleep(interval)
+		if p.IdleTimeout <= 0 {
+			continue
+		}
+
+		p.mu.Lock()
+		for url, conn := range p.conns {
+			if conn.idle(p.IdleTimeout) {
+				conn.close()
+				delete(p.conns, url)
+			}
+		}
+		p.mu.Unlock()
+	}
+}
+
+// Release closes the idle connections to the untrusted relays among urls, for relays a single request
+// chose (naddr hints, relay lists of the users it names) that shouldn't stay open afterwards
+func (p *Pool) Release(urls ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, url := range Unique(urls) {
+		if conn, found := p.conns[url]; found && !p.trusted[url] && conn.idle(0) {
+			conn.close()
+			delete(p.conns, url)
+		}
+	}
+}
+
+// Close disconnects from every relay in the pool
+func (p *Pool) Close() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for url, conn := range p.conns {
+		conn.close()
+		delete(p.conns, url)
+	}
+}
+
+func nextSubscriptionID() string {
+	return fmt.Sprintf("badger-%d", subscriptionCounter.Add(1))
+}
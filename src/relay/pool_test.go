@@ -0,0 +1,166 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"badger/src/types"
+
+	"github.com/gorilla/websocket"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// fakeRelay answers every REQ with the given events followed by EOSE
+func fakeRelay(t *testing.T, events ...nostr.Event) string {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		for {
+			var message []json.RawMessage
+			if err := ws.ReadJSON(&message); err != nil {
+				return
+			}
+			var label, id string
+			json.Unmarshal(message[0], &label)
+			if label != "REQ" {
+				continue
+			}
+			json.Unmarshal(message[1], &id)
+			for _, event := range events {
+				ws.WriteJSON([]interface{}{"EVENT", id, event})
+			}
+			ws.WriteJSON([]interface{}{"EOSE", id})
+		}
+	}))
+	t.Cleanup(server.Close)
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func signedEvent(t *testing.T, kind int, content string) nostr.Event {
+	t.Helper()
+	event := nostr.Event{Kind: kind, CreatedAt: nostr.Now(), Tags: nostr.Tags{}, Content: content}
+	if err := event.Sign(nostr.GeneratePrivateKey()); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestQueryDropsUnverifiedEvents(t *testing.T) {
+	valid := signedEvent(t, 8, "valid")
+
+	tamperedContent := signedEvent(t, 8, "original")
+	tamperedContent.Content = "changed"
+
+	tamperedID := signedEvent(t, 8, "other")
+	tamperedID.Content = "changed"
+	tamperedID.ID = tamperedID.GetID()
+
+	wrongAuthor := signedEvent(t, 8, "author")
+	wrongAuthor.PubKey = valid.PubKey
+
+	url := fakeRelay(t, valid, tamperedContent, tamperedID, wrongAuthor)
+	pool := NewPool()
+	pool.SetTrusted([]string{url})
+	defer pool.Close()
+
+	events, err := pool.Query(context.Background(), []string{url}, types.SubscriptionFilter{Kinds: []int{8}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].ID != valid.ID {
+		t.Fatalf("expected only the valid event, got %+v", events)
+	}
+}
+
+func TestQueryKeepsEventsBeforeEOSE(t *testing.T) {
+	var sent []nostr.Event
+	for i := 0; i < 50; i++ {
+		sent = append(sent, signedEvent(t, 8, fmt.Sprint("event ", i)))
+	}
+	url := fakeRelay(t, sent...)
+	pool := NewPool()
+	pool.SetTrusted([]string{url})
+	defer pool.Close()
+
+	for i := 0; i < 20; i++ {
+		events, err := pool.QueryRelay(context.Background(), url, types.SubscriptionFilter{Kinds: []int{8}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != len(sent) {
+			t.Fatalf("query %d returned %d of %d events", i, len(events), len(sent))
+		}
+	}
+}
+
+func TestIdleConnectionsAreEvicted(t *testing.T) {
+	url := fakeRelay(t)
+	pool := NewPool()
+	pool.IdleTimeout = 50 * time.Millisecond
+	pool.SetTrusted([]string{url})
+	defer pool.Close()
+
+	if _, err := pool.QueryRelay(context.Background(), url, types.SubscriptionFilter{}); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		pool.mu.Lock()
+		open := len(pool.conns)
+		pool.mu.Unlock()
+		if open == 0 {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatal("idle connection was not closed")
+}
+
+func TestUntrustedRelaysMustBePublic(t *testing.T) {
+	url := fakeRelay(t)
+	pool := NewPool()
+	defer pool.Close()
+
+	if _, err := pool.QueryRelay(context.Background(), url, types.SubscriptionFilter{}); err == nil {
+		t.Fatal("dialled an untrusted loopback relay")
+	}
+	pool.SetTrusted([]string{url})
+	if _, err := pool.QueryRelay(context.Background(), url, types.SubscriptionFilter{}); err != nil {
+		t.Fatalf("trusted relay refused: %v", err)
+	}
+}
+
+func TestPublicOnlyRefusesResolvedPrivateAddresses(t *testing.T) {
+	tests := []struct {
+		address string
+		allowed bool
+	}{
+		{"93.184.216.34:443", true},
+		{"[2606:4700::1111]:443", true},
+		{"127.0.0.1:443", false},
+		{"10.1.2.3:443", false},
+		{"192.168.0.10:443", false},
+		{"169.254.169.254:80", false},
+		{"100.64.0.1:443", false},
+		{"[::1]:443", false},
+		{"[fd00::1]:443", false},
+		{"0.0.0.0:443", false},
+	}
+	for _, test := range tests {
+		if err := PublicOnly("tcp", test.address, nil); (err == nil) != test.allowed {
+			t.Errorf("PublicOnly(%s) = %v, want allowed %v", test.address, err, test.allowed)
+		}
+	}
+}
@@ -0,0 +1,66 @@
+package relay
+
+import (
+	"fmt"
+	"net"
+	neturl "net/url"
+	"strings"
+	"syscall"
+)
+
+// Relays named by users (naddr hints, NIP-65 relay lists) are untrusted: the pool only dials them over
+// wss:// and only at public addresses, so a request can't make Badger reach services on its own network.
+// Relays from the config are trusted and may be anywhere.
+
+// sharedAddressSpace is the carrier-grade NAT range (RFC 6598), private in practice
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// IsPublicURL reports whether url is a wss:// URL whose host isn't a loopback, private or link-local
+// address. Host names are checked again against the addresses they resolve to when dialled.
+func IsPublicURL(url string) bool {
+	parsed, err := neturl.Parse(NormalizeURL(url))
+	if err != nil || parsed.Scheme != "wss" || parsed.Hostname() == "" {
+		return false
+	}
+
+	host := strings.ToLower(parsed.Hostname())
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") || strings.HasSuffix(host, ".local") || strings.HasSuffix(host, ".internal") {
+		return false
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		return publicIP(ip)
+	}
+	return true
+}
+
+// PublicURLs keeps the unique public relay URLs, at most max of them (all of them when max <= 0)
+func PublicURLs(urls []string, max int) []string {
+	var public []string
+	for _, url := range Unique(urls) {
+		if max > 0 && len(public) == max {
+			break
+		}
+		if IsPublicURL(url) {
+			public = append(public, url)
+		}
+	}
+	return public
+}
+
+func publicIP(ip net.IP) bool {
+	return !(ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || sharedAddressSpace.Contains(ip))
+}
+
+// PublicOnly is a net.Dialer Control function refusing connections to non-public addresses, after DNS
+// resolved the host name. Also used for other requests to hosts taken from events, such as NIP-05.
+func PublicOnly(network, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	if ip := net.ParseIP(host); ip == nil || !publicIP(ip) {
+		return fmt.Errorf("refusing to connect to non-public address %s", host)
+	}
+	return nil
+}
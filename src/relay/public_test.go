@@ -0,0 +1,55 @@
+package relay
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestIsPublicURL(t *testing.T) {
+	tests := []struct {
+		url    string
+		public bool
+	}{
+		{"wss://relay.damus.io", true},
+		{"wss://relay.example.com:7777/path", true},
+		{"wss://93.184.216.34", true},
+		{"ws://relay.damus.io", false},
+		{"https://relay.damus.io", false},
+		{"wss://localhost:8787/relay", false},
+		{"wss://relay.localhost", false},
+		{"wss://printer.local", false},
+		{"wss://metadata.google.internal", false},
+		{"wss://127.0.0.1", false},
+		{"wss://10.0.0.5:7000", false},
+		{"wss://172.16.3.4", false},
+		{"wss://192.168.1.1", false},
+		{"wss://169.254.169.254", false},
+		{"wss://[::1]", false},
+		{"wss://[fe80::1]", false},
+		{"wss://", false},
+		{"not a url", false},
+	}
+	for _, test := range tests {
+		if got := IsPublicURL(test.url); got != test.public {
+			t.Errorf("IsPublicURL(%q) = %v, want %v", test.url, got, test.public)
+		}
+	}
+}
+
+func TestPublicURLs(t *testing.T) {
+	urls := []string{"ws://10.0.0.1", "wss://a.example", "wss://127.0.0.1", "wss://a.example", "wss://b.example", "wss://c.example"}
+
+	tests := []struct {
+		max  int
+		want []string
+	}{
+		{0, []string{"wss://a.example", "wss://b.example", "wss://c.example"}},
+		{2, []string{"wss://a.example", "wss://b.example"}},
+		{1, []string{"wss://a.example"}},
+	}
+	for _, test := range tests {
+		if got := PublicURLs(urls, test.max); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("PublicURLs(max %d) = %v, want %v", test.max, got, test.want)
+		}
+	}
+}
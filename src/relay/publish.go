@@ -0,0 +1,42 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// OKResult is a relay's answer to an EVENT message (NIP-01 "OK")
+type OKResult struct {
+	EventID  string
+	Accepted bool
+	Message  string
+}
+
+// Publish sends a signed event to a single relay and waits for its OK response
+func (p *Pool) Publish(ctx context.Context, url string, event nostr.Event) (OKResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.PublishTimeout)
+	defer cancel()
+
+	conn, err := p.connect(ctx, url)
+	if err != nil {
+		return OKResult{}, err
+	}
+
+	waiter := conn.expectOK(event.ID)
+	if err := conn.send("EVENT", event); err != nil {
+		conn.forgetOK(event.ID)
+		return OKResult{}, fmt.Errorf("failed to send event to relay %s: %w", url, err)
+	}
+
+	select {
+	case result := <-waiter:
+		return result, nil
+	case <-conn.done:
+		return OKResult{}, ErrConnectionClosed
+	case <-ctx.Done():
+		conn.forgetOK(event.ID)
+		return OKResult{}, ctx.Err()
+	}
+}
@@ -0,0 +1,122 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+
+	"badger/src/types"
+)
+
+// QueryRelay sends a REQ to a single relay and collects events until EOSE, CLOSED or the query timeout
+func (p *Pool) QueryRelay(ctx context.Context, url string, filters ...types.SubscriptionFilter) ([]types.NostrEvent, error) {
+	ctx, cancel := context.WithTimeout(ctx, p.QueryTimeout)
+	defer cancel()
+
+	conn, err := p.connect(ctx, url)
+	if err != nil {
+		return nil, err
+	}
+
+	sub, err := conn.subscribe(nextSubscriptionID(), filters)
+	if err != nil {
+		return nil, fmt.Errorf("failed to subscribe on %s: %w", url, err)
+	}
+
+	var events []types.NostrEvent
+	for {
+		select {
+		case event := <-sub.events:
+			events = append(events, event)
+		case <-sub.eose:
+			// Events read before the EOSE may still be buffered, select picks among ready cases at random
+			for drained := false; !drained; {
+				select {
+				case event := <-sub.events:
+					events = append(events, event)
+				default:
+					drained = true
+				}
+			}
+			conn.unsubscribe(sub, true)
+			return events, nil
+		case reason := <-sub.closed:
+			conn.unsubscribe(sub, false)
+			return events, fmt.Errorf("subscription closed by %s: %s", url, reason)
+		case <-conn.done:
+			conn.unsubscribe(sub, false)
+			return events, ErrConnectionClosed
+		case <-ctx.Done():
+			conn.unsubscribe(sub, true)
+			return events, ctx.Err()
+		}
+	}
+}
+
+// Query sends the filters to every relay concurrently and returns the deduplicated events. It only
+// fails when no relay could answer at all, a slow or broken relay just contributes nothing.
+func (p *Pool) Query(ctx context.Context, urls []string, filters ...types.SubscriptionFilter) ([]types.NostrEvent, error) {
+	var events []types.NostrEvent
+	seenEventIDs := make(map[string]bool)
+	var errs []error
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for _, url := range Unique(urls) {
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			relayEvents, err := p.QueryRelay(ctx, url, filters...)
+
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				log.Printf("Query on relay %s ended early: %v\n", url, err)
+				if len(relayEvents) == 0 {
+					errs = append(errs, err)
+				}
+			}
+			for _, event := range relayEvents {
+				if seenEventIDs[event.ID] {
+					continue
+				}
+				seenEventIDs[event.ID] = true
+				events = append(events, event)
+			}
+		}(url)
+	}
+	wg.Wait()
+
+	if len(urls) > 0 && len(events) == 0 && len(errs) == len(Unique(urls)) {
+		return nil, errors.Join(errs...)
+	}
+	return events, nil
+}
+
+// Newest returns the most recent event, which is the current version of a replaceable event
+func Newest(events []types.NostrEvent) *types.NostrEvent {
+	var newest *types.NostrEvent
+	for i := range events {
+		if newest == nil || events[i].CreatedAt > newest.CreatedAt {
+			newest = &events[i]
+		}
+	}
+	return newest
+}
+
+// Unique removes duplicate and empty relay URLs while keeping their order
+func Unique(urls []string) []string {
+	var unique []string
+	seen := make(map[string]bool)
+	for _, url := range urls {
+		url = NormalizeURL(url)
+		if url == "" || seen[url] {
+			continue
+		}
+		seen[url] = true
+		unique = append(unique, url)
+	}
+	return unique
+}
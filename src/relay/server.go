@@ -0,0 +1,336 @@
+package relay
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"badger/src/store"
+	"badger/src/types"
+
+	"github.com/gorilla/websocket"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// ServerKinds are the event kinds the embedded relay accepts: metadata, deletions, relay lists and badges
+var ServerKinds = map[int]bool{
+	0:     true, // User metadata
+	5:     true, // Deletion event kind (NIP-09)
+	8:     true, // Badge award (NIP-58)
+	10002: true, // Relay list metadata (NIP-65)
+	30008: true, // Profile badges (NIP-58)
+	30009: true, // Badge definition (NIP-58)
+}
+
+const (
+	maxServerMessageSize = 128 << 10 // Largest message a client may send
+	maxServerMessageRate = 20        // Messages per second a client may send, the rest is dropped
+	maxServerFilters     = 10        // Filters allowed in a single REQ
+	maxServerSubs        = 20        // Open subscriptions per client
+	maxServerSubIDLength = 64        // Longest subscription id
+	maxServerEventTags   = 2000      // Tags in one event, big awards name many recipients
+	maxServerFutureSkew  = 15 * 60   // Seconds an event's created_at may be ahead of the clock
+	defaultServerLimit   = 500       // Events returned per filter when the client sets no limit
+)
+
+// Server is a minimal NIP-01 relay backed by the local event store
+type Server struct {
+	store    *store.Store
+	upgrader websocket.Upgrader
+
+	mu      sync.Mutex
+	clients map[*serverClient]bool
+}
+
+// serverClient is one WebSocket connected to the embedded relay
+type serverClient struct {
+	ws      *websocket.Conn
+	writeMu sync.Mutex
+
+	mu   sync.Mutex
+	subs map[string][]types.SubscriptionFilter
+
+	// Messages received in the current one second window, only touched by the read loop
+	window   time.Time
+	messages int
+}
+
+func NewServer(s *store.Store) *Server {
+	return &Server{
+		store: s,
+		upgrader: websocket.Upgrader{
+			// Any nostr client may connect, not only the Badger UI
+			CheckOrigin: func(r *http.Request) bool { return true },
+		},
+		clients: make(map[*serverClient]bool),
+	}
+}
+
+// ServeHTTP upgrades relay connections and answers NIP-11 information requests
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !websocket.IsWebSocketUpgrade(r) {
+		if strings.Contains(r.Header.Get("Accept"), "application/nostr+json") {
+			s.writeInformation(w)
+			return
+		}
+		http.Error(w, "This is a nostr relay, connect with a WebSocket client", http.StatusBadRequest)
+		return
+	}
+
+	ws, err := s.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade relay connection: %v\n", err)
+		return
+	}
+	ws.SetReadLimit(maxServerMessageSize)
+
+	client := &serverClient{ws: ws, subs: make(map[string][]types.SubscriptionFilter)}
+	s.mu.Lock()
+	s.clients[client] = true
+	s.mu.Unlock()
+
+	defer func() {
+		s.mu.Lock()
+		delete(s.clients, client)
+		s.mu.Unlock()
+		ws.Close()
+	}()
+
+	s.readLoop(client)
+}
+
+// writeInformation serves the NIP-11 relay information document
+func (s *Server) writeInformation(w http.ResponseWriter) {
+	kinds := make([]int, 0, len(ServerKinds))
+	for kind := range ServerKinds {
+		kinds = append(kinds, kind)
+	}
+
+	w.Header().Set("Content-Type", "application/nostr+json")
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"name":           "Badger",
+		"description":    "Badge events hosted by this Badger instance",
+		"supported_nips": []int{1, 9, 11, 40, 58, 65},
+		"software":       "badger",
+		"limitation": map[string]interface{}{
+			"max_message_length":     maxServerMessageSize,
+			"max_subscriptions":      maxServerSubs,
+			"max_filters":            maxServerFilters,
+			"max_limit":              defaultServerLimit,
+			"max_subid_length":       maxServerSubIDLength,
+			"max_event_tags":         maxServerEventTags,
+			"created_at_upper_limit": maxServerFutureSkew,
+			"max_message_rate":       maxServerMessageRate, // Not in NIP-11, messages per second
+		},
+		"accepted_kinds": kinds,
+	})
+}
+
+// readLoop handles client messages until the socket closes
+func (s *Server) readLoop(client *serverClient) {
+	for {
+		_, message, err := client.ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		if !client.allow(time.Now()) {
+			client.send("NOTICE", fmt.Sprintf("rate-limited: at most %d messages per second", maxServerMessageRate))
+			continue
+		}
+
+		var envelope []json.RawMessage
+		if err := json.Unmarshal(message, &envelope); err != nil || len(envelope) < 2 {
+			client.send("NOTICE", "invalid: could not parse message")
+			continue
+		}
+
+		var label string
+		if err := json.Unmarshal(envelope[0], &label); err != nil {
+			client.send("NOTICE", "invalid: message label must be a string")
+			continue
+		}
+
+		switch label {
+		case "EVENT":
+			s.handleEvent(client, envelope[1])
+		case "REQ":
+			s.handleReq(client, envelope)
+		case "CLOSE":
+			var id string
+			json.Unmarshal(envelope[1], &id)
+			client.mu.Lock()
+			delete(client.subs, id)
+			client.mu.Unlock()
+		default:
+			client.send("NOTICE", fmt.Sprintf("unsupported: unknown message %q", label))
+		}
+	}
+}
+
+func (s *Server) handleEvent(client *serverClient, raw json.RawMessage) {
+	var event nostr.Event
+	if err := json.Unmarshal(raw, &event); err != nil {
+		client.send("NOTICE", "invalid: could not parse event")
+		return
+	}
+
+	if reason := checkServerEvent(event); reason != "" {
+		client.send("OK", event.ID, false, reason)
+		return
+	}
+
+	// Add keeps only the newest replaceable version and applies NIP-09 deletions. Events it leaves out
+	// are refused with its reason and not broadcast.
+	stored := types.FromNostrEvent(event)
+	if err := s.store.Add(stored); err != nil {
+		if store.Skipped(err) {
+			client.send("OK", event.ID, false, err.Error())
+			return
+		}
+		log.Printf("Failed to store event %s from relay client: %v\n", event.ID, err)
+		client.send("OK", event.ID, false, "error: could not store event")
+		return
+	}
+	client.send("OK", event.ID, true, "")
+
+	s.broadcast(stored)
+}
+
+// checkServerEvent returns the OK rejection message for an event, or "" when it is acceptable
+func checkServerEvent(event nostr.Event) string {
+	if !ServerKinds[event.Kind] {
+		return fmt.Sprintf("blocked: kind %d is not accepted by this relay", event.Kind)
+	}
+	if len(event.Tags) > maxServerEventTags {
+		return fmt.Sprintf("invalid: events may have at most %d tags", maxServerEventTags)
+	}
+	now := time.Now().Unix()
+	if int64(event.CreatedAt) > now+maxServerFutureSkew {
+		return "invalid: created_at is too far in the future"
+	}
+	if types.FromNostrEvent(event).Expired(now) {
+		return "invalid: event has expired"
+	}
+	if event.GetID() != event.ID {
+		return "invalid: event id does not match its content"
+	}
+	valid, err := event.CheckSignature()
+	if err != nil || !valid {
+		return "invalid: bad signature"
+	}
+	return ""
+}
+
+func (s *Server) handleReq(client *serverClient, envelope []json.RawMessage) {
+	var id string
+	if err := json.Unmarshal(envelope[1], &id); err != nil || id == "" || len(id) > maxServerSubIDLength {
+		client.send("NOTICE", fmt.Sprintf("invalid: subscription id must be a string of 1 to %d characters", maxServerSubIDLength))
+		return
+	}
+
+	rawFilters := envelope[2:]
+	if len(rawFilters) == 0 || len(rawFilters) > maxServerFilters {
+		client.send("CLOSED", id, fmt.Sprintf("invalid: a REQ needs between 1 and %d filters", maxServerFilters))
+		return
+	}
+
+	filters := make([]types.SubscriptionFilter, 0, len(rawFilters))
+	for _, rawFilter := range rawFilters {
+		var filter types.SubscriptionFilter
+		if err := json.Unmarshal(rawFilter, &filter); err != nil {
+			client.send("CLOSED", id, "invalid: could not parse filter")
+			return
+		}
+		filters = append(filters, filter)
+	}
+
+	client.mu.Lock()
+	_, replacing := client.subs[id]
+	if !replacing && len(client.subs) >= maxServerSubs {
+		client.mu.Unlock()
+		client.send("CLOSED", id, "rate-limited: too many open subscriptions")
+		return
+	}
+	client.subs[id] = filters
+	client.mu.Unlock()
+
+	now := time.Now().Unix()
+	seenEventIDs := make(map[string]bool)
+	for _, filter := range filters {
+		if filter.Limit == nil || *filter.Limit > defaultServerLimit {
+			limit := defaultServerLimit
+			filter.Limit = &limit
+		}
+
+		events, err := s.store.Query(filter)
+		if err != nil {
+			log.Printf("Failed to query store for relay subscription %s: %v\n", id, err)
+			client.send("CLOSED", id, "error: could not query events")
+			return
+		}
+		for _, event := range events {
+			if seenEventIDs[event.ID] || event.Expired(now) {
+				continue
+			}
+			seenEventIDs[event.ID] = true
+			client.send("EVENT", id, event)
+		}
+	}
+	client.send("EOSE", id)
+}
+
+// broadcast sends a newly stored event to every open subscription it matches
+func (s *Server) broadcast(event types.NostrEvent) {
+	s.mu.Lock()
+	clients := make([]*serverClient, 0, len(s.clients))
+	for client := range s.clients {
+		clients = append(clients, client)
+	}
+	s.mu.Unlock()
+
+	for _, client := range clients {
+		for _, id := range client.matchingSubs(event) {
+			client.send("EVENT", id, event)
+		}
+	}
+}
+
+func (c *serverClient) matchingSubs(event types.NostrEvent) []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var ids []string
+	for id, filters := range c.subs {
+		for _, filter := range filters {
+			if store.MatchFilter(filter, event) {
+				ids = append(ids, id)
+				break
+			}
+		}
+	}
+	return ids
+}
+
+// allow counts a message against the client's rate limit and reports whether it may be handled
+func (c *serverClient) allow(now time.Time) bool {
+	if now.Sub(c.window) >= time.Second {
+		c.window = now
+		c.messages = 0
+	}
+	c.messages++
+	return c.messages <= maxServerMessageRate
+}
+
+// send writes a single relay protocol message, e.g. ["OK", id, true, ""]
+func (c *serverClient) send(message ...interface{}) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if err := c.ws.WriteJSON(message); err != nil {
+		log.Printf("Failed to write to relay client: %v\n", err)
+	}
+}
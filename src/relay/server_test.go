@@ -0,0 +1,254 @@
+package relay
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"badger/src/store"
+	"badger/src/types"
+
+	"github.com/gorilla/websocket"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// testServer runs the embedded relay on a fresh store and connects a client to it
+func testServer(t *testing.T) (*store.Store, *websocket.Conn) {
+	t.Helper()
+	st, err := store.Open(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { st.Close() })
+
+	server := httptest.NewServer(NewServer(st))
+	t.Cleanup(server.Close)
+
+	ws, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	return st, ws
+}
+
+// readMessage reads the next relay message as its label and raw fields
+func readMessage(t *testing.T, ws *websocket.Conn) (string, []json.RawMessage) {
+	t.Helper()
+	ws.SetReadDeadline(time.Now().Add(2 * time.Second))
+	var message []json.RawMessage
+	if err := ws.ReadJSON(&message); err != nil {
+		t.Fatal(err)
+	}
+	var label string
+	json.Unmarshal(message[0], &label)
+	return label, message[1:]
+}
+
+func signedEventAt(t *testing.T, kind int, createdAt nostr.Timestamp, tags nostr.Tags) nostr.Event {
+	t.Helper()
+	event := nostr.Event{Kind: kind, CreatedAt: createdAt, Tags: tags, Content: "test"}
+	if err := event.Sign(nostr.GeneratePrivateKey()); err != nil {
+		t.Fatal(err)
+	}
+	return event
+}
+
+func TestServerEventChecks(t *testing.T) {
+	now := nostr.Now()
+	expiration := nostr.Tags{{"expiration", fmt.Sprint(now - 60)}}
+
+	forgedContent := signedEventAt(t, 8, now, nostr.Tags{})
+	forgedContent.Content = "changed"
+
+	forgedAuthor := signedEventAt(t, 8, now, nostr.Tags{})
+	forgedAuthor.PubKey = signedEventAt(t, 8, now, nostr.Tags{}).PubKey
+	forgedAuthor.ID = forgedAuthor.GetID()
+
+	tests := []struct {
+		name     string
+		event    nostr.Event
+		accepted bool
+		reason   string
+	}{
+		{"valid award", signedEventAt(t, 8, now, nostr.Tags{}), true, ""},
+		{"valid definition", signedEventAt(t, 30009, now, nostr.Tags{{"d", "x"}}), true, ""},
+		{"not yet expired", signedEventAt(t, 8, now, nostr.Tags{{"expiration", fmt.Sprint(now + 3600)}}), true, ""},
+		{"other kind", signedEventAt(t, 1, now, nostr.Tags{}), false, "blocked:"},
+		{"forged content", forgedContent, false, "invalid:"},
+		{"forged author", forgedAuthor, false, "invalid:"},
+		{"expired", signedEventAt(t, 8, now, expiration), false, "invalid: event has expired"},
+		{"from the future", signedEventAt(t, 8, now+3600, nostr.Tags{}), false, "invalid: created_at"},
+	}
+
+	_, ws := testServer(t)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ws.WriteJSON([]interface{}{"EVENT", test.event})
+			label, fields := readMessage(t, ws)
+			var accepted bool
+			var reason string
+			json.Unmarshal(fields[1], &accepted)
+			json.Unmarshal(fields[2], &reason)
+			if label != "OK" || accepted != test.accepted || !strings.HasPrefix(reason, test.reason) {
+				t.Fatalf("got %s %v %q, want accepted %v with %q", label, accepted, reason, test.accepted, test.reason)
+			}
+		})
+	}
+}
+
+func TestServerRefusesSkippedEvents(t *testing.T) {
+	st, ws := testServer(t)
+	now := nostr.Now()
+	key := nostr.GeneratePrivateKey()
+	sign := func(kind int, createdAt nostr.Timestamp, tags nostr.Tags) nostr.Event {
+		event := nostr.Event{Kind: kind, CreatedAt: createdAt, Tags: tags, Content: "test"}
+		if err := event.Sign(key); err != nil {
+			t.Fatal(err)
+		}
+		return event
+	}
+
+	stored := sign(8, now, nostr.Tags{})
+	newer := sign(30009, now, nostr.Tags{{"d", "x"}})
+	older := sign(30009, now-60, nostr.Tags{{"d", "x"}})
+	removed := sign(8, now-1, nostr.Tags{})
+	deletion := sign(5, now, nostr.Tags{{"e", removed.ID}})
+	st.Save(types.FromNostrEvent(stored), types.FromNostrEvent(newer), types.FromNostrEvent(deletion))
+
+	// An open subscription would receive anything the server broadcasts
+	ws.WriteJSON([]interface{}{"REQ", "live", types.SubscriptionFilter{}})
+	for label, _ := readMessage(t, ws); label != "EOSE"; label, _ = readMessage(t, ws) {
+	}
+
+	tests := []struct {
+		name   string
+		event  nostr.Event
+		reason string
+	}{
+		{"duplicate", stored, "duplicate:"},
+		{"older version", older, "duplicate:"},
+		{"deleted", removed, "invalid:"},
+	}
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ws.WriteJSON([]interface{}{"EVENT", test.event})
+			label, fields := readMessage(t, ws)
+			var accepted bool
+			var reason string
+			json.Unmarshal(fields[1], &accepted)
+			json.Unmarshal(fields[2], &reason)
+			if label != "OK" || accepted || !strings.HasPrefix(reason, test.reason) {
+				t.Fatalf("got %s %v %q, want refused with %q", label, accepted, reason, test.reason)
+			}
+
+			// Messages are answered in order, so a broadcast would arrive before this EOSE
+			ws.WriteJSON([]interface{}{"REQ", fmt.Sprint("check", i), types.SubscriptionFilter{Kinds: []int{7}}})
+			if label, _ := readMessage(t, ws); label != "EOSE" {
+				t.Fatalf("got %s, the refused event should not be broadcast", label)
+			}
+		})
+	}
+}
+
+func TestServerServesOnlyLiveEvents(t *testing.T) {
+	st, ws := testServer(t)
+	now := nostr.Now()
+	live := signedEventAt(t, 8, now, nostr.Tags{})
+	expired := signedEventAt(t, 8, now, nostr.Tags{{"expiration", fmt.Sprint(now - 60)}})
+	definition := signedEventAt(t, 30009, now, nostr.Tags{{"d", "x"}})
+	st.Save(types.FromNostrEvent(live), types.FromNostrEvent(expired), types.FromNostrEvent(definition))
+
+	tests := []struct {
+		name   string
+		filter types.SubscriptionFilter
+		want   []string
+	}{
+		{"by kind", types.SubscriptionFilter{Kinds: []int{8}}, []string{live.ID}},
+		{"by author", types.SubscriptionFilter{Authors: []string{definition.PubKey}}, []string{definition.ID}},
+		{"by kind and author", types.SubscriptionFilter{Kinds: []int{8}, Authors: []string{definition.PubKey}}, nil},
+		{"everything", types.SubscriptionFilter{}, []string{live.ID, definition.ID}},
+	}
+	for i, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			id := fmt.Sprint("sub", i)
+			ws.WriteJSON([]interface{}{"REQ", id, test.filter})
+			got := make(map[string]bool)
+			for {
+				label, fields := readMessage(t, ws)
+				if label == "EOSE" {
+					break
+				}
+				var event nostr.Event
+				json.Unmarshal(fields[1], &event)
+				got[event.ID] = true
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+			for _, want := range test.want {
+				if !got[want] {
+					t.Fatalf("missing %s in %v", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestServerLimits(t *testing.T) {
+	_, ws := testServer(t)
+
+	tests := []struct {
+		name    string
+		message []interface{}
+		label   string
+	}{
+		{"no filters", []interface{}{"REQ", "a"}, "CLOSED"},
+		{"too many filters", append([]interface{}{"REQ", "b"}, make([]interface{}, maxServerFilters+1)...), "CLOSED"},
+		{"long subscription id", []interface{}{"REQ", strings.Repeat("x", maxServerSubIDLength+1), map[string]interface{}{}}, "NOTICE"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ws.WriteJSON(test.message)
+			if label, _ := readMessage(t, ws); label != test.label {
+				t.Fatalf("got %s, want %s", label, test.label)
+			}
+		})
+	}
+}
+
+func TestServerSubscriptionLimit(t *testing.T) {
+	_, ws := testServer(t)
+	filter := types.SubscriptionFilter{Kinds: []int{8}}
+	for i := 0; i < maxServerSubs; i++ {
+		ws.WriteJSON([]interface{}{"REQ", fmt.Sprint(i), filter})
+		if label, _ := readMessage(t, ws); label != "EOSE" {
+			t.Fatalf("subscription %d: got %s", i, label)
+		}
+		time.Sleep(time.Second / (maxServerMessageRate / 2)) // Stay under the rate limit
+	}
+	ws.WriteJSON([]interface{}{"REQ", "one too many", filter})
+	if label, _ := readMessage(t, ws); label != "CLOSED" {
+		t.Fatalf("got %s, want CLOSED", label)
+	}
+}
+
+func TestClientRateLimit(t *testing.T) {
+	client := &serverClient{}
+	start := time.Now()
+	for i := 0; i < maxServerMessageRate; i++ {
+		if !client.allow(start) {
+			t.Fatalf("message %d was rate limited", i+1)
+		}
+	}
+	if client.allow(start.Add(500 * time.Millisecond)) {
+		t.Fatal("message over the rate was allowed")
+	}
+	if !client.allow(start.Add(time.Second)) {
+		t.Fatal("rate limit did not reset after a second")
+	}
+}
@@ -0,0 +1,43 @@
+package routes
+
+import (
+	"net/http"
+
+	"badger/src/handlers"
+	"badger/src/store"
+	"badger/src/utils"
+)
+
+// adminAuditEntries is how much of the issuer audit log the admin page shows
+const adminAuditEntries = 100
+
+// AdminIssuers lists the organization issuers, their members and the audit log of their keys
+func AdminIssuers(w http.ResponseWriter, r *http.Request) {
+	publicKey, ok := handlers.RequireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	data := utils.PageData{
+		Title:          "Organization Issuers",
+		PublicKey:      publicKey,
+		IssuersEnabled: utils.AppConfig.IssuerKeyPassword != "",
+	}
+
+	if store.Default != nil {
+		issuers, err := store.Default.Issuers()
+		if err != nil {
+			utils.Errorf("Failed to list issuers: %v", err)
+			http.Error(w, "Failed to list issuers", http.StatusInternalServerError)
+			return
+		}
+		data.Issuers = issuers
+
+		data.IssuerAudit, err = store.Default.IssuerAudit("", adminAuditEntries)
+		if err != nil {
+			utils.Errorf("Failed to read issuer audit log: %v", err)
+		}
+	}
+
+	utils.RenderTemplate(w, data, "admin-issuers.html", false)
+}
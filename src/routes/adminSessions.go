@@ -0,0 +1,32 @@
+package routes
+
+import (
+	"net/http"
+
+	"badger/src/handlers"
+	"badger/src/utils"
+)
+
+// AdminSessions lists every active login, for the public keys in admin_pubkeys
+func AdminSessions(w http.ResponseWriter, r *http.Request) {
+	publicKey, ok := handlers.RequireAdmin(w, r)
+	if !ok {
+		return
+	}
+
+	sessions, err := handlers.User.Active()
+	if err != nil {
+		utils.Errorf("Failed to list sessions: %v", err)
+		http.Error(w, "Failed to list sessions", http.StatusInternalServerError)
+		return
+	}
+
+	data := utils.PageData{
+		Title:             "Active Sessions",
+		PublicKey:         publicKey,
+		Sessions:          sessions,
+		CurrentSessionKey: handlers.User.CurrentKey(r),
+	}
+
+	utils.RenderTemplate(w, data, "admin-sessions.html", false)
+}
@@ -0,0 +1,45 @@
+package routes
+
+import (
+	"badger/src/handlers"
+	"badger/src/types"
+	"badger/src/utils"
+	"net/http"
+)
+
+func AwardBadgeForm(w http.ResponseWriter, r *http.Request) {
+	session, _ := handlers.User.Get(r, "session-name")
+
+	publicKey, ok := session.Values["publicKey"].(string)
+	if !ok || publicKey == "" {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	query := r.URL.Query()
+	data := utils.PageData{
+		Title:     "Award Badge",
+		PublicKey: publicKey,
+		Badge: types.BadgeDefinition{
+			Name:     query.Get("name"),
+			ThumbURL: query.Get("thumb"),
+			DTag:     query.Get("dtag"),
+		},
+		MaxAwardRecipients: utils.AppConfig.MaxAwardRecipients,
+	}
+
+	// Badges linked by naddr are looked up instead of trusting the query string
+	if naddr := query.Get("naddr"); naddr != "" {
+		relays, _ := session.Values["relays"].(utils.RelayList)
+		badge, status, err := fetchOwnBadge(naddr, publicKey, relays)
+		if err != nil {
+			utils.Warnf("Failed to load badge to award: %v", err)
+			http.Error(w, err.Error(), status)
+			return
+		}
+		data.Badge = *badge
+	}
+
+	// Call RenderTemplate with the specific template for this route
+	utils.RenderTemplate(w, data, "award-badge.html", false)
+}
@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"badger/src/handlers"
 	"badger/src/utils"
 	"net/http"
 )
@@ -10,6 +11,13 @@ func BadgeForm(w http.ResponseWriter, r *http.Request) {
 		Title: "Badge Form Page",
 	}
 
+	// Offer the organization issuers the user may create badges as
+	session, _ := handlers.User.Get(r, "session-name")
+	if publicKey, ok := session.Values["publicKey"].(string); ok && publicKey != "" {
+		data.PublicKey = publicKey
+		data.Issuers = utils.IssuersFor(publicKey)
+	}
+
 	// Call RenderTemplate with the specific template for this route
 	utils.RenderTemplate(w, data, "badgeForm.html", false)
 }
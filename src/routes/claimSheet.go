@@ -0,0 +1,116 @@
+package routes
+
+import (
+	"html/template"
+	"net/http"
+	"strconv"
+
+	"badger/src/handlers"
+	"badger/src/types"
+	"badger/src/utils"
+)
+
+// Claim cards laid out on each printed page
+const (
+	defaultCardsPerPage = 6
+	maxCardsPerPage     = 24
+)
+
+// claimSheet is a campaign's unused claim codes split into printed pages
+type claimSheet struct {
+	Title   string
+	Badge   types.BadgeDefinition
+	Pages   [][]claimCard
+	Columns int
+}
+
+// claimCard is one cut-out card with a claim code's QR code and link
+type claimCard struct {
+	Code      string
+	URL       string
+	Remaining int
+}
+
+// ClaimSheet renders /claims/print?campaign={id}&per_page={n}: a campaign's claim codes as printable
+// cards with the badge's image and name, to print or save as PDF from the browser
+func ClaimSheet(w http.ResponseWriter, r *http.Request) {
+	session, _ := handlers.User.Get(r, "session-name")
+	publicKey, ok := session.Values["publicKey"].(string)
+	if !ok || publicKey == "" {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	campaign, err := utils.ClaimCampaignFor(publicKey, r.URL.Query().Get("campaign"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+
+	perPage := defaultCardsPerPage
+	if value := r.URL.Query().Get("per_page"); value != "" {
+		perPage, err = strconv.Atoi(value)
+		if err != nil || perPage < 1 || perPage > maxCardsPerPage {
+			http.Error(w, "Cards per page must be between 1 and 24", http.StatusBadRequest)
+			return
+		}
+	}
+
+	relays := utils.WithFallbackRelays(utils.OutboxRelays([]string{campaign.Issuer}))
+	badge, err := utils.FetchBadgeDefinition(campaign.Issuer, campaign.DTag, relays)
+	if err != nil || badge == nil {
+		utils.Warnf("Failed to fetch badge of claim campaign %s: %v", campaign.ID, err)
+		http.Error(w, "Failed to fetch badge", http.StatusBadGateway)
+		return
+	}
+
+	sheet := claimSheet{
+		Title:   badge.Name,
+		Badge:   *badge,
+		Columns: sheetColumns(perPage),
+	}
+
+	// Used up codes are left out, there's no point printing them
+	baseURL := utils.AppConfig.BaseURL(r)
+	var page []claimCard
+	for _, code := range campaign.Codes {
+		if code.Remaining() == 0 {
+			continue
+		}
+		page = append(page, claimCard{
+			Code:      code.Code,
+			URL:       baseURL + "/claim/" + code.Code,
+			Remaining: code.Remaining(),
+		})
+		if len(page) == perPage {
+			sheet.Pages = append(sheet.Pages, page)
+			page = nil
+		}
+	}
+	if len(page) > 0 {
+		sheet.Pages = append(sheet.Pages, page)
+	}
+
+	tmpl, err := template.New("").Funcs(utils.TemplateFuncs).ParseFiles("web/views/claim-sheet.html")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := tmpl.ExecuteTemplate(w, "claimSheet", sheet); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// sheetColumns picks a grid that keeps cards readable as more fit on a page
+func sheetColumns(perPage int) int {
+	switch {
+	case perPage <= 2:
+		return 1
+	case perPage <= 8:
+		return 2
+	case perPage <= 15:
+		return 3
+	default:
+		return 4
+	}
+}
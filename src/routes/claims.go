@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"badger/src/handlers"
+	"badger/src/store"
+	"badger/src/utils"
+)
+
+// ClaimCampaigns lists the user's claim campaigns with their links, and offers to start one for the
+// badge given by naddr
+func ClaimCampaigns(w http.ResponseWriter, r *http.Request) {
+	session, _ := handlers.User.Get(r, "session-name")
+	publicKey, ok := session.Values["publicKey"].(string)
+	if !ok || publicKey == "" {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	data := utils.PageData{
+		Title:          "Claim Links",
+		PublicKey:      publicKey,
+		IssuersEnabled: utils.AppConfig.IssuerKeyPassword != "",
+		BaseURL:        utils.AppConfig.BaseURL(r),
+		ClaimCampaigns: utils.ClaimCampaignsFor(publicKey),
+	}
+
+	if naddr := r.URL.Query().Get("naddr"); naddr != "" {
+		relays, _ := session.Values["relays"].(utils.RelayList)
+		badge, status, err := fetchOwnBadge(naddr, publicKey, relays)
+		if err != nil {
+			utils.Warnf("Failed to load badge for claim links: %v", err)
+			http.Error(w, err.Error(), status)
+			return
+		}
+		data.Badge = *badge
+		data.NAddr = naddr
+	}
+
+	utils.RenderTemplate(w, data, "claims.html", false)
+}
+
+// ClaimBadge renders /claim/{code}: the badge a claim code awards and how to claim it, without a login
+func ClaimBadge(w http.ResponseWriter, r *http.Request) {
+	code := store.NormalizeClaimCode(strings.TrimPrefix(r.URL.Path, "/claim/"))
+
+	campaign, err := utils.LookupClaimCode(code)
+	if errors.Is(err, store.ErrClaimNotFound) {
+		http.Error(w, "Unknown claim code", http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		utils.Errorf("Failed to look up claim code: %v", err)
+		http.Error(w, "Failed to look up claim code", http.StatusInternalServerError)
+		return
+	}
+
+	relays := utils.WithFallbackRelays(utils.OutboxRelays([]string{campaign.Issuer}))
+	badge, err := utils.FetchBadgeDefinition(campaign.Issuer, campaign.DTag, relays)
+	if err != nil || badge == nil {
+		utils.Warnf("Failed to fetch badge of claim campaign %s: %v", campaign.ID, err)
+		http.Error(w, "Failed to fetch badge", http.StatusBadGateway)
+		return
+	}
+
+	issuer, err := utils.FetchUserMetadata(campaign.Issuer, relays)
+	if err != nil {
+		utils.Warnf("Failed to fetch issuer metadata: %v", err)
+	}
+
+	// A logged in user claims with their session, everyone else signs a challenge
+	session, _ := handlers.User.Get(r, "session-name")
+	publicKey, _ := session.Values["publicKey"].(string)
+
+	data := utils.PageData{
+		Title:       "Claim " + badge.Name,
+		PublicKey:   publicKey,
+		Badge:       *badge,
+		ProfileNPub: utils.EncodeNPub(campaign.Issuer),
+		ClaimCode:   code,
+		OpenGraph: &utils.OpenGraph{
+			Title:       "Claim " + badge.Name,
+			Description: badge.Description,
+			Image:       badge.ImageURL,
+			URL:         requestURL(r),
+		},
+	}
+	if issuer != nil {
+		data.Profile = *issuer
+	}
+	switch {
+	case campaign.Expired(time.Now()):
+		data.ClaimStatus = store.ErrClaimExpired.Error()
+	case campaign.Code(code).Remaining() == 0:
+		data.ClaimStatus = store.ErrClaimUsedUp.Error()
+	}
+
+	utils.RenderTemplate(w, data, "claim-badge.html", true)
+}
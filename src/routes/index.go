@@ -18,13 +18,15 @@ func Index(w http.ResponseWriter, r *http.Request) {
 	displayName, _ := session.Values["displayName"].(string)
 	picture, _ := session.Values["picture"].(string)
 	about, _ := session.Values["about"].(string)
+	_, remoteSigner := session.Values["bunker"].(utils.BunkerSession)
 
 	data := utils.PageData{
-		Title:       "Dashboard",
-		DisplayName: displayName,
-		Picture:     picture,
-		PublicKey:   publicKey,
-		About:       about,
+		Title:        "Dashboard",
+		DisplayName:  displayName,
+		Picture:      picture,
+		PublicKey:    publicKey,
+		About:        about,
+		RemoteSigner: remoteSigner,
 	}
 
 	utils.RenderTemplate(w, data, "index.html", false)
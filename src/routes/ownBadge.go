@@ -0,0 +1,38 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+
+	"badger/src/types"
+	"badger/src/utils"
+)
+
+// fetchOwnBadge resolves an naddr to one of the logged in user's badge definitions, or of an organization
+// issuer they are a member of, returning the HTTP status to answer with when it can't
+func fetchOwnBadge(naddr, publicKey string, relays utils.RelayList) (*types.BadgeDefinition, int, error) {
+	issuer, dTag, hints, err := utils.DecodeBadgeAddress(naddr)
+	if err != nil {
+		return nil, http.StatusBadRequest, err
+	}
+	if issuer != publicKey {
+		if !utils.CanIssueAs(publicKey, issuer) {
+			return nil, http.StatusForbidden, fmt.Errorf("badge %s belongs to another user", naddr)
+		}
+		issuerRelays, err := utils.FetchUserRelays(issuer, utils.AppConfig.BootstrapRelays)
+		if err != nil {
+			return nil, http.StatusBadGateway, err
+		}
+		relays = *issuerRelays
+	}
+
+	// Definitions are on their issuer's outbox relays
+	badge, err := utils.FetchBadgeDefinition(issuer, dTag, append(relays.WriteRelays(), hints...))
+	if err != nil {
+		return nil, http.StatusBadGateway, err
+	}
+	if badge == nil {
+		return nil, http.StatusNotFound, fmt.Errorf("badge %s not found", naddr)
+	}
+	return badge, http.StatusOK, nil
+}
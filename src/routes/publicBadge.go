@@ -0,0 +1,75 @@
+package routes
+
+import (
+	"net/http"
+	"strings"
+
+	"badger/src/utils"
+)
+
+// PublicBadge renders /b/{naddr}: a badge definition, its issuer and recipients, without a login
+func PublicBadge(w http.ResponseWriter, r *http.Request) {
+	naddr := strings.TrimPrefix(r.URL.Path, "/b/")
+
+	issuerKey, dTag, hints, err := utils.DecodeBadgeAddress(naddr)
+	if err != nil {
+		http.Error(w, "Invalid badge address", http.StatusBadRequest)
+		return
+	}
+
+	// Relay hints in the naddr come first, the issuer's outbox relays cover links without hints
+	relays := utils.PublicPageRelays(hints, issuerKey)
+	defer utils.ReleaseRelays(relays)
+
+	badge, err := utils.FetchBadgeDefinition(issuerKey, dTag, relays)
+	if err != nil {
+		http.Error(w, "Failed to fetch badge", http.StatusBadGateway)
+		return
+	}
+	if badge == nil {
+		http.Error(w, "Badge not found", http.StatusNotFound)
+		return
+	}
+
+	issuer, err := utils.FetchUserMetadata(issuerKey, relays)
+	if err != nil {
+		utils.Warnf("Failed to fetch issuer metadata: %v", err)
+	}
+
+	pubKeys, err := utils.FetchBadgeRecipients(issuerKey, dTag, relays)
+	if err != nil {
+		utils.Warnf("Failed to fetch badge recipients: %v", err)
+	}
+	var recipients []string
+	for _, pubKey := range pubKeys {
+		recipients = append(recipients, utils.EncodeNPub(pubKey))
+	}
+
+	issuerNPub := utils.EncodeNPub(issuerKey)
+	data := utils.PageData{
+		Title:       badge.Name,
+		ProfileNPub: issuerNPub,
+		PublicBadges: []utils.BadgeLink{{
+			BadgeDefinition: *badge,
+			NAddr:           naddr,
+			IssuerNPub:      issuerNPub,
+		}},
+		Recipients: recipients,
+		OpenGraph: &utils.OpenGraph{
+			Title:       badge.Name,
+			Description: badge.Description,
+			Image:       badge.ImageURL,
+			URL:         requestURL(r),
+		},
+	}
+	if issuer != nil {
+		data.Profile = *issuer
+	}
+
+	utils.RenderTemplate(w, data, "public-badge.html", true)
+}
+
+// requestURL is the absolute URL of a request for og:url
+func requestURL(r *http.Request) string {
+	return utils.AppConfig.BaseURL(r) + r.URL.Path
+}
@@ -0,0 +1,75 @@
+package routes
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"badger/src/utils"
+)
+
+// PublicProfile renders /p/{npub}: the badges someone accepted onto their profile, without a login
+func PublicProfile(w http.ResponseWriter, r *http.Request) {
+	npub := strings.TrimPrefix(r.URL.Path, "/p/")
+
+	publicKey, hints, err := utils.DecodePubKey(npub)
+	if err != nil {
+		http.Error(w, "Invalid profile address", http.StatusBadRequest)
+		return
+	}
+	npub = utils.EncodeNPub(publicKey)
+
+	// Profile badges and metadata are on the user's outbox relays
+	relays := utils.PublicPageRelays(hints, publicKey)
+	defer utils.ReleaseRelays(relays)
+
+	profile, err := utils.FetchUserMetadata(publicKey, relays)
+	if err != nil {
+		utils.Warnf("Failed to fetch profile metadata: %v", err)
+		profile = nil
+	}
+
+	profileBadges, err := utils.FetchProfileBadges(publicKey, relays)
+	if err != nil {
+		http.Error(w, "Failed to fetch profile badges", http.StatusBadGateway)
+		return
+	}
+	badgeDefinitions, _ := utils.FetchBadgeDefinitions(profileBadges, relays)
+
+	// Keep the order the user chose, skipping badges whose definition is gone
+	var badges []utils.BadgeLink
+	for _, event := range profileBadges {
+		for _, badge := range event.Badges {
+			definition, found := badgeDefinitions[fmt.Sprintf("%s:%s", badge.BadgeAwardedBy, badge.BadgeAwardDTag)]
+			if !found {
+				continue
+			}
+			badges = append(badges, utils.BadgeLink{
+				BadgeDefinition: definition,
+				NAddr:           utils.BadgeNAddr(badge.BadgeAwardedBy, badge.BadgeAwardDTag, utils.RelayList{}),
+				IssuerNPub:      utils.EncodeNPub(badge.BadgeAwardedBy),
+			})
+		}
+	}
+
+	name := npub
+	data := utils.PageData{
+		ProfileNPub:  npub,
+		PublicBadges: badges,
+	}
+	if profile != nil {
+		data.Profile = *profile
+		if profile.DisplayName != "" {
+			name = profile.DisplayName
+		}
+	}
+	data.Title = name + "'s Badges"
+	data.OpenGraph = &utils.OpenGraph{
+		Title:       data.Title,
+		Description: fmt.Sprintf("%d badges on their nostr profile", len(badges)),
+		Image:       data.Profile.Picture,
+		URL:         requestURL(r),
+	}
+
+	utils.RenderTemplate(w, data, "public-profile.html", true)
+}
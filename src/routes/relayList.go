@@ -1,7 +1,6 @@
 package routes
 
 import (
-	"log"
 	"net/http"
 
 	"badger/src/handlers"
@@ -9,7 +8,7 @@ import (
 )
 
 func RelayList(w http.ResponseWriter, r *http.Request) {
-	log.Println("RelayListHandler called")
+	utils.Debugf("RelayListHandler called")
 
 	session, _ := handlers.User.Get(r, "session-name")
 
@@ -22,7 +21,7 @@ func RelayList(w http.ResponseWriter, r *http.Request) {
 	// Fetch the relay list from the session
 	relays, ok := session.Values["relays"].(utils.RelayList)
 	if !ok {
-		log.Println("No relay list found in session")
+		utils.Warnf("No relay list found in session")
 		http.Error(w, "Relay list not found", http.StatusInternalServerError)
 		return
 	}
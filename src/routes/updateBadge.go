@@ -1,13 +1,48 @@
 package routes
 
 import (
+	"badger/src/handlers"
+	"badger/src/types"
 	"badger/src/utils"
 	"net/http"
 )
 
 func UpdateBadgeForm(w http.ResponseWriter, r *http.Request) {
+	session, _ := handlers.User.Get(r, "session-name")
+
+	publicKey, ok := session.Values["publicKey"].(string)
+	if !ok || publicKey == "" {
+		http.Redirect(w, r, "/login", http.StatusSeeOther)
+		return
+	}
+
+	// Older links carry the whole definition in the query string
+	query := r.URL.Query()
+	badgeID, _, _ := utils.DecodeEventID(query.Get("badgeId"))
+	badge := types.BadgeDefinition{
+		NostrEvent:  types.NostrEvent{ID: badgeID},
+		Name:        query.Get("name"),
+		Description: query.Get("description"),
+		ImageURL:    query.Get("image"),
+		ThumbURL:    query.Get("thumb"),
+		DTag:        query.Get("dtag"),
+	}
+
+	if naddr := query.Get("naddr"); naddr != "" {
+		relays, _ := session.Values["relays"].(utils.RelayList)
+		fetched, status, err := fetchOwnBadge(naddr, publicKey, relays)
+		if err != nil {
+			utils.Warnf("Failed to load badge to update: %v", err)
+			http.Error(w, err.Error(), status)
+			return
+		}
+		badge = *fetched
+	}
+
 	data := utils.PageData{
-		Title: "Update Badge",
+		Title:     "Update Badge",
+		PublicKey: publicKey,
+		Badge:     badge,
 	}
 
 	// Call RenderTemplate with the specific template for this route
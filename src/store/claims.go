@@ -0,0 +1,295 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	claimCampaignsBucket = []byte("claim_campaigns") // campaign id -> campaign JSON
+	claimCodesBucket     = []byte("claim_codes")     // claim code -> campaign id
+)
+
+// Reasons a claim code can't be redeemed
+var (
+	ErrClaimNotFound   = errors.New("unknown claim code")
+	ErrClaimExpired    = errors.New("this claim link has expired")
+	ErrClaimUsedUp     = errors.New("this claim code has already been used")
+	ErrAlreadyClaimed  = errors.New("you have already claimed this badge")
+	ErrClaimInProgress = errors.New("this claim is still being published")
+)
+
+// ClaimCampaign hands out codes that let people award themselves one badge definition
+type ClaimCampaign struct {
+	ID        string      `json:"id"`
+	Issuer    string      `json:"issuer"` // Organization issuer the awards are signed by
+	DTag      string      `json:"dtag"`
+	BadgeName string      `json:"badge_name"`
+	CreatedBy string      `json:"created_by"` // Member of the issuer who started the campaign
+	CreatedAt time.Time   `json:"created_at"`
+	ExpiresAt time.Time   `json:"expires_at"` // Zero when the codes never expire
+	Codes     []ClaimCode `json:"codes"`
+	Claims    []Claim     `json:"claims"`
+}
+
+// ClaimCode is one code of a campaign and how often it was redeemed
+type ClaimCode struct {
+	Code    string `json:"code"`
+	MaxUses int    `json:"max_uses"`
+	Uses    int    `json:"uses"`
+}
+
+// Claim is a badge awarded through a claim code
+type Claim struct {
+	PubKey  string    `json:"pubkey"`
+	Code    string    `json:"code"`
+	EventID string    `json:"event_id"` // Empty while the award is being published
+	Time    time.Time `json:"time"`     // When the code was redeemed, so also when the claim went pending
+}
+
+// Expired reports whether the campaign's codes can no longer be redeemed at the given time
+func (campaign ClaimCampaign) Expired(at time.Time) bool {
+	return !campaign.ExpiresAt.IsZero() && !campaign.ExpiresAt.After(at)
+}
+
+// Code returns the campaign's code, or nil when it isn't one of them
+func (campaign *ClaimCampaign) Code(code string) *ClaimCode {
+	for i := range campaign.Codes {
+		if campaign.Codes[i].Code == code {
+			return &campaign.Codes[i]
+		}
+	}
+	return nil
+}
+
+// Remaining is how many more times the code can be redeemed
+func (code ClaimCode) Remaining() int {
+	return max(0, code.MaxUses-code.Uses)
+}
+
+// SaveClaimCampaign creates or replaces a campaign and indexes its codes
+func (s *Store) SaveClaimCampaign(campaign ClaimCampaign) error {
+	data, err := json.Marshal(campaign)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		codes := tx.Bucket(claimCodesBucket)
+		for _, code := range campaign.Codes {
+			if existing := codes.Get([]byte(code.Code)); existing != nil && string(existing) != campaign.ID {
+				return fmt.Errorf("claim code %s is already in use", code.Code)
+			}
+			if err := codes.Put([]byte(code.Code), []byte(campaign.ID)); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(claimCampaignsBucket).Put([]byte(campaign.ID), data)
+	})
+}
+
+// LoadClaimCampaign returns the campaign with the id, reporting false when there is none
+func (s *Store) LoadClaimCampaign(id string) (ClaimCampaign, bool, error) {
+	var campaign ClaimCampaign
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		var err error
+		campaign, found, err = loadClaimCampaign(tx, []byte(id))
+		return err
+	})
+	if err != nil {
+		return ClaimCampaign{}, false, fmt.Errorf("failed to load claim campaign: %v", err)
+	}
+	return campaign, found, nil
+}
+
+// ClaimCampaignByCode returns the campaign a claim code belongs to
+func (s *Store) ClaimCampaignByCode(code string) (ClaimCampaign, bool, error) {
+	code = NormalizeClaimCode(code)
+	var campaign ClaimCampaign
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		id := tx.Bucket(claimCodesBucket).Get([]byte(code))
+		if id == nil {
+			return nil
+		}
+		var err error
+		campaign, found, err = loadClaimCampaign(tx, id)
+		return err
+	})
+	if err != nil {
+		return ClaimCampaign{}, false, fmt.Errorf("failed to load claim campaign: %v", err)
+	}
+	return campaign, found, nil
+}
+
+// ClaimCampaigns lists the campaigns started by a user, newest first
+func (s *Store) ClaimCampaigns(createdBy string) ([]ClaimCampaign, error) {
+	var campaigns []ClaimCampaign
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(claimCampaignsBucket).ForEach(func(_, data []byte) error {
+			var campaign ClaimCampaign
+			if err := json.Unmarshal(data, &campaign); err != nil {
+				return nil
+			}
+			if campaign.CreatedBy == createdBy {
+				campaigns = append(campaigns, campaign)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list claim campaigns: %v", err)
+	}
+
+	sort.Slice(campaigns, func(i, j int) bool {
+		return campaigns[i].CreatedAt.After(campaigns[j].CreatedAt)
+	})
+	return campaigns, nil
+}
+
+// DeleteClaimCampaign removes a campaign, its codes stop working immediately
+func (s *Store) DeleteClaimCampaign(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		campaign, found, err := loadClaimCampaign(tx, []byte(id))
+		if err != nil || !found {
+			return err
+		}
+		for _, code := range campaign.Codes {
+			if err := tx.Bucket(claimCodesBucket).Delete([]byte(code.Code)); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(claimCampaignsBucket).Delete([]byte(id))
+	})
+}
+
+// RedeemClaimCode uses up one redemption of the code for the public key. The claim stays pending until
+// CompleteClaim records its award, or ReleaseClaim gives the redemption back. Claims still pending after
+// pendingFor are taken as released, so a crash while publishing doesn't use up the code for good.
+func (s *Store) RedeemClaimCode(code, pubKey string, at time.Time, pendingFor time.Duration) (ClaimCampaign, error) {
+	code = NormalizeClaimCode(code)
+	var campaign ClaimCampaign
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		id := tx.Bucket(claimCodesBucket).Get([]byte(code))
+		if id == nil {
+			return ErrClaimNotFound
+		}
+		var found bool
+		var err error
+		campaign, found, err = loadClaimCampaign(tx, id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return ErrClaimNotFound
+		}
+
+		if campaign.Expired(at) {
+			return ErrClaimExpired
+		}
+		campaign.releaseStaleClaims(at.Add(-pendingFor))
+		for _, claim := range campaign.Claims {
+			if claim.PubKey != pubKey {
+				continue
+			}
+			if claim.EventID == "" {
+				return ErrClaimInProgress
+			}
+			return ErrAlreadyClaimed
+		}
+		claimCode := campaign.Code(code)
+		if claimCode == nil {
+			return ErrClaimNotFound
+		}
+		if claimCode.Remaining() == 0 {
+			return ErrClaimUsedUp
+		}
+
+		claimCode.Uses++
+		campaign.Claims = append(campaign.Claims, Claim{PubKey: pubKey, Code: code, Time: at})
+		return putClaimCampaign(tx, campaign)
+	})
+	if err != nil {
+		return ClaimCampaign{}, err
+	}
+	return campaign, nil
+}
+
+// CompleteClaim records the award event published for a pending claim
+func (s *Store) CompleteClaim(campaignID, pubKey, eventID string) error {
+	return s.updateClaim(campaignID, pubKey, func(campaign *ClaimCampaign, i int) {
+		campaign.Claims[i].EventID = eventID
+	})
+}
+
+// ReleaseClaim drops a pending claim whose award couldn't be published, so the code can be used again
+func (s *Store) ReleaseClaim(campaignID, pubKey string) error {
+	return s.updateClaim(campaignID, pubKey, func(campaign *ClaimCampaign, i int) {
+		if code := campaign.Code(campaign.Claims[i].Code); code != nil && code.Uses > 0 {
+			code.Uses--
+		}
+		campaign.Claims = append(campaign.Claims[:i], campaign.Claims[i+1:]...)
+	})
+}
+
+// releaseStaleClaims gives back the redemptions of claims that went pending before the given time
+func (campaign *ClaimCampaign) releaseStaleClaims(before time.Time) {
+	claims := campaign.Claims[:0]
+	for _, claim := range campaign.Claims {
+		if claim.EventID == "" && claim.Time.Before(before) {
+			if code := campaign.Code(claim.Code); code != nil && code.Uses > 0 {
+				code.Uses--
+			}
+			continue
+		}
+		claims = append(claims, claim)
+	}
+	campaign.Claims = claims
+}
+
+func (s *Store) updateClaim(campaignID, pubKey string, update func(campaign *ClaimCampaign, i int)) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		campaign, found, err := loadClaimCampaign(tx, []byte(campaignID))
+		if err != nil || !found {
+			return err
+		}
+		for i, claim := range campaign.Claims {
+			if claim.PubKey == pubKey {
+				update(&campaign, i)
+				return putClaimCampaign(tx, campaign)
+			}
+		}
+		return nil
+	})
+}
+
+// NormalizeClaimCode trims and lowercases a code as typed or scanned, codes are stored lowercase
+func NormalizeClaimCode(code string) string {
+	return strings.ToLower(strings.TrimSpace(code))
+}
+
+func loadClaimCampaign(tx *bolt.Tx, id []byte) (ClaimCampaign, bool, error) {
+	data := tx.Bucket(claimCampaignsBucket).Get(id)
+	if data == nil {
+		return ClaimCampaign{}, false, nil
+	}
+	var campaign ClaimCampaign
+	if err := json.Unmarshal(data, &campaign); err != nil {
+		return ClaimCampaign{}, false, err
+	}
+	return campaign, true, nil
+}
+
+func putClaimCampaign(tx *bolt.Tx, campaign ClaimCampaign) error {
+	data, err := json.Marshal(campaign)
+	if err != nil {
+		return err
+	}
+	return tx.Bucket(claimCampaignsBucket).Put([]byte(campaign.ID), data)
+}
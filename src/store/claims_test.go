@@ -0,0 +1,185 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func testCampaign(t *testing.T, s *Store, expiresAt time.Time) ClaimCampaign {
+	t.Helper()
+	campaign := ClaimCampaign{
+		ID:        "campaign",
+		Issuer:    "issuer",
+		DTag:      "badge",
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+		Codes: []ClaimCode{
+			{Code: "single", MaxUses: 1},
+			{Code: "shared", MaxUses: 3},
+		},
+	}
+	if err := s.SaveClaimCampaign(campaign); err != nil {
+		t.Fatal(err)
+	}
+	return campaign
+}
+
+func TestRedeemClaimCode(t *testing.T) {
+	now := time.Now()
+	const pendingFor = time.Minute
+
+	// step is one action against the campaign and the error it should end with
+	type step struct {
+		action string // "redeem", "complete", "release" or "wait" (until pending claims are stale)
+		code   string
+		pubKey string
+		err    error
+	}
+	tests := []struct {
+		name      string
+		expiresAt time.Time
+		steps     []step
+	}{
+		{"single use code is used up", time.Time{}, []step{
+			{"redeem", "single", "alice", nil},
+			{"complete", "", "alice", nil},
+			{"redeem", "single", "bob", ErrClaimUsedUp},
+		}},
+		{"shared code runs out", time.Time{}, []step{
+			{"redeem", "shared", "alice", nil},
+			{"redeem", "shared", "bob", nil},
+			{"redeem", "shared", "carol", nil},
+			{"redeem", "shared", "dave", ErrClaimUsedUp},
+		}},
+		{"double claim with the same code", time.Time{}, []step{
+			{"redeem", "shared", "alice", nil},
+			{"complete", "", "alice", nil},
+			{"redeem", "shared", "alice", ErrAlreadyClaimed},
+		}},
+		{"double claim with another code", time.Time{}, []step{
+			{"redeem", "single", "alice", nil},
+			{"complete", "", "alice", nil},
+			{"redeem", "shared", "alice", ErrAlreadyClaimed},
+		}},
+		{"claim still publishing", time.Time{}, []step{
+			{"redeem", "shared", "alice", nil},
+			{"redeem", "shared", "alice", ErrClaimInProgress},
+		}},
+		{"released claim frees the code", time.Time{}, []step{
+			{"redeem", "single", "alice", nil},
+			{"release", "", "alice", nil},
+			{"redeem", "single", "bob", nil},
+		}},
+		{"released claimant may try again", time.Time{}, []step{
+			{"redeem", "shared", "alice", nil},
+			{"release", "", "alice", nil},
+			{"redeem", "shared", "alice", nil},
+		}},
+		{"abandoned claim may be retried", time.Time{}, []step{
+			{"redeem", "single", "alice", nil},
+			{"wait", "", "", nil},
+			{"redeem", "single", "alice", nil},
+		}},
+		{"abandoned claim frees the code", time.Time{}, []step{
+			{"redeem", "single", "alice", nil},
+			{"wait", "", "", nil},
+			{"redeem", "single", "bob", nil},
+			{"redeem", "single", "carol", ErrClaimUsedUp},
+		}},
+		{"completed claims stay", time.Time{}, []step{
+			{"redeem", "single", "alice", nil},
+			{"complete", "", "alice", nil},
+			{"wait", "", "", nil},
+			{"redeem", "single", "bob", ErrClaimUsedUp},
+			{"redeem", "shared", "alice", ErrAlreadyClaimed},
+		}},
+		{"codes are case insensitive", time.Time{}, []step{
+			{"redeem", " SINGLE ", "alice", nil},
+			{"redeem", "Single", "bob", ErrClaimUsedUp},
+		}},
+		{"unknown code", time.Time{}, []step{
+			{"redeem", "missing", "alice", ErrClaimNotFound},
+			{"redeem", "", "alice", ErrClaimNotFound},
+		}},
+		{"expired campaign", now.Add(-time.Minute), []step{
+			{"redeem", "shared", "alice", ErrClaimExpired},
+		}},
+		{"campaign expiring later", now.Add(time.Hour), []step{
+			{"redeem", "shared", "alice", nil},
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := testStore(t)
+			campaign := testCampaign(t, s, test.expiresAt)
+
+			at := now
+			for i, step := range test.steps {
+				var err error
+				switch step.action {
+				case "redeem":
+					_, err = s.RedeemClaimCode(step.code, step.pubKey, at, pendingFor)
+				case "complete":
+					err = s.CompleteClaim(campaign.ID, step.pubKey, "award-"+step.pubKey)
+				case "release":
+					err = s.ReleaseClaim(campaign.ID, step.pubKey)
+				case "wait":
+					at = at.Add(2 * pendingFor)
+				}
+				if !errors.Is(err, step.err) {
+					t.Fatalf("step %d %s %q by %s: got %v, want %v", i, step.action, step.code, step.pubKey, err, step.err)
+				}
+			}
+		})
+	}
+}
+
+func TestRedeemClaimCodeRecordsUses(t *testing.T) {
+	s := testStore(t)
+	testCampaign(t, s, time.Time{})
+
+	if _, err := s.RedeemClaimCode("shared", "alice", time.Now(), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.CompleteClaim("campaign", "alice", "award"); err != nil {
+		t.Fatal(err)
+	}
+
+	campaign, found, err := s.LoadClaimCampaign("campaign")
+	if err != nil || !found {
+		t.Fatalf("campaign not found: %v", err)
+	}
+	if code := campaign.Code("shared"); code.Uses != 1 || code.Remaining() != 2 {
+		t.Fatalf("code has %d uses and %d remaining", code.Uses, code.Remaining())
+	}
+	if len(campaign.Claims) != 1 || campaign.Claims[0].PubKey != "alice" || campaign.Claims[0].EventID != "award" {
+		t.Fatalf("claims = %+v", campaign.Claims)
+	}
+}
+
+func TestRedeemClaimCodeConcurrently(t *testing.T) {
+	s := testStore(t)
+	testCampaign(t, s, time.Time{})
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	redeemed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(pubKey string) {
+			defer wg.Done()
+			if _, err := s.RedeemClaimCode("shared", pubKey, time.Now(), time.Minute); err == nil {
+				mu.Lock()
+				redeemed++
+				mu.Unlock()
+			}
+		}(fmt.Sprint("user", i))
+	}
+	wg.Wait()
+	if redeemed != 3 {
+		t.Fatalf("%d redemptions of a code with 3 uses", redeemed)
+	}
+}
@@ -0,0 +1,56 @@
+package store
+
+import "badger/src/types"
+
+// MatchFilter reports whether an event satisfies a NIP-01 subscription filter (ignoring limit)
+func MatchFilter(filter types.SubscriptionFilter, event types.NostrEvent) bool {
+	if len(filter.IDs) > 0 && !contains(filter.IDs, event.ID) {
+		return false
+	}
+	if len(filter.Authors) > 0 && !contains(filter.Authors, event.PubKey) {
+		return false
+	}
+	if len(filter.Kinds) > 0 {
+		found := false
+		for _, kind := range filter.Kinds {
+			if kind == event.Kind {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if filter.Since != nil && event.CreatedAt < *filter.Since {
+		return false
+	}
+	if filter.Until != nil && event.CreatedAt > *filter.Until {
+		return false
+	}
+
+	// Every tag filter must match at least one of the event's tags with that name
+	for name, values := range filter.Tags {
+		found := false
+		for _, tag := range event.Tags {
+			if len(tag) > 1 && tag[0] == name && contains(values, tag[1]) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
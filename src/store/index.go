@@ -0,0 +1,123 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"log"
+
+	"badger/src/types"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	byKindBucket   = []byte("by_kind")   // 4 byte kind + id -> nothing
+	byAuthorBucket = []byte("by_author") // pubkey + id -> nothing
+)
+
+func kindKey(kind int, id string) []byte {
+	key := make([]byte, 4, 4+len(id))
+	binary.BigEndian.PutUint32(key, uint32(kind))
+	return append(key, id...)
+}
+
+func authorKey(pubKey, id string) []byte {
+	return []byte(pubKey + id)
+}
+
+// putEvent stores an event and its index entries
+func putEvent(tx *bolt.Tx, event types.NostrEvent) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	if err := tx.Bucket(eventsBucket).Put([]byte(event.ID), data); err != nil {
+		return err
+	}
+	if err := tx.Bucket(byKindBucket).Put(kindKey(event.Kind, event.ID), nil); err != nil {
+		return err
+	}
+	return tx.Bucket(byAuthorBucket).Put(authorKey(event.PubKey, event.ID), nil)
+}
+
+// deleteEvent removes an event and its index entries
+func deleteEvent(tx *bolt.Tx, event types.NostrEvent) error {
+	if err := tx.Bucket(eventsBucket).Delete([]byte(event.ID)); err != nil {
+		return err
+	}
+	if err := tx.Bucket(byKindBucket).Delete(kindKey(event.Kind, event.ID)); err != nil {
+		return err
+	}
+	return tx.Bucket(byAuthorBucket).Delete(authorKey(event.PubKey, event.ID))
+}
+
+// candidateIDs returns the ids of the events a filter can match according to the kind or author index,
+// whichever is smaller, and false when the filter names neither so every event has to be scanned
+func candidateIDs(tx *bolt.Tx, filter types.SubscriptionFilter) ([][]byte, bool) {
+	var byKind, byAuthor [][]byte
+	if len(filter.Kinds) > 0 {
+		for _, kind := range filter.Kinds {
+			byKind = append(byKind, indexScan(tx.Bucket(byKindBucket), kindKey(kind, ""))...)
+		}
+	}
+	if len(filter.Authors) > 0 {
+		for _, author := range filter.Authors {
+			byAuthor = append(byAuthor, indexScan(tx.Bucket(byAuthorBucket), authorKey(author, ""))...)
+		}
+	}
+
+	switch {
+	case len(filter.Authors) > 0 && (len(filter.Kinds) == 0 || len(byAuthor) <= len(byKind)):
+		return byAuthor, true
+	case len(filter.Kinds) > 0:
+		return byKind, true
+	}
+	return nil, false
+}
+
+// indexScan returns the ids under an index prefix
+func indexScan(bucket *bolt.Bucket, prefix []byte) [][]byte {
+	var ids [][]byte
+	cursor := bucket.Cursor()
+	for k, _ := cursor.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = cursor.Next() {
+		ids = append(ids, append([]byte(nil), k[len(prefix):]...))
+	}
+	return ids
+}
+
+// reindex builds the indexes of a store created before they existed, dropping events that fail
+// verification on the way since older versions stored them without checking
+func reindex(tx *bolt.Tx) error {
+	eventsB := tx.Bucket(eventsBucket)
+
+	var events []types.NostrEvent
+	var forged [][]byte
+	err := eventsB.ForEach(func(id, data []byte) error {
+		var event types.NostrEvent
+		if err := json.Unmarshal(data, &event); err != nil || event.Verify() != nil {
+			forged = append(forged, append([]byte(nil), id...))
+			return nil
+		}
+		events = append(events, event)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, id := range forged {
+		if err := eventsB.Delete(id); err != nil {
+			return err
+		}
+	}
+	for _, event := range events {
+		if err := putEvent(tx, event); err != nil {
+			return err
+		}
+	}
+	if len(forged) > 0 {
+		log.Printf("Dropped %d stored events that failed verification\n", len(forged))
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+package store
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	issuersBucket     = []byte("issuers")      // issuer pubkey -> issuer JSON
+	issuerAuditBucket = []byte("issuer_audit") // sequence number -> audit entry JSON
+)
+
+// Issuer is an organization key Badger signs badge events with on behalf of its members
+type Issuer struct {
+	PubKey    string    `json:"pubkey"`
+	Name      string    `json:"name"`
+	NCryptSec string    `json:"ncryptsec"` // The secret key encrypted with the instance's issuer password (NIP-49)
+	Members   []string  `json:"members"`   // Users allowed to create definitions and awards as this issuer
+	CreatedAt time.Time `json:"created_at"`
+	CreatedBy string    `json:"created_by"`
+}
+
+// HasMember reports whether the user may sign as the issuer
+func (issuer Issuer) HasMember(publicKey string) bool {
+	for _, member := range issuer.Members {
+		if member == publicKey {
+			return true
+		}
+	}
+	return false
+}
+
+// IssuerAuditEntry records one use or change of an issuer key
+type IssuerAuditEntry struct {
+	Time    time.Time `json:"time"`
+	Issuer  string    `json:"issuer"`
+	Actor   string    `json:"actor"`  // The logged in user who used or changed the key
+	Action  string    `json:"action"` // import, sign, publish, add-member, remove-member or delete
+	Kind    int       `json:"kind,omitempty"`
+	EventID string    `json:"event_id,omitempty"`
+	Detail  string    `json:"detail,omitempty"`
+}
+
+// SaveIssuer creates or replaces an issuer
+func (s *Store) SaveIssuer(issuer Issuer) error {
+	data, err := json.Marshal(issuer)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(issuersBucket).Put([]byte(issuer.PubKey), data)
+	})
+}
+
+// LoadIssuer returns the issuer with the public key, reporting false when there is none
+func (s *Store) LoadIssuer(pubKey string) (Issuer, bool, error) {
+	var issuer Issuer
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(issuersBucket).Get([]byte(pubKey))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &issuer)
+	})
+	if err != nil {
+		return Issuer{}, false, fmt.Errorf("failed to load issuer: %v", err)
+	}
+	return issuer, found, nil
+}
+
+// DeleteIssuer removes an issuer and its encrypted key, the audit log is kept
+func (s *Store) DeleteIssuer(pubKey string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(issuersBucket).Delete([]byte(pubKey))
+	})
+}
+
+// Issuers lists every issuer by name
+func (s *Store) Issuers() ([]Issuer, error) {
+	var issuers []Issuer
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(issuersBucket).ForEach(func(_, data []byte) error {
+			var issuer Issuer
+			if err := json.Unmarshal(data, &issuer); err != nil {
+				return nil
+			}
+			issuers = append(issuers, issuer)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list issuers: %v", err)
+	}
+
+	sort.Slice(issuers, func(i, j int) bool { return issuers[i].Name < issuers[j].Name })
+	return issuers, nil
+}
+
+// AppendIssuerAudit adds an entry to the issuer audit log
+func (s *Store) AppendIssuerAudit(entry IssuerAuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(issuerAuditBucket)
+		sequence, err := bucket.NextSequence()
+		if err != nil {
+			return err
+		}
+		key := make([]byte, 8)
+		binary.BigEndian.PutUint64(key, sequence)
+		return bucket.Put(key, data)
+	})
+}
+
+// IssuerAudit returns up to limit audit entries, newest first, for one issuer or all when issuer is ""
+func (s *Store) IssuerAudit(issuer string, limit int) ([]IssuerAuditEntry, error) {
+	var entries []IssuerAuditEntry
+	err := s.db.View(func(tx *bolt.Tx) error {
+		cursor := tx.Bucket(issuerAuditBucket).Cursor()
+		for key, data := cursor.Last(); key != nil && len(entries) < limit; key, data = cursor.Prev() {
+			var entry IssuerAuditEntry
+			if err := json.Unmarshal(data, &entry); err != nil {
+				continue
+			}
+			if issuer == "" || entry.Issuer == issuer {
+				entries = append(entries, entry)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read issuer audit log: %v", err)
+	}
+	return entries, nil
+}
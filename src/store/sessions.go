@@ -0,0 +1,142 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var sessionsBucket = []byte("sessions") // session key -> session JSON
+
+// Session is a login kept on the server, the browser only holds its signed id
+type Session struct {
+	Key        string    `json:"key"`    // SHA-256 of the session id, so the database never holds usable ids
+	PubKey     string    `json:"pubkey"` // Logged in user, empty before login
+	Values     []byte    `json:"values"` // gob encoded session values
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeen   time.Time `json:"last_seen"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	UserAgent  string    `json:"user_agent"`
+	RemoteAddr string    `json:"remote_addr"`
+}
+
+// Expired reports whether the session can no longer be used at the given time
+func (session Session) Expired(at time.Time) bool {
+	return !session.ExpiresAt.After(at)
+}
+
+// SaveSession creates or replaces a session
+func (s *Store) SaveSession(session Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).Put([]byte(session.Key), data)
+	})
+}
+
+// LoadSession returns the session stored under key, reporting false when it is missing or expired
+func (s *Store) LoadSession(key string) (Session, bool, error) {
+	var session Session
+	var found bool
+	err := s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(sessionsBucket).Get([]byte(key))
+		if data == nil {
+			return nil
+		}
+		found = true
+		return json.Unmarshal(data, &session)
+	})
+	if err != nil {
+		return Session{}, false, fmt.Errorf("failed to load session: %v", err)
+	}
+	if !found || session.Expired(time.Now()) {
+		return Session{}, false, nil
+	}
+	return session, true, nil
+}
+
+// DeleteSession removes a single session, returning what it held (empty when there was none)
+func (s *Store) DeleteSession(key string) (Session, error) {
+	var session Session
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(sessionsBucket)
+		if data := bucket.Get([]byte(key)); data != nil {
+			json.Unmarshal(data, &session)
+		}
+		return bucket.Delete([]byte(key))
+	})
+	return session, err
+}
+
+// DeleteUserSessions logs a user out of every device, returning the removed sessions
+func (s *Store) DeleteUserSessions(pubKey string) ([]Session, error) {
+	return s.deleteSessions(func(session Session) bool { return session.PubKey == pubKey })
+}
+
+// PruneSessions removes the sessions that expired before the given time, returning them
+func (s *Store) PruneSessions(at time.Time) ([]Session, error) {
+	return s.deleteSessions(func(session Session) bool { return session.Expired(at) })
+}
+
+func (s *Store) deleteSessions(match func(Session) bool) ([]Session, error) {
+	var deleted []Session
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(sessionsBucket)
+
+		// Collect first, deleting while iterating a bbolt cursor skips keys
+		var keys [][]byte
+		err := bucket.ForEach(func(key, data []byte) error {
+			var session Session
+			if err := json.Unmarshal(data, &session); err != nil || match(session) {
+				keys = append(keys, append([]byte{}, key...))
+				deleted = append(deleted, session)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, key := range keys {
+			if err := bucket.Delete(key); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deleted, nil
+}
+
+// ActiveSessions lists the logged in sessions that haven't expired, most recently seen first
+func (s *Store) ActiveSessions() ([]Session, error) {
+	now := time.Now()
+	var sessions []Session
+	err := s.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(sessionsBucket).ForEach(func(_, data []byte) error {
+			var session Session
+			if err := json.Unmarshal(data, &session); err != nil {
+				return nil
+			}
+			if session.PubKey != "" && !session.Expired(now) {
+				sessions = append(sessions, session)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %v", err)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeen.After(sessions[j].LastSeen)
+	})
+	return sessions, nil
+}
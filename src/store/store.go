@@ -0,0 +1,344 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"badger/src/types"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	eventsBucket      = []byte("events")      // event id -> event JSON
+	replaceableBucket = []byte("replaceable") // "kind:pubkey:d" -> id of the newest version
+	syncsBucket       = []byte("syncs")       // query key -> unix time of the last relay sync
+	tombstonesBucket  = []byte("tombstones")  // "pubkey:id" or "kind:pubkey:d" deleted by NIP-09 -> unix time of the deletion
+)
+
+// Reasons Add gives for leaving an event out, worded as NIP-01 OK messages
+var (
+	ErrDuplicate    = errors.New("duplicate: already have this event")
+	ErrNewerVersion = errors.New("duplicate: already have a newer version of this event")
+	ErrBadSignature = errors.New("invalid: bad signature")
+	ErrEventDeleted = errors.New("invalid: event was deleted by its author")
+	ErrEventExpired = errors.New("invalid: event has expired")
+)
+
+// Skipped reports whether err is one of the reasons an event is left out rather than a failure
+func Skipped(err error) bool {
+	return errors.Is(err, ErrDuplicate) || errors.Is(err, ErrNewerVersion) || errors.Is(err, ErrBadSignature) ||
+		errors.Is(err, ErrEventDeleted) || errors.Is(err, ErrEventExpired)
+}
+
+// Store is a persistent local copy of the badge related events Badger has seen
+type Store struct {
+	db *bolt.DB
+}
+
+// Default is the store shared by the fetchers, nil when persistence is disabled
+var Default *Store
+
+// Open opens (or creates) the event database at path
+func Open(path string) (*Store, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %v", err)
+	}
+
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event store: %v", err)
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		indexed := tx.Bucket(byKindBucket) != nil
+		for _, bucket := range [][]byte{eventsBucket, replaceableBucket, syncsBucket, tombstonesBucket, byKindBucket, byAuthorBucket, sessionsBucket, issuersBucket, issuerAuditBucket, claimCampaignsBucket, claimCodesBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		if !indexed {
+			return reindex(tx)
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to initialize event store: %v", err)
+	}
+
+	return &Store{db: db}, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// ReplaceableKey returns the (kind, pubkey, d-tag) address of replaceable and parameterized
+// replaceable events, or "" for regular events
+func ReplaceableKey(event types.NostrEvent) string {
+	switch {
+	case event.Kind == 0 || event.Kind == 3 || (event.Kind >= 10000 && event.Kind < 20000):
+		return fmt.Sprintf("%d:%s:", event.Kind, event.PubKey)
+	case event.Kind >= 30000 && event.Kind < 40000:
+		dTag := ""
+		for _, tag := range event.Tags {
+			if len(tag) > 1 && tag[0] == "d" {
+				dTag = tag[1]
+				break
+			}
+		}
+		return fmt.Sprintf("%d:%s:%s", event.Kind, event.PubKey, dTag)
+	}
+	return ""
+}
+
+// Save stores correctly signed events, keeping only the newest version of replaceable events and
+// applying NIP-09 deletions to events by the same author. Forged, expired (NIP-40) and deleted events
+// are skipped.
+func (s *Store) Save(events ...types.NostrEvent) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		for _, event := range events {
+			if err := saveEvent(tx, event); err != nil && !Skipped(err) {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Add stores a single event like Save, returning why it was left out (ErrDuplicate, ErrBadSignature, ...)
+// when nothing was stored
+func (s *Store) Add(event types.NostrEvent) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		return saveEvent(tx, event)
+	})
+}
+
+// saveEvent returns one of the skip reasons before writing anything when the event is left out
+func saveEvent(tx *bolt.Tx, event types.NostrEvent) error {
+	eventsB := tx.Bucket(eventsBucket)
+	replaceableB := tx.Bucket(replaceableBucket)
+
+	if eventsB.Get([]byte(event.ID)) != nil {
+		return ErrDuplicate
+	}
+	if err := event.Verify(); err != nil {
+		log.Printf("Not storing event %s from %s: %v\n", event.ID, event.PubKey, err)
+		return ErrBadSignature
+	}
+	if deleted(tx.Bucket(tombstonesBucket), event) {
+		return ErrEventDeleted
+	}
+	if event.Expired(time.Now().Unix()) {
+		return ErrEventExpired
+	}
+
+	if key := ReplaceableKey(event); key != "" {
+		if existingID := replaceableB.Get([]byte(key)); existingID != nil {
+			if existing, err := getEvent(eventsB, existingID); err == nil {
+				if existing.CreatedAt >= event.CreatedAt {
+					return ErrNewerVersion
+				}
+				if err := deleteEvent(tx, existing); err != nil {
+					return err
+				}
+			}
+		}
+		if err := replaceableB.Put([]byte(key), []byte(event.ID)); err != nil {
+			return err
+		}
+	}
+
+	if event.Kind == 5 {
+		if err := applyDeletion(tx, event); err != nil {
+			return err
+		}
+	}
+
+	return putEvent(tx, event)
+}
+
+// applyDeletion removes the events referenced by a kind 5 event's "e" and "a" tags, as long as they
+// were published by the same pubkey, and keeps a tombstone for each so a later sync can't bring them back
+func applyDeletion(tx *bolt.Tx, deletion types.NostrEvent) error {
+	eventsB := tx.Bucket(eventsBucket)
+	replaceableB := tx.Bucket(replaceableBucket)
+	tombstonesB := tx.Bucket(tombstonesBucket)
+
+	deletedAt := make([]byte, 8)
+	binary.BigEndian.PutUint64(deletedAt, uint64(deletion.CreatedAt))
+
+	for _, tag := range deletion.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+
+		var targetID []byte
+		switch tag[0] {
+		case "e":
+			if err := tombstonesB.Put([]byte(deletion.PubKey+":"+tag[1]), deletedAt); err != nil {
+				return err
+			}
+			targetID = []byte(tag[1])
+		case "a":
+			// Only the author's own addresses, and only versions up to the deletion's time
+			if parts := strings.SplitN(tag[1], ":", 3); len(parts) != 3 || parts[1] != deletion.PubKey {
+				continue
+			}
+			if err := tombstonesB.Put([]byte(tag[1]), deletedAt); err != nil {
+				return err
+			}
+			targetID = replaceableB.Get([]byte(tag[1]))
+		}
+		if targetID == nil {
+			continue
+		}
+
+		target, err := getEvent(eventsB, targetID)
+		if err != nil || target.PubKey != deletion.PubKey {
+			continue
+		}
+		if tag[0] == "a" && target.CreatedAt > deletion.CreatedAt {
+			continue
+		}
+		if key := ReplaceableKey(target); key != "" {
+			if err := replaceableB.Delete([]byte(key)); err != nil {
+				return err
+			}
+		}
+		if err := deleteEvent(tx, target); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// deleted reports whether a stored NIP-09 deletion covers the event
+func deleted(tombstones *bolt.Bucket, event types.NostrEvent) bool {
+	if tombstones.Get([]byte(event.PubKey+":"+event.ID)) != nil {
+		return true
+	}
+	if key := ReplaceableKey(event); key != "" {
+		if deletedAt := tombstones.Get([]byte(key)); len(deletedAt) == 8 {
+			return event.CreatedAt <= int64(binary.BigEndian.Uint64(deletedAt))
+		}
+	}
+	return false
+}
+
+func getEvent(bucket *bolt.Bucket, id []byte) (types.NostrEvent, error) {
+	var event types.NostrEvent
+	data := bucket.Get(id)
+	if data == nil {
+		return event, fmt.Errorf("event %s not found", id)
+	}
+	err := json.Unmarshal(data, &event)
+	return event, err
+}
+
+// Query returns the stored events matching the filter, newest first, leaving out expired events
+func (s *Store) Query(filter types.SubscriptionFilter) ([]types.NostrEvent, error) {
+	var events []types.NostrEvent
+	now := time.Now().Unix()
+	match := func(event types.NostrEvent) bool {
+		return MatchFilter(filter, event) && !event.Expired(now)
+	}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		eventsB := tx.Bucket(eventsBucket)
+
+		// Lookups by id don't need to scan the bucket
+		if len(filter.IDs) > 0 {
+			for _, id := range filter.IDs {
+				event, err := getEvent(eventsB, []byte(id))
+				if err == nil && match(event) {
+					events = append(events, event)
+				}
+			}
+			return nil
+		}
+
+		// Filters naming kinds or authors only read the events listed in those indexes
+		if ids, indexed := candidateIDs(tx, filter); indexed {
+			for _, id := range ids {
+				event, err := getEvent(eventsB, id)
+				if err == nil && match(event) {
+					events = append(events, event)
+				}
+			}
+			return nil
+		}
+
+		return eventsB.ForEach(func(_, data []byte) error {
+			var event types.NostrEvent
+			if err := json.Unmarshal(data, &event); err != nil {
+				return nil
+			}
+			if match(event) {
+				events = append(events, event)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].CreatedAt > events[j].CreatedAt
+	})
+	if filter.Limit != nil && *filter.Limit >= 0 && len(events) > *filter.Limit {
+		events = events[:*filter.Limit]
+	}
+	return events, nil
+}
+
+// LastSync returns when the query key was last refreshed from relays
+func (s *Store) LastSync(key string) time.Time {
+	var last time.Time
+	s.db.View(func(tx *bolt.Tx) error {
+		if data := tx.Bucket(syncsBucket).Get([]byte(key)); len(data) == 8 {
+			last = time.Unix(int64(binary.BigEndian.Uint64(data)), 0)
+		}
+		return nil
+	})
+	return last
+}
+
+func (s *Store) setLastSync(key string, at time.Time) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		data := make([]byte, 8)
+		binary.BigEndian.PutUint64(data, uint64(at.Unix()))
+		return tx.Bucket(syncsBucket).Put([]byte(key), data)
+	})
+}
+
+// Invalidate forgets the sync time of every query key starting with prefix, so the next load
+// fetches everything from relays again
+func (s *Store) Invalidate(prefix string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(syncsBucket)
+
+		var keys [][]byte
+		cursor := bucket.Cursor()
+		for k, _ := cursor.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, _ = cursor.Next() {
+			keys = append(keys, append([]byte(nil), k...))
+		}
+		for _, k := range keys {
+			if err := bucket.Delete(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,326 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"testing"
+
+	"badger/src/types"
+
+	"github.com/nbd-wtf/go-nostr"
+	bolt "go.etcd.io/bbolt"
+)
+
+func testStore(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// signed builds an event signed with secretKey
+func signed(t *testing.T, secretKey string, kind int, createdAt int64, tags nostr.Tags, content string) types.NostrEvent {
+	t.Helper()
+	event := nostr.Event{Kind: kind, CreatedAt: nostr.Timestamp(createdAt), Tags: tags, Content: content}
+	if err := event.Sign(secretKey); err != nil {
+		t.Fatal(err)
+	}
+	return types.FromNostrEvent(event)
+}
+
+func publicKey(t *testing.T, secretKey string) string {
+	t.Helper()
+	pk, err := nostr.GetPublicKey(secretKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pk
+}
+
+// eventID recomputes the id of an edited event, leaving its signature broken
+func eventID(event types.NostrEvent) string {
+	e := event.ToNostrEvent()
+	return e.GetID()
+}
+
+// stored reports whether the event with id is in the store
+func stored(t *testing.T, s *Store, id string) bool {
+	t.Helper()
+	events, err := s.Query(types.SubscriptionFilter{IDs: []string{id}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return len(events) == 1
+}
+
+func TestSaveChecksEvents(t *testing.T) {
+	alice, bob := nostr.GeneratePrivateKey(), nostr.GeneratePrivateKey()
+	now := int64(nostr.Now())
+
+	tamperedContent := signed(t, alice, 8, now, nil, "original")
+	tamperedContent.Content = "changed"
+
+	tamperedID := signed(t, alice, 8, now, nil, "original")
+	tamperedID.Content = "changed"
+	tamperedID.ID = eventID(tamperedID)
+
+	otherAuthor := signed(t, alice, 8, now, nil, "original")
+	otherAuthor.PubKey = publicKey(t, bob)
+	otherAuthor.ID = eventID(otherAuthor)
+
+	tests := []struct {
+		name   string
+		event  types.NostrEvent
+		stored bool
+	}{
+		{"valid", signed(t, alice, 8, now, nil, ""), true},
+		{"tampered content", tamperedContent, false},
+		{"tampered id", tamperedID, false},
+		{"other author", otherAuthor, false},
+		{"missing signature", func() types.NostrEvent { e := signed(t, alice, 8, now, nil, "x"); e.Sig = ""; return e }(), false},
+		{"expired", signed(t, alice, 8, now, nostr.Tags{{"expiration", fmt.Sprint(now - 1)}}, ""), false},
+		{"expires later", signed(t, alice, 8, now, nostr.Tags{{"expiration", fmt.Sprint(now + 3600)}}, ""), true},
+	}
+	s := testStore(t)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := s.Save(test.event); err != nil {
+				t.Fatal(err)
+			}
+			if got := stored(t, s, test.event.ID); got != test.stored {
+				t.Fatalf("stored = %v, want %v", got, test.stored)
+			}
+		})
+	}
+}
+
+func TestAddReportsSkippedEvents(t *testing.T) {
+	alice := nostr.GeneratePrivateKey()
+	now := int64(nostr.Now())
+	s := testStore(t)
+
+	award := signed(t, alice, 8, now, nil, "")
+	definition := signed(t, alice, 30009, now, nostr.Tags{{"d", "badge"}}, "")
+	removed := signed(t, alice, 8, now-1, nil, "removed")
+	deletion := signed(t, alice, 5, now, nostr.Tags{{"e", removed.ID}}, "")
+	forged := signed(t, alice, 8, now, nil, "original")
+	forged.Content = "changed"
+
+	tests := []struct {
+		name  string
+		event types.NostrEvent
+		err   error
+	}{
+		{"new", award, nil},
+		{"duplicate", award, ErrDuplicate},
+		{"definition", definition, nil},
+		{"older version", signed(t, alice, 30009, now-60, nostr.Tags{{"d", "badge"}}, ""), ErrNewerVersion},
+		{"deletion", deletion, nil},
+		{"deleted", removed, ErrEventDeleted},
+		{"expired", signed(t, alice, 8, now, nostr.Tags{{"expiration", fmt.Sprint(now - 1)}}, ""), ErrEventExpired},
+		{"forged", forged, ErrBadSignature},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := s.Add(test.event)
+			if err != test.err {
+				t.Fatalf("error = %v, want %v", err, test.err)
+			}
+			if err != nil && !Skipped(err) {
+				t.Fatalf("%v is not reported as skipped", err)
+			}
+		})
+	}
+}
+
+func TestSaveKeepsNewestReplaceable(t *testing.T) {
+	alice := nostr.GeneratePrivateKey()
+	s := testStore(t)
+
+	middle := signed(t, alice, 30009, 200, nostr.Tags{{"d", "badge"}}, "middle")
+	newest := signed(t, alice, 30009, 300, nostr.Tags{{"d", "badge"}}, "newest")
+	oldest := signed(t, alice, 30009, 100, nostr.Tags{{"d", "badge"}}, "oldest")
+	otherBadge := signed(t, alice, 30009, 100, nostr.Tags{{"d", "other"}}, "other")
+	for _, event := range []types.NostrEvent{middle, newest, oldest, otherBadge} {
+		if err := s.Save(event); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	events, err := s.Query(types.SubscriptionFilter{Kinds: []int{30009}, Tags: map[string][]string{"d": {"badge"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].ID != newest.ID {
+		t.Fatalf("got %+v, want only the newest version", events)
+	}
+	if stored(t, s, middle.ID) || stored(t, s, oldest.ID) || !stored(t, s, otherBadge.ID) {
+		t.Fatal("older versions were kept or another address was replaced")
+	}
+
+	// Replaced versions leave the indexes too
+	s.db.View(func(tx *bolt.Tx) error {
+		if ids := indexScan(tx.Bucket(byAuthorBucket), authorKey(newest.PubKey, "")); len(ids) != 2 {
+			t.Fatalf("author index holds %d events, want 2", len(ids))
+		}
+		return nil
+	})
+}
+
+func TestDeletions(t *testing.T) {
+	alice, bob := nostr.GeneratePrivateKey(), nostr.GeneratePrivateKey()
+	alicePK := publicKey(t, alice)
+	address := "30009:" + alicePK + ":badge"
+
+	tests := []struct {
+		name string
+		// events are saved in order, then want says which are stored by their index
+		events func() []types.NostrEvent
+		want   []bool
+	}{
+		{"author deletes an award", func() []types.NostrEvent {
+			award := signed(t, alice, 8, 100, nil, "")
+			return []types.NostrEvent{award, signed(t, alice, 5, 200, nostr.Tags{{"e", award.ID}}, "")}
+		}, []bool{false, true}},
+		{"deleted award can't come back", func() []types.NostrEvent {
+			award := signed(t, alice, 8, 100, nil, "")
+			deletion := signed(t, alice, 5, 200, nostr.Tags{{"e", award.ID}}, "")
+			return []types.NostrEvent{deletion, award}
+		}, []bool{true, false}},
+		{"someone else can't delete", func() []types.NostrEvent {
+			award := signed(t, alice, 8, 100, nil, "")
+			return []types.NostrEvent{award, signed(t, bob, 5, 200, nostr.Tags{{"e", award.ID}}, "")}
+		}, []bool{true, true}},
+		{"address deletion covers older versions", func() []types.NostrEvent {
+			definition := signed(t, alice, 30009, 100, nostr.Tags{{"d", "badge"}}, "")
+			deletion := signed(t, alice, 5, 200, nostr.Tags{{"a", address}}, "")
+			older := signed(t, alice, 30009, 150, nostr.Tags{{"d", "badge"}}, "")
+			newer := signed(t, alice, 30009, 300, nostr.Tags{{"d", "badge"}}, "")
+			return []types.NostrEvent{definition, deletion, older, newer}
+		}, []bool{false, true, false, true}},
+		{"address of someone else", func() []types.NostrEvent {
+			definition := signed(t, alice, 30009, 100, nostr.Tags{{"d", "badge"}}, "")
+			return []types.NostrEvent{definition, signed(t, bob, 5, 200, nostr.Tags{{"a", address}}, "")}
+		}, []bool{true, true}},
+		{"forged deletion", func() []types.NostrEvent {
+			award := signed(t, alice, 8, 100, nil, "")
+			deletion := signed(t, alice, 5, 200, nostr.Tags{{"e", award.ID}}, "")
+			deletion.Sig = signed(t, alice, 5, 200, nil, "").Sig
+			return []types.NostrEvent{award, deletion}
+		}, []bool{true, false}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s := testStore(t)
+			events := test.events()
+			for _, event := range events {
+				if err := s.Save(event); err != nil {
+					t.Fatal(err)
+				}
+			}
+			for i, event := range events {
+				if got := stored(t, s, event.ID); got != test.want[i] {
+					t.Errorf("event %d stored = %v, want %v", i, got, test.want[i])
+				}
+			}
+		})
+	}
+}
+
+func TestQuery(t *testing.T) {
+	alice, bob := nostr.GeneratePrivateKey(), nostr.GeneratePrivateKey()
+	alicePK, bobPK := publicKey(t, alice), publicKey(t, bob)
+	s := testStore(t)
+
+	awardToBob := signed(t, alice, 8, 100, nostr.Tags{{"p", bobPK}}, "")
+	awardToAlice := signed(t, bob, 8, 200, nostr.Tags{{"p", alicePK}}, "")
+	definition := signed(t, alice, 30009, 300, nostr.Tags{{"d", "badge"}}, "")
+	metadata := signed(t, bob, 0, 400, nil, "{}")
+	if err := s.Save(awardToBob, awardToAlice, definition, metadata); err != nil {
+		t.Fatal(err)
+	}
+
+	since, until, one := int64(150), int64(350), 1
+	tests := []struct {
+		name   string
+		filter types.SubscriptionFilter
+		want   []string // Newest first
+	}{
+		{"everything", types.SubscriptionFilter{}, []string{metadata.ID, definition.ID, awardToAlice.ID, awardToBob.ID}},
+		{"kind", types.SubscriptionFilter{Kinds: []int{8}}, []string{awardToAlice.ID, awardToBob.ID}},
+		{"kinds", types.SubscriptionFilter{Kinds: []int{0, 30009}}, []string{metadata.ID, definition.ID}},
+		{"author", types.SubscriptionFilter{Authors: []string{alicePK}}, []string{definition.ID, awardToBob.ID}},
+		{"kind and author", types.SubscriptionFilter{Kinds: []int{8}, Authors: []string{bobPK}}, []string{awardToAlice.ID}},
+		{"tag", types.SubscriptionFilter{Kinds: []int{8}, Tags: map[string][]string{"p": {bobPK}}}, []string{awardToBob.ID}},
+		{"since and until", types.SubscriptionFilter{Since: &since, Until: &until}, []string{definition.ID, awardToAlice.ID}},
+		{"limit", types.SubscriptionFilter{Kinds: []int{8}, Limit: &one}, []string{awardToAlice.ID}},
+		{"ids", types.SubscriptionFilter{IDs: []string{awardToBob.ID, "missing"}}, []string{awardToBob.ID}},
+		{"unknown kind", types.SubscriptionFilter{Kinds: []int{1}}, nil},
+		{"unknown author", types.SubscriptionFilter{Authors: []string{"abc"}}, nil},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			events, err := s.Query(test.filter)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, event := range events {
+				got = append(got, event.ID)
+			}
+			if fmt.Sprint(got) != fmt.Sprint(test.want) {
+				t.Fatalf("got %v, want %v", got, test.want)
+			}
+		})
+	}
+}
+
+func TestReindexDropsForgedEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.db")
+	s, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	valid := signed(t, nostr.GeneratePrivateKey(), 8, 100, nil, "")
+	forged := signed(t, nostr.GeneratePrivateKey(), 8, 100, nil, "")
+	forged.Content = "changed"
+	if err := s.Save(valid); err != nil {
+		t.Fatal(err)
+	}
+
+	// Write the forged event the way older versions did and drop the indexes they didn't have
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		data, _ := json.Marshal(forged)
+		if err := tx.Bucket(eventsBucket).Put([]byte(forged.ID), data); err != nil {
+			return err
+		}
+		if err := tx.DeleteBucket(byKindBucket); err != nil {
+			return err
+		}
+		return tx.DeleteBucket(byAuthorBucket)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Close()
+
+	s, err = Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	events, err := s.Query(types.SubscriptionFilter{Kinds: []int{8}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 1 || events[0].ID != valid.ID {
+		t.Fatalf("got %+v, want only the valid event", events)
+	}
+	if stored(t, s, forged.ID) {
+		t.Fatal("the forged event is still stored")
+	}
+}
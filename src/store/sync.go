@@ -0,0 +1,50 @@
+package store
+
+import (
+	"log"
+	"time"
+
+	"badger/src/types"
+)
+
+// syncOverlap re-requests a little history on incremental syncs to cover relay clock skew
+const syncOverlap = 5 * time.Minute
+
+// FetchFunc queries relays with the given filter
+type FetchFunc func(filter types.SubscriptionFilter) ([]types.NostrEvent, error)
+
+// Sync answers a query from the store, going to relays only when the key hasn't been refreshed
+// within ttl. Refreshes after the first one only ask relays for events newer than the last sync.
+func (s *Store) Sync(key string, ttl time.Duration, filter types.SubscriptionFilter, fetch FetchFunc) ([]types.NostrEvent, error) {
+	lastSync := s.LastSync(key)
+	if !lastSync.IsZero() && time.Since(lastSync) < ttl {
+		return s.Query(filter)
+	}
+
+	relayFilter := filter
+	if !lastSync.IsZero() {
+		since := lastSync.Add(-syncOverlap).Unix()
+		relayFilter.Since = &since
+	}
+
+	started := time.Now()
+	events, err := fetch(relayFilter)
+	if err != nil {
+		if lastSync.IsZero() {
+			return nil, err
+		}
+		// Serve what we already have if the relays are unreachable
+		log.Printf("Failed to refresh %s from relays, serving stored events: %v\n", key, err)
+		return s.Query(filter)
+	}
+
+	if err := s.Save(events...); err != nil {
+		log.Printf("Failed to store events for %s: %v\n", key, err)
+		return events, nil
+	}
+	if err := s.setLastSync(key, started); err != nil {
+		log.Printf("Failed to record sync time for %s: %v\n", key, err)
+	}
+
+	return s.Query(filter)
+}
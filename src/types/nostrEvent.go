@@ -1,5 +1,12 @@
 package types
 
+import (
+	"errors"
+	"strconv"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
 type NostrEvent struct {
 	ID        string     `json:"id"`
 	PubKey    string     `json:"pubkey"`
@@ -9,3 +16,60 @@ type NostrEvent struct {
 	Content   string     `json:"content"`
 	Sig       string     `json:"sig"`
 }
+
+// FromNostrEvent converts a go-nostr event into Badger's event type
+func FromNostrEvent(event nostr.Event) NostrEvent {
+	tags := make([][]string, len(event.Tags))
+	for i, tag := range event.Tags {
+		tags[i] = tag
+	}
+	return NostrEvent{
+		ID:        event.ID,
+		PubKey:    event.PubKey,
+		CreatedAt: int64(event.CreatedAt),
+		Kind:      event.Kind,
+		Tags:      tags,
+		Content:   event.Content,
+		Sig:       event.Sig,
+	}
+}
+
+// ToNostrEvent converts the event into a go-nostr event
+func (e NostrEvent) ToNostrEvent() nostr.Event {
+	tags := make(nostr.Tags, len(e.Tags))
+	for i, tag := range e.Tags {
+		tags[i] = tag
+	}
+	return nostr.Event{
+		ID:        e.ID,
+		PubKey:    e.PubKey,
+		CreatedAt: nostr.Timestamp(e.CreatedAt),
+		Kind:      e.Kind,
+		Tags:      tags,
+		Content:   e.Content,
+		Sig:       e.Sig,
+	}
+}
+
+// Verify checks that the id is the hash of the event's content and the signature is its author's
+func (e NostrEvent) Verify() error {
+	event := e.ToNostrEvent()
+	if event.GetID() != e.ID {
+		return errors.New("event id does not match its content")
+	}
+	if ok, err := event.CheckSignature(); err != nil || !ok {
+		return errors.New("invalid event signature")
+	}
+	return nil
+}
+
+// Expired reports whether the event's NIP-40 expiration time is before now (unix seconds)
+func (e NostrEvent) Expired(now int64) bool {
+	for _, tag := range e.Tags {
+		if len(tag) > 1 && tag[0] == "expiration" {
+			expiration, err := strconv.ParseInt(tag[1], 10, 64)
+			return err == nil && expiration <= now
+		}
+	}
+	return false
+}
@@ -1,11 +1,69 @@
 package types
 
+import (
+	"encoding/json"
+	"strings"
+)
+
 type SubscriptionFilter struct {
 	IDs     []string            `json:"ids,omitempty"`
 	Authors []string            `json:"authors,omitempty"`
 	Kinds   []int               `json:"kinds,omitempty"`
-	Tags    map[string][]string `json:"#,omitempty"`
+	Tags    map[string][]string `json:"-"` // Serialized as "#<tag>" keys (NIP-01)
 	Since   *int64              `json:"since,omitempty"`
 	Until   *int64              `json:"until,omitempty"`
 	Limit   *int                `json:"limit,omitempty"`
 }
+
+// subscriptionFilterFields avoids recursing into MarshalJSON/UnmarshalJSON
+type subscriptionFilterFields SubscriptionFilter
+
+// MarshalJSON writes tag filters as top level "#e", "#p", "#d"... keys as NIP-01 requires
+func (f SubscriptionFilter) MarshalJSON() ([]byte, error) {
+	base, err := json.Marshal(subscriptionFilterFields(f))
+	if err != nil || len(f.Tags) == 0 {
+		return base, err
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(base, &fields); err != nil {
+		return nil, err
+	}
+	for name, values := range f.Tags {
+		raw, err := json.Marshal(values)
+		if err != nil {
+			return nil, err
+		}
+		fields["#"+name] = raw
+	}
+	return json.Marshal(fields)
+}
+
+// UnmarshalJSON reads "#<tag>" keys back into Tags
+func (f *SubscriptionFilter) UnmarshalJSON(data []byte) error {
+	var base subscriptionFilterFields
+	if err := json.Unmarshal(data, &base); err != nil {
+		return err
+	}
+
+	fields := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	for key, raw := range fields {
+		if !strings.HasPrefix(key, "#") || len(key) < 2 {
+			continue
+		}
+		var values []string
+		if err := json.Unmarshal(raw, &values); err != nil {
+			return err
+		}
+		if base.Tags == nil {
+			base.Tags = make(map[string][]string)
+		}
+		base.Tags[key[1:]] = values
+	}
+
+	*f = SubscriptionFilter(base)
+	return nil
+}
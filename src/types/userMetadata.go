@@ -1,8 +1,18 @@
 package types
 
 type UserMetadata struct {
+	Name        string `json:"name"`
 	DisplayName string `json:"display_name"`
 	Picture     string `json:"picture"`
 	About       string `json:"about"`
-	// can add more extra metadata fields if desired website, banner, name
+	NIP05       string `json:"nip05"`
+	// can add more extra metadata fields if desired website, banner
+}
+
+// Label returns the name a user should be shown by, preferring their display name
+func (m UserMetadata) Label() string {
+	if m.DisplayName != "" {
+		return m.DisplayName
+	}
+	return m.Name
 }
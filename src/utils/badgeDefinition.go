@@ -0,0 +1,56 @@
+package utils
+
+import (
+	"strconv"
+	"strings"
+
+	"badger/src/types"
+)
+
+// displayThumbSize is the thumbnail width Badger shows badges at, the closest thumb tag is used
+const displayThumbSize = 256
+
+// ParseBadgeDefinition reads the NIP-58 tags of a kind 30009 event into a BadgeDefinition
+func ParseBadgeDefinition(event types.NostrEvent) types.BadgeDefinition {
+	badge := types.BadgeDefinition{NostrEvent: event}
+	thumbDistance := -1
+	for _, tag := range event.Tags {
+		if len(tag) < 2 {
+			continue
+		}
+		switch tag[0] {
+		case "name":
+			badge.Name = tag[1]
+		case "description":
+			badge.Description = tag[1]
+		case "image":
+			badge.ImageURL = tag[1]
+		case "thumb":
+			// Definitions may list several thumbnail sizes
+			distance := thumbSizeDistance(tag)
+			if thumbDistance < 0 || distance < thumbDistance {
+				badge.ThumbURL = tag[1]
+				thumbDistance = distance
+			}
+		case "d":
+			badge.DTag = tag[1]
+		}
+	}
+	return badge
+}
+
+// thumbSizeDistance is how far a thumb tag's "<width>x<height>" is from displayThumbSize, thumbs
+// without dimensions count as just a bit worse than any known size
+func thumbSizeDistance(tag []string) int {
+	if len(tag) < 3 {
+		return 1 << 20
+	}
+	width, err := strconv.Atoi(strings.SplitN(tag[2], "x", 2)[0])
+	if err != nil {
+		return 1 << 20
+	}
+	if width > displayThumbSize {
+		return width - displayThumbSize
+	}
+	return displayThumbSize - width
+}
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// AwardRecipient is a single pubkey receiving a badge award, with the relay hint placed in its "p" tag
+type AwardRecipient struct {
+	PubKey    string
+	RelayHint string
+}
+
+// BadgeATag builds the "a" tag value ("30009:pubkey:dtag") pointing at a badge definition
+func BadgeATag(issuerPubKey, dTag string) string {
+	return fmt.Sprintf("%d:%s:%s", 30009, issuerPubKey, dTag)
+}
+
+// BuildBadgeAwardEvent creates an unsigned kind 8 badge award event (NIP-58) for the given recipients
+func BuildBadgeAwardEvent(issuerPubKey, dTag string, recipients []AwardRecipient) *nostr.Event {
+	tags := nostr.Tags{
+		nostr.Tag{"a", BadgeATag(issuerPubKey, dTag)},
+	}
+	for _, recipient := range recipients {
+		if recipient.RelayHint != "" {
+			tags = append(tags, nostr.Tag{"p", recipient.PubKey, recipient.RelayHint})
+		} else {
+			tags = append(tags, nostr.Tag{"p", recipient.PubKey})
+		}
+	}
+
+	return &nostr.Event{
+		PubKey:    issuerPubKey,
+		CreatedAt: nostr.Timestamp(time.Now().Unix()),
+		Kind:      8, // Badge award event kind (NIP-58)
+		Tags:      tags,
+		Content:   "",
+	}
+}
+
+// PreferredRelayHint picks the relay advertised in award "p" tags, preferring the issuer's write relays
+func PreferredRelayHint(relays RelayList) string {
+	for _, group := range [][]string{relays.Write, relays.Both, relays.Read} {
+		if len(group) > 0 {
+			return group[0]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// BuildProfileBadgesEvent creates an unsigned kind 30008 profile badges event (NIP-58) listing the
+// badges in display order as consecutive "a"/"e" tag pairs
+func BuildProfileBadgesEvent(publicKey string, badges []ProfileBadge) (*nostr.Event, error) {
+	tags := nostr.Tags{
+		nostr.Tag{"d", "profile_badges"},
+	}
+
+	seen := make(map[string]bool)
+	for _, badge := range badges {
+		parts := strings.Split(badge.BadgeAwardATag, ":")
+		if len(parts) != 3 || parts[0] != "30009" || !nostr.IsValidPublicKeyHex(parts[1]) || parts[2] == "" {
+			return nil, fmt.Errorf("invalid badge definition reference: %q", badge.BadgeAwardATag)
+		}
+		if !nostr.IsValid32ByteHex(badge.AwardEventID) {
+			return nil, fmt.Errorf("invalid badge award event id: %q", badge.AwardEventID)
+		}
+		if seen[badge.BadgeAwardATag] {
+			continue
+		}
+		seen[badge.BadgeAwardATag] = true
+
+		tags = append(tags, nostr.Tag{"a", badge.BadgeAwardATag})
+		if badge.AwardRelayURL != "" {
+			tags = append(tags, nostr.Tag{"e", badge.AwardEventID, badge.AwardRelayURL})
+		} else {
+			tags = append(tags, nostr.Tag{"e", badge.AwardEventID})
+		}
+	}
+
+	return &nostr.Event{
+		PubKey:    publicKey,
+		CreatedAt: nostr.Timestamp(time.Now().Unix()),
+		Kind:      30008, // Profile badges event kind (NIP-58)
+		Tags:      tags,
+		Content:   "",
+	}, nil
+}
@@ -0,0 +1,164 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip46"
+)
+
+// bunkerTimeout is how long a remote signer gets to answer, long enough to approve a request on a phone
+const bunkerTimeout = 2 * time.Minute
+
+// BunkerSession is what a NIP-46 login keeps in the user's session to reach their remote signer again
+type BunkerSession struct {
+	ClientSecretKey string   // Key Badger talks to the signer with, authorized during connect
+	SignerPubKey    string   // The remote signer's key from the bunker:// URI
+	Relays          []string // Relays the signer listens on
+}
+
+// bunkerClients keeps one open connection per authorized client key, so signing doesn't reconnect
+var bunkerClients = struct {
+	sync.Mutex
+	clients map[string]*bunkerClient
+}{clients: make(map[string]*bunkerClient)}
+
+type bunkerClient struct {
+	client *nip46.BunkerClient
+	cancel context.CancelFunc
+}
+
+// ConnectBunker connects to the remote signer of a bunker://<signer pubkey>?relay=...&secret=... URI and
+// returns the session to sign with plus the public key of the user it signs for
+func ConnectBunker(uri string) (BunkerSession, string, error) {
+	if !nip46.IsValidBunkerURL(uri) {
+		return BunkerSession{}, "", fmt.Errorf("expected a bunker://<pubkey>?relay=wss://... URI")
+	}
+	parsed, err := url.Parse(uri)
+	if err != nil {
+		return BunkerSession{}, "", fmt.Errorf("invalid bunker URI: %w", err)
+	}
+
+	bunker := BunkerSession{
+		ClientSecretKey: nostr.GeneratePrivateKey(),
+		SignerPubKey:    parsed.Host,
+		Relays:          parsed.Query()["relay"],
+	}
+	if len(bunker.Relays) == 0 {
+		return BunkerSession{}, "", fmt.Errorf("the bunker URI names no relays")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), bunkerTimeout)
+	defer cancel()
+
+	client := bunker.client()
+	if _, err := client.RPC(ctx, "connect", []string{bunker.SignerPubKey, parsed.Query().Get("secret")}); err != nil {
+		bunker.Close()
+		return BunkerSession{}, "", fmt.Errorf("remote signer refused to connect: %w", err)
+	}
+
+	publicKey, err := client.GetPublicKey(ctx)
+	if err != nil {
+		bunker.Close()
+		return BunkerSession{}, "", fmt.Errorf("failed to get public key from remote signer: %w", err)
+	}
+	if !nostr.IsValidPublicKeyHex(publicKey) {
+		bunker.Close()
+		return BunkerSession{}, "", fmt.Errorf("remote signer returned an invalid public key %q", publicKey)
+	}
+
+	// Any signer can answer get_public_key with someone else's key, only a signature proves it holds it
+	if err := proveBunkerKey(ctx, client, publicKey); err != nil {
+		bunker.Close()
+		return BunkerSession{}, "", err
+	}
+
+	return bunker, publicKey, nil
+}
+
+// proveBunkerKey has the remote signer sign a fresh login challenge (the kind 22242 event of the
+// extension login) and checks it was signed by publicKey
+func proveBunkerKey(ctx context.Context, client *nip46.BunkerClient, publicKey string) error {
+	nonce := make([]byte, 32)
+	if _, err := rand.Read(nonce); err != nil {
+		return fmt.Errorf("failed to generate login challenge: %w", err)
+	}
+	challenge := hex.EncodeToString(nonce)
+
+	event := nostr.Event{
+		PubKey:    publicKey,
+		CreatedAt: nostr.Now(),
+		Kind:      22242,
+		Tags:      nostr.Tags{{"challenge", challenge}},
+	}
+	if err := client.SignEvent(ctx, &event); err != nil {
+		return fmt.Errorf("remote signer failed to sign the login challenge: %w", err)
+	}
+
+	if event.PubKey != publicKey {
+		return errors.New("remote signer signed the login challenge with a different key")
+	}
+	if event.Kind != 22242 || event.Tags.GetFirst([]string{"challenge", challenge}) == nil {
+		return errors.New("remote signer changed the login challenge")
+	}
+	if event.GetID() != event.ID {
+		return errors.New("login challenge id does not match its content")
+	}
+	if ok, err := event.CheckSignature(); err != nil || !ok {
+		return errors.New("invalid login challenge signature")
+	}
+	return nil
+}
+
+// SignEvent asks the remote signer to sign the event, filling in its id, pubkey and signature
+func (b BunkerSession) SignEvent(event *nostr.Event) error {
+	ctx, cancel := context.WithTimeout(context.Background(), bunkerTimeout)
+	defer cancel()
+
+	if err := b.client().SignEvent(ctx, event); err != nil {
+		return fmt.Errorf("remote signer failed to sign: %w", err)
+	}
+	if event.GetID() != event.ID {
+		return fmt.Errorf("remote signer returned an event whose id does not match its content")
+	}
+	if valid, err := event.CheckSignature(); err != nil || !valid {
+		return fmt.Errorf("remote signer returned an invalid signature")
+	}
+	return nil
+}
+
+// Close drops the open connection to the remote signer
+func (b BunkerSession) Close() {
+	bunkerClients.Lock()
+	defer bunkerClients.Unlock()
+
+	if open, found := bunkerClients.clients[b.ClientSecretKey]; found {
+		open.cancel()
+		delete(bunkerClients.clients, b.ClientSecretKey)
+	}
+}
+
+// client returns the open connection to the remote signer, reconnecting after a restart
+func (b BunkerSession) client() *nip46.BunkerClient {
+	bunkerClients.Lock()
+	defer bunkerClients.Unlock()
+
+	if open, found := bunkerClients.clients[b.ClientSecretKey]; found {
+		return open.client
+	}
+
+	// The subscription for the signer's answers lives until Close
+	ctx, cancel := context.WithCancel(context.Background())
+	client := nip46.NewBunker(ctx, b.ClientSecretKey, b.SignerPubKey, b.Relays, nil, func(authURL string) {
+		Infof("Remote signer %s asks for authorization at %s", b.SignerPubKey, authURL)
+	})
+	bunkerClients.clients[b.ClientSecretKey] = &bunkerClient{client: client, cancel: cancel}
+	return client
+}
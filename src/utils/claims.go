@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"badger/src/store"
+	"badger/src/types"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// MaxClaimCodes limits how many codes a single campaign generates
+const MaxClaimCodes = 1000
+
+// claimCodeEncoding writes claim codes in lowercase base32, easy to type from a printed card
+var claimCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// CreateClaimCampaign generates codes that each award the badge up to uses times, until expiresAt
+// (never when zero). Awards are signed on the server, so the badge must belong to an organization
+// issuer the user is a member of.
+func CreateClaimCampaign(publicKey string, badge types.BadgeDefinition, codes, uses int, expiresAt time.Time) (store.ClaimCampaign, error) {
+	db, err := issuerStore()
+	if err != nil {
+		return store.ClaimCampaign{}, err
+	}
+	if !CanIssueAs(publicKey, badge.PubKey) {
+		return store.ClaimCampaign{}, errors.New("claim links need a badge issued as an organization issuer you are a member of")
+	}
+	if codes < 1 || codes > MaxClaimCodes {
+		return store.ClaimCampaign{}, fmt.Errorf("a campaign has between 1 and %d codes", MaxClaimCodes)
+	}
+	if uses < 1 {
+		return store.ClaimCampaign{}, errors.New("each code must be usable at least once")
+	}
+	if !expiresAt.IsZero() && !expiresAt.After(time.Now()) {
+		return store.ClaimCampaign{}, errors.New("the expiry must be in the future")
+	}
+
+	id, err := randomToken(8)
+	if err != nil {
+		return store.ClaimCampaign{}, err
+	}
+	campaign := store.ClaimCampaign{
+		ID:        hex.EncodeToString(id),
+		Issuer:    badge.PubKey,
+		DTag:      badge.DTag,
+		BadgeName: badge.Name,
+		CreatedBy: publicKey,
+		CreatedAt: time.Now(),
+		ExpiresAt: expiresAt,
+	}
+	for i := 0; i < codes; i++ {
+		code, err := randomToken(8)
+		if err != nil {
+			return store.ClaimCampaign{}, err
+		}
+		campaign.Codes = append(campaign.Codes, store.ClaimCode{
+			Code:    strings.ToLower(claimCodeEncoding.EncodeToString(code)),
+			MaxUses: uses,
+		})
+	}
+
+	if err := db.SaveClaimCampaign(campaign); err != nil {
+		return store.ClaimCampaign{}, fmt.Errorf("failed to save claim campaign: %v", err)
+	}
+	return campaign, nil
+}
+
+// ClaimCampaignFor returns one of the user's claim campaigns
+func ClaimCampaignFor(publicKey, id string) (store.ClaimCampaign, error) {
+	db, err := issuerStore()
+	if err != nil {
+		return store.ClaimCampaign{}, err
+	}
+	campaign, found, err := db.LoadClaimCampaign(id)
+	if err != nil {
+		return store.ClaimCampaign{}, err
+	}
+	if !found || campaign.CreatedBy != publicKey {
+		return store.ClaimCampaign{}, errors.New("claim campaign not found")
+	}
+	return campaign, nil
+}
+
+// DeleteClaimCampaign stops a campaign started by the user, awards already claimed stay published
+func DeleteClaimCampaign(publicKey, id string) error {
+	if _, err := ClaimCampaignFor(publicKey, id); err != nil {
+		return err
+	}
+	return store.Default.DeleteClaimCampaign(id)
+}
+
+// ClaimCampaignsFor lists the user's claim campaigns, newest first
+func ClaimCampaignsFor(publicKey string) []store.ClaimCampaign {
+	db, err := issuerStore()
+	if err != nil {
+		return nil
+	}
+	campaigns, err := db.ClaimCampaigns(publicKey)
+	if err != nil {
+		Errorf("Failed to list claim campaigns: %v", err)
+		return nil
+	}
+	return campaigns
+}
+
+// LookupClaimCode returns the campaign a claim code belongs to
+func LookupClaimCode(code string) (store.ClaimCampaign, error) {
+	db, err := issuerStore()
+	if err != nil {
+		return store.ClaimCampaign{}, err
+	}
+	campaign, found, err := db.ClaimCampaignByCode(store.NormalizeClaimCode(code))
+	if err != nil {
+		return store.ClaimCampaign{}, err
+	}
+	if !found {
+		return store.ClaimCampaign{}, store.ErrClaimNotFound
+	}
+	return campaign, nil
+}
+
+// RedeemClaim uses the code for claimant and returns the kind 8 award signed by the campaign's issuer.
+// The claim stays pending until FinishClaim reports how publishing went.
+func RedeemClaim(code, claimant, relayHint string) (store.ClaimCampaign, nostr.Event, error) {
+	db, err := issuerStore()
+	if err != nil {
+		return store.ClaimCampaign{}, nostr.Event{}, err
+	}
+	code = store.NormalizeClaimCode(code)
+	// Signing and publishing take at most about one publish timeout, a claim pending for twice that was
+	// abandoned
+	campaign, err := db.RedeemClaimCode(code, claimant, time.Now(), 2*seconds(AppConfig.PublishTimeout))
+	if err != nil {
+		return store.ClaimCampaign{}, nostr.Event{}, err
+	}
+
+	award := BuildBadgeAwardEvent(campaign.Issuer, campaign.DTag, []AwardRecipient{{PubKey: claimant, RelayHint: relayHint}})
+
+	// Removing the campaign's creator from the issuer also stops their campaigns
+	err = signWithIssuer(award, campaign.CreatedBy, store.IssuerAuditEntry{
+		Actor:  claimant,
+		Action: "claim",
+		Detail: fmt.Sprintf("code %s of campaign %s", code, campaign.ID),
+	})
+	if err != nil {
+		if releaseErr := db.ReleaseClaim(campaign.ID, claimant); releaseErr != nil {
+			Errorf("Failed to release claim of %s: %v", claimant, releaseErr)
+		}
+		return store.ClaimCampaign{}, nostr.Event{}, err
+	}
+	return campaign, *award, nil
+}
+
+// FinishClaim records the published award, or gives the redemption back when no relay accepted it
+func FinishClaim(campaign store.ClaimCampaign, claimant string, award nostr.Event, results []RelayResult) {
+	RecordIssuerPublish(award, claimant, results)
+
+	var err error
+	if CountAccepted(results) > 0 {
+		err = store.Default.CompleteClaim(campaign.ID, claimant, award.ID)
+	} else {
+		err = store.Default.ReleaseClaim(campaign.ID, claimant)
+	}
+	if err != nil {
+		Errorf("Failed to record claim of %s in campaign %s: %v", claimant, campaign.ID, err)
+	}
+}
+
+func randomToken(size int) ([]byte, error) {
+	token := make([]byte, size)
+	if _, err := rand.Read(token); err != nil {
+		return nil, fmt.Errorf("failed to generate random token: %v", err)
+	}
+	return token, nil
+}
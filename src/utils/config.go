@@ -2,27 +2,384 @@ package utils
 
 import (
 	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"net/http"
+	"net/url"
 	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"badger/src/relay"
+
+	"github.com/nbd-wtf/go-nostr"
 )
 
 type Config struct {
-	Port        int    `json:"port"`
-	Development string `json:"development"`
+	Port                 int          `json:"port"`
+	ListenAddress        string       `json:"listen_address"` // Interface to bind, empty for all
+	PublicURL            string       `json:"public_url"`     // Where users reach Badger, e.g. https://badger.example.com, used in links and uploaded image URLs
+	Development          string       `json:"development"`
+	TLSCert              string       `json:"tls_cert"`               // Serve HTTPS when both the cert and key are set
+	TLSKey               string       `json:"tls_key"`                //
+	LogLevel             string       `json:"log_level"`              // debug, info, warn or error
+	SessionSecret        string       `json:"session_secret"`         // Key signing session cookies, generated into data_dir when empty
+	SessionEncryptionKey string       `json:"session_encryption_key"` // Optional AES key (16, 24 or 32 characters) encrypting session cookies
+	PreviousSessionKeys  []SessionKey `json:"previous_session_keys"`  // Retired keys still accepted until their cookies expire
+	SessionMaxAge        int          `json:"session_max_age"`        // Seconds a login lasts
+	CookieSecure         bool         `json:"cookie_secure"`          // Only send the session cookie over HTTPS, always on when serving TLS
+	CookieHTTPOnly       bool         `json:"cookie_http_only"`       // Hide the session cookie from scripts
+	CookieSameSite       string       `json:"cookie_same_site"`       // lax, strict or none
+	AdminPubKeys         []string     `json:"admin_pubkeys"`          // npubs or hex keys allowed into /admin
+	IssuerKeyPassword    string       `json:"issuer_key_password"`    // Encrypts organization issuer keys at rest (NIP-49), issuers are disabled when empty
+	MaxAwardRecipients   int          `json:"max_award_recipients"`   // Maximum "p" tags in a single kind 8 award event
+	CacheTTL             int          `json:"cache_ttl"`              // Seconds before stored events are refreshed from relays
+	MetadataTTL          int          `json:"metadata_ttl"`           // Seconds resolved profiles and NIP-05 checks are reused
+	DialTimeout          int          `json:"dial_timeout"`           // Seconds allowed to connect to a relay
+	QueryTimeout         int          `json:"query_timeout"`          // Seconds a relay gets to answer a query
+	PublishTimeout       int          `json:"publish_timeout"`        // Seconds a relay gets to accept an event
+	DataDir              string       `json:"data_dir"`               // Directory holding the local event store
+	RelayEnabled         bool         `json:"relay_enabled"`          // Serve the embedded relay at /relay
+	BootstrapRelays      []string     `json:"bootstrap_relays"`       // Where relay lists are looked up (NIP-65)
+	FallbackRelays       []string     `json:"fallback_relays"`        // Public relays searched when a user's own relays come up empty
+}
+
+// SessionKey is a signing secret and optional encryption key pair for session cookies
+type SessionKey struct {
+	Secret        string `json:"secret"`
+	EncryptionKey string `json:"encryption_key"`
+}
+
+// AppConfig holds the configuration loaded at startup
+var AppConfig = DefaultConfig()
+
+// DefaultConfig returns the settings used for anything the file, environment and flags leave out
+func DefaultConfig() *Config {
+	return &Config{
+		Port:               8787,
+		LogLevel:           "info",
+		SessionMaxAge:      30 * 24 * 60 * 60,
+		CookieHTTPOnly:     true,
+		CookieSameSite:     "lax",
+		MaxAwardRecipients: 100,
+		CacheTTL:           600,
+		MetadataTTL:        3600,
+		DialTimeout:        5,
+		QueryTimeout:       5,
+		PublishTimeout:     10,
+		DataDir:            "data",
+		BootstrapRelays: []string{
+			"wss://purplepag.es",
+			"wss://relay.damus.io",
+			"wss://nos.lol",
+			"wss://relay.primal.net",
+			"wss://relay.nostr.band",
+			"wss://offchain.pub",
+		},
+		FallbackRelays: []string{
+			"wss://nos.lol",
+			"wss://relay.damus.io",
+			"wss://relay.nostr.band",
+			"wss://relay.primal.net",
+			"wss://offchain.pub",
+			"wss://nostr.mom",
+			"wss://nostr.oxtr.dev",
+			"wss://nostr.fmt.wiz.biz",
+			"wss://nostr.bitcoiner.social",
+			"wss://relay.snort.social",
+			"wss://soloco.nl",
+		},
+	}
+}
+
+// configSetting is one option that can be given as an environment variable and a command line flag
+type configSetting struct {
+	flag    string
+	env     string
+	usage   string
+	boolean bool // The flag may be given without a value
+	set     func(config *Config, value string) error
 }
 
-func LoadConfig() (*Config, error) {
-	file, err := os.Open("config.json")
+var configSettings = []configSetting{
+	intOption("port", "BADGER_PORT", "port to listen on", func(c *Config) *int { return &c.Port }),
+	stringOption("listen", "BADGER_LISTEN_ADDRESS", "interface to listen on, empty for all", func(c *Config) *string { return &c.ListenAddress }),
+	stringOption("public-url", "BADGER_PUBLIC_URL", "URL users reach Badger at, guessed from requests when empty", func(c *Config) *string { return &c.PublicURL }),
+	stringOption("tls-cert", "BADGER_TLS_CERT", "TLS certificate file", func(c *Config) *string { return &c.TLSCert }),
+	stringOption("tls-key", "BADGER_TLS_KEY", "TLS private key file", func(c *Config) *string { return &c.TLSKey }),
+	stringOption("log-level", "BADGER_LOG_LEVEL", "debug, info, warn or error", func(c *Config) *string { return &c.LogLevel }),
+	stringOption("session-secret", "BADGER_SESSION_SECRET", "key signing session cookies", func(c *Config) *string { return &c.SessionSecret }),
+	stringOption("session-encryption-key", "BADGER_SESSION_ENCRYPTION_KEY", "optional key encrypting session cookies", func(c *Config) *string { return &c.SessionEncryptionKey }),
+	intOption("session-max-age", "BADGER_SESSION_MAX_AGE", "seconds a login lasts", func(c *Config) *int { return &c.SessionMaxAge }),
+	boolOption("cookie-secure", "BADGER_COOKIE_SECURE", "only send the session cookie over HTTPS", func(c *Config) *bool { return &c.CookieSecure }),
+	boolOption("cookie-http-only", "BADGER_COOKIE_HTTP_ONLY", "hide the session cookie from scripts", func(c *Config) *bool { return &c.CookieHTTPOnly }),
+	stringOption("cookie-same-site", "BADGER_COOKIE_SAME_SITE", "lax, strict or none", func(c *Config) *string { return &c.CookieSameSite }),
+	listOption("admin-pubkeys", "BADGER_ADMIN_PUBKEYS", "comma separated npubs allowed into /admin", func(c *Config) *[]string { return &c.AdminPubKeys }),
+	stringOption("issuer-key-password", "BADGER_ISSUER_KEY_PASSWORD", "password encrypting organization issuer keys", func(c *Config) *string { return &c.IssuerKeyPassword }),
+	intOption("max-award-recipients", "BADGER_MAX_AWARD_RECIPIENTS", "maximum recipients per award event", func(c *Config) *int { return &c.MaxAwardRecipients }),
+	intOption("cache-ttl", "BADGER_CACHE_TTL", "seconds before stored events are refreshed", func(c *Config) *int { return &c.CacheTTL }),
+	intOption("metadata-ttl", "BADGER_METADATA_TTL", "seconds profiles and NIP-05 checks are reused", func(c *Config) *int { return &c.MetadataTTL }),
+	intOption("dial-timeout", "BADGER_DIAL_TIMEOUT", "seconds allowed to connect to a relay", func(c *Config) *int { return &c.DialTimeout }),
+	intOption("query-timeout", "BADGER_QUERY_TIMEOUT", "seconds a relay gets to answer a query", func(c *Config) *int { return &c.QueryTimeout }),
+	intOption("publish-timeout", "BADGER_PUBLISH_TIMEOUT", "seconds a relay gets to accept an event", func(c *Config) *int { return &c.PublishTimeout }),
+	stringOption("data-dir", "BADGER_DATA_DIR", "directory holding the local event store", func(c *Config) *string { return &c.DataDir }),
+	boolOption("relay", "BADGER_RELAY_ENABLED", "serve the embedded relay at /relay", func(c *Config) *bool { return &c.RelayEnabled }),
+	listOption("bootstrap-relays", "BADGER_BOOTSTRAP_RELAYS", "comma separated relays where relay lists are looked up", func(c *Config) *[]string { return &c.BootstrapRelays }),
+	listOption("fallback-relays", "BADGER_FALLBACK_RELAYS", "comma separated public relays searched as a fallback", func(c *Config) *[]string { return &c.FallbackRelays }),
+}
+
+// ConfigFlags is a command line flag set with every config option, subcommands add their own flags to it
+type ConfigFlags struct {
+	*flag.FlagSet
+	configPath *string
+	values     map[string]*settingValue
+}
+
+// NewConfigFlags returns the config flags of the named command
+func NewConfigFlags(name string) *ConfigFlags {
+	flags := &ConfigFlags{
+		FlagSet: flag.NewFlagSet(name, flag.ContinueOnError),
+		values:  make(map[string]*settingValue),
+	}
+	flags.configPath = flags.String("config", "", "config file (default config.json, or $BADGER_CONFIG)")
+	for _, setting := range configSettings {
+		flags.values[setting.flag] = &settingValue{boolean: setting.boolean}
+		flags.Var(flags.values[setting.flag], setting.flag, fmt.Sprintf("%s (or $%s)", setting.usage, setting.env))
+	}
+	return flags
+}
+
+// LoadConfig builds the configuration from the defaults, then the config file, then BADGER_* environment
+// variables and finally the command line flags in args, and validates the result
+func LoadConfig(args []string) (*Config, error) {
+	return NewConfigFlags("badger").Load(args)
+}
+
+// Load parses args and builds the configuration like LoadConfig, arguments after the flags are left in Args()
+func (flags *ConfigFlags) Load(args []string) (*Config, error) {
+	config := DefaultConfig()
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	// Only a config file that was asked for explicitly has to exist
+	path, required := *flags.configPath, true
+	if path == "" {
+		path = os.Getenv("BADGER_CONFIG")
+	}
+	if path == "" {
+		path, required = "config.json", false
+	}
+	if err := config.loadFile(path, required); err != nil {
+		return nil, err
+	}
+
+	for _, setting := range configSettings {
+		if value, found := os.LookupEnv(setting.env); found {
+			if err := setting.set(config, value); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", setting.env, err)
+			}
+		}
+	}
+
+	// Flags given on the command line win over everything else
+	var flagErr error
+	flags.Visit(func(f *flag.Flag) {
+		for _, setting := range configSettings {
+			if setting.flag == f.Name && flagErr == nil {
+				if err := setting.set(config, flags.values[f.Name].value); err != nil {
+					flagErr = fmt.Errorf("invalid -%s: %w", f.Name, err)
+				}
+			}
+		}
+	})
+	if flagErr != nil {
+		return nil, flagErr
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	AppConfig = config
+	config.apply()
+	return config, nil
+}
+
+// apply pushes the settings that live outside AppConfig to the packages using them
+func (c *Config) apply() {
+	SetLogLevel(c.LogLevel)
+	relay.DefaultPool.DialTimeout = seconds(c.DialTimeout)
+	relay.DefaultPool.QueryTimeout = seconds(c.QueryTimeout)
+	relay.DefaultPool.PublishTimeout = seconds(c.PublishTimeout)
+	relay.DefaultPool.SetTrusted(append(append([]string{}, c.BootstrapRelays...), c.FallbackRelays...))
+}
+
+func (c *Config) loadFile(path string, required bool) error {
+	file, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to open config file: %v", err)
+		return fmt.Errorf("failed to open config file: %v", err)
 	}
 	defer file.Close()
 
-	var config Config
-	err = json.NewDecoder(file).Decode(&config)
-	if err != nil {
-		return nil, fmt.Errorf("failed to decode config file: %v", err)
+	decoder := json.NewDecoder(file)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(c); err != nil {
+		return fmt.Errorf("failed to decode config file %s: %v", path, err)
+	}
+	return nil
+}
+
+// Validate reports every invalid setting at once so they can all be fixed before the next start
+func (c *Config) Validate() error {
+	var problems []string
+	check := func(ok bool, format string, args ...interface{}) {
+		if !ok {
+			problems = append(problems, fmt.Sprintf(format, args...))
+		}
+	}
+
+	check(c.Port > 0 && c.Port <= 65535, "port must be between 1 and 65535, got %d", c.Port)
+	if c.PublicURL != "" {
+		parsed, err := url.Parse(c.PublicURL)
+		check(err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != "", "public_url must be an http(s) URL, got %q", c.PublicURL)
+	}
+	check((c.TLSCert == "") == (c.TLSKey == ""), "tls_cert and tls_key must be set together")
+	for _, path := range []string{c.TLSCert, c.TLSKey} {
+		if path != "" {
+			_, err := os.Stat(path)
+			check(err == nil, "TLS file %s can't be read: %v", path, err)
+		}
+	}
+	_, validLevel := logLevels[c.LogLevel]
+	check(validLevel, "log_level must be debug, info, warn or error, got %q", c.LogLevel)
+	check(c.SessionSecret == "" || len(c.SessionSecret) >= 32, "session_secret must be at least 32 characters")
+	check(validEncryptionKey(c.SessionEncryptionKey), "session_encryption_key must be 16, 24 or 32 characters")
+	for i, key := range c.PreviousSessionKeys {
+		check(len(key.Secret) >= 32, "previous_session_keys[%d].secret must be at least 32 characters", i)
+		check(validEncryptionKey(key.EncryptionKey), "previous_session_keys[%d].encryption_key must be 16, 24 or 32 characters", i)
+	}
+	check(c.SessionMaxAge > 0, "session_max_age must be positive")
+	_, validSameSite := sameSiteModes[c.CookieSameSite]
+	check(validSameSite, "cookie_same_site must be lax, strict or none, got %q", c.CookieSameSite)
+	check(c.CookieSameSite != "none" || c.SecureCookies(), "cookie_same_site none needs cookie_secure or TLS")
+	for _, admin := range c.AdminPubKeys {
+		_, _, err := DecodePubKey(admin)
+		check(err == nil, "admin_pubkeys: %v", err)
+	}
+	check(c.IssuerKeyPassword == "" || len(c.IssuerKeyPassword) >= 12, "issuer_key_password must be at least 12 characters")
+	check(c.MaxAwardRecipients > 0, "max_award_recipients must be positive")
+	check(c.CacheTTL > 0, "cache_ttl must be positive")
+	check(c.MetadataTTL > 0, "metadata_ttl must be positive")
+	check(c.DialTimeout > 0, "dial_timeout must be positive")
+	check(c.QueryTimeout > 0, "query_timeout must be positive")
+	check(c.PublishTimeout > 0, "publish_timeout must be positive")
+	check(c.DataDir != "", "data_dir must not be empty")
+	check(len(c.BootstrapRelays) > 0, "bootstrap_relays must list at least one relay")
+	for _, url := range append(append([]string{}, c.BootstrapRelays...), c.FallbackRelays...) {
+		check(nostr.IsValidRelayURL(url), "%q is not a valid relay URL", url)
 	}
 
-	return &config, nil
+	if len(problems) > 0 {
+		return fmt.Errorf("invalid configuration:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+// BaseURL is the public_url, or the scheme and host the request came in on when it isn't set
+func (c *Config) BaseURL(r *http.Request) string {
+	if c.PublicURL != "" {
+		return strings.TrimSuffix(c.PublicURL, "/")
+	}
+	scheme := "http"
+	if r.TLS != nil || r.Header.Get("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host
+}
+
+// Address is the host:port the server listens on
+func (c *Config) Address() string {
+	return fmt.Sprintf("%s:%d", c.ListenAddress, c.Port)
+}
+
+// IsAdmin reports whether the public key is one of admin_pubkeys
+func (c *Config) IsAdmin(publicKey string) bool {
+	for _, admin := range c.AdminPubKeys {
+		if pk, _, err := DecodePubKey(admin); err == nil && pk == publicKey {
+			return true
+		}
+	}
+	return false
+}
+
+// SecureCookies reports whether the session cookie is limited to HTTPS
+func (c *Config) SecureCookies() bool {
+	return c.CookieSecure || c.UseTLS()
+}
+
+// UseTLS reports whether the server should serve HTTPS
+func (c *Config) UseTLS() bool {
+	return c.TLSCert != "" && c.TLSKey != ""
+}
+
+// settingValue holds a flag's raw value until the file and environment have been applied
+type settingValue struct {
+	value   string
+	boolean bool
+}
+
+func (v *settingValue) String() string     { return v.value }
+func (v *settingValue) Set(s string) error { v.value = s; return nil }
+func (v *settingValue) IsBoolFlag() bool   { return v.boolean }
+
+func seconds(value int) time.Duration {
+	return time.Duration(value) * time.Second
+}
+
+func intOption(flag, env, usage string, field func(*Config) *int) configSetting {
+	return configSetting{flag: flag, env: env, usage: usage, set: func(c *Config, value string) error {
+		parsed, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%q is not a number", value)
+		}
+		*field(c) = parsed
+		return nil
+	}}
+}
+
+func stringOption(flag, env, usage string, field func(*Config) *string) configSetting {
+	return configSetting{flag: flag, env: env, usage: usage, set: func(c *Config, value string) error {
+		*field(c) = strings.TrimSpace(value)
+		return nil
+	}}
+}
+
+func boolOption(flag, env, usage string, field func(*Config) *bool) configSetting {
+	return configSetting{flag: flag, env: env, usage: usage, boolean: true, set: func(c *Config, value string) error {
+		parsed, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Errorf("%q is not true or false", value)
+		}
+		*field(c) = parsed
+		return nil
+	}}
+}
+
+func listOption(flag, env, usage string, field func(*Config) *[]string) configSetting {
+	return configSetting{flag: flag, env: env, usage: usage, set: func(c *Config, value string) error {
+		var list []string
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		*field(c) = list
+		return nil
+	}}
 }
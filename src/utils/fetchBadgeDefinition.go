@@ -0,0 +1,63 @@
+package utils
+
+import (
+	"sort"
+
+	"badger/src/relay"
+	"badger/src/types"
+)
+
+// FetchBadgeDefinition fetches the current version of a single badge definition, or nil if none was found
+func FetchBadgeDefinition(publicKey, dTag string, relays []string) (*types.BadgeDefinition, error) {
+	filter := types.SubscriptionFilter{
+		Authors: []string{publicKey},
+		Kinds:   []int{30009}, // Badge definition event
+		Tags:    map[string][]string{"d": {dTag}},
+	}
+
+	events, err := queryRelays(publicKey, relays, filter)
+	if err != nil {
+		Warnf("Failed to fetch badge definition %s: %v", BadgeATag(publicKey, dTag), err)
+		return nil, err
+	}
+
+	// Definitions are replaceable, only the newest event counts
+	event := relay.Newest(events)
+	if event == nil {
+		return nil, nil
+	}
+	badge := ParseBadgeDefinition(*event)
+	return &badge, nil
+}
+
+// FetchBadgeRecipients returns the pubkeys awarded a badge by its issuer, most recent awards first
+func FetchBadgeRecipients(publicKey, dTag string, relays []string) ([]string, error) {
+	filter := types.SubscriptionFilter{
+		Authors: []string{publicKey}, // Only the issuer can award their own badge
+		Kinds:   []int{8},            // Badge award event
+		Tags:    map[string][]string{"a": {BadgeATag(publicKey, dTag)}},
+	}
+
+	events, err := queryRelays(publicKey, relays, filter)
+	if err != nil {
+		Warnf("Failed to fetch awards for %s: %v", BadgeATag(publicKey, dTag), err)
+		return nil, err
+	}
+
+	sort.Slice(events, func(i, j int) bool {
+		return events[i].CreatedAt > events[j].CreatedAt
+	})
+
+	var recipients []string
+	seen := make(map[string]bool)
+	for _, event := range events {
+		for _, tag := range event.Tags {
+			if len(tag) < 2 || tag[0] != "p" || seen[tag[1]] {
+				continue
+			}
+			seen[tag[1]] = true
+			recipients = append(recipients, tag[1])
+		}
+	}
+	return recipients, nil
+}
@@ -0,0 +1,99 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"badger/src/relay"
+	"badger/src/store"
+
+	"github.com/gorilla/websocket"
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// fakeRelay answers every REQ with the given events followed by EOSE, and is trusted by the pool
+func fakeRelay(t *testing.T, events ...nostr.Event) string {
+	t.Helper()
+	upgrader := websocket.Upgrader{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		defer ws.Close()
+		for {
+			var message []json.RawMessage
+			if err := ws.ReadJSON(&message); err != nil {
+				return
+			}
+			var label, id string
+			json.Unmarshal(message[0], &label)
+			if label != "REQ" {
+				continue
+			}
+			json.Unmarshal(message[1], &id)
+			for _, event := range events {
+				ws.WriteJSON([]interface{}{"EVENT", id, event})
+			}
+			ws.WriteJSON([]interface{}{"EOSE", id})
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	relay.DefaultPool.SetTrusted([]string{url})
+	t.Cleanup(func() { relay.DefaultPool.SetTrusted(nil) })
+	return url
+}
+
+func TestFetchCreatedBadgesWithoutStore(t *testing.T) {
+	savedStore := store.Default
+	store.Default = nil
+	defer func() { store.Default = savedStore }()
+
+	secretKey := nostr.GeneratePrivateKey()
+	publicKey, _ := nostr.GetPublicKey(secretKey)
+	sign := func(kind int, createdAt nostr.Timestamp, tags nostr.Tags) nostr.Event {
+		event := nostr.Event{Kind: kind, CreatedAt: createdAt, Tags: tags}
+		if err := event.Sign(secretKey); err != nil {
+			t.Fatal(err)
+		}
+		return event
+	}
+	definition := func(dTag, name string, createdAt nostr.Timestamp) nostr.Event {
+		return sign(30009, createdAt, nostr.Tags{{"d", dTag}, {"name", name}})
+	}
+
+	removedByID := definition("removed-by-id", "Removed", 100)
+	events := []nostr.Event{
+		definition("updated", "Old name", 100),
+		definition("updated", "New name", 300),
+		definition("updated", "Middle name", 200),
+		definition("kept", "Kept", 100),
+		removedByID,
+		definition("removed", "Removed", 100),
+		definition("recreated", "Deleted version", 100),
+		definition("recreated", "Recreated", 300),
+		sign(5, 200, nostr.Tags{{"e", removedByID.ID}, {"a", BadgeATag(publicKey, "removed")}, {"a", BadgeATag(publicKey, "recreated")}}),
+	}
+
+	badges, err := FetchCreatedBadges(publicKey, []string{fakeRelay(t, events...)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]string)
+	for _, badge := range badges {
+		got[badge.DTag] = badge.Name
+	}
+	want := map[string]string{"updated": "New name", "kept": "Kept", "recreated": "Recreated"}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("badges = %v, want %v", got, want)
+	}
+	if len(badges) != len(want) {
+		t.Fatalf("got %d badges, want one per d tag", len(badges))
+	}
+}
@@ -0,0 +1,86 @@
+package utils
+
+import (
+	"sync"
+	"time"
+
+	"badger/src/types"
+)
+
+// Awarder is the profile of a badge issuer as shown next to their badges
+type Awarder struct {
+	types.UserMetadata
+	PubKey        string
+	NIP05Verified bool // The NIP-05 identifier currently points at PubKey
+}
+
+type awarderCacheEntry struct {
+	awarder   Awarder
+	fetchedAt time.Time
+}
+
+// awarderCache keeps resolved profiles in memory so every badge list doesn't hit the store again
+var awarderCache = struct {
+	sync.Mutex
+	entries map[string]awarderCacheEntry
+}{entries: make(map[string]awarderCacheEntry)}
+
+// FetchUsersMetadata resolves the kind 0 metadata of many users with a single multi-author REQ. Every
+// requested pubkey is present in the result, with empty metadata when none could be found.
+func FetchUsersMetadata(publicKeys []string, relays []string) (map[string]Awarder, error) {
+	awarders := make(map[string]Awarder, len(publicKeys))
+	ttl := seconds(AppConfig.MetadataTTL)
+
+	// Serve recently resolved users from memory and only ask relays for the rest
+	var missing []string
+	awarderCache.Lock()
+	for _, publicKey := range publicKeys {
+		if _, done := awarders[publicKey]; done {
+			continue
+		}
+		entry, found := awarderCache.entries[publicKey]
+		if found && time.Since(entry.fetchedAt) < ttl {
+			awarders[publicKey] = entry.awarder
+			continue
+		}
+		awarders[publicKey] = Awarder{PubKey: publicKey}
+		missing = append(missing, publicKey)
+	}
+	awarderCache.Unlock()
+
+	if len(missing) == 0 {
+		return awarders, nil
+	}
+
+	filter := types.SubscriptionFilter{
+		Authors: missing,
+		Kinds:   []int{0}, // Kind 0 corresponds to metadata (NIP-01)
+	}
+
+	// Metadata is published to each user's outbox relays (NIP-65)
+	events, err := queryRelays("metadata", append(OutboxRelays(missing), relays...), filter)
+	if err != nil {
+		Warnf("Failed to fetch awarder metadata: %v", err)
+		return awarders, err
+	}
+
+	// Metadata is replaceable, keep the newest event of each author
+	newest := make(map[string]types.NostrEvent)
+	for _, event := range events {
+		if existing, found := newest[event.PubKey]; !found || event.CreatedAt > existing.CreatedAt {
+			newest[event.PubKey] = event
+		}
+	}
+
+	awarderCache.Lock()
+	defer awarderCache.Unlock()
+	for _, publicKey := range missing {
+		awarder := Awarder{PubKey: publicKey}
+		if event, found := newest[publicKey]; found {
+			awarder.UserMetadata = parseUserMetadata(event)
+		}
+		awarders[publicKey] = awarder
+		awarderCache.entries[publicKey] = awarderCacheEntry{awarder: awarder, fetchedAt: time.Now()}
+	}
+	return awarders, nil
+}
@@ -0,0 +1,182 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	_ "image/gif"  // Register GIF decoding for uploads
+	_ "image/jpeg" // Register JPEG decoding for uploads
+)
+
+const (
+	MaxImageUploadSize = 5 << 20 // Largest image file accepted
+	maxImageDimension  = 4096    // Largest width or height decoded, guards against decompression bombs
+)
+
+// ThumbnailSizes are the square thumbnail sizes NIP-58 recommends for badge definitions
+var ThumbnailSizes = []int{512, 256, 64, 32, 16}
+
+// imageNamePattern matches stored image file names: the SHA-256 of the file and its extension
+var imageNamePattern = regexp.MustCompile(`^[0-9a-f]{64}\.(png|jpg|gif)$`)
+
+// imageExtensions maps decoder format names to the extension images are stored with
+var imageExtensions = map[string]string{
+	"png":  "png",
+	"jpeg": "jpg",
+	"gif":  "gif",
+}
+
+// StoredImage is a hosted image file with its real dimensions
+type StoredImage struct {
+	URL        string `json:"url"`
+	Width      int    `json:"width"`
+	Height     int    `json:"height"`
+	Dimensions string `json:"dimensions"` // "<width>x<height>" as used in NIP-58 image and thumb tags
+}
+
+// UploadedImage is an uploaded badge image and the thumbnails generated from it, largest first
+type UploadedImage struct {
+	Image  StoredImage   `json:"image"`
+	Thumbs []StoredImage `json:"thumbs"`
+}
+
+// ImagesDir is where uploaded images are stored
+func ImagesDir() string {
+	return filepath.Join(AppConfig.DataDir, "images")
+}
+
+// ImagePath returns the file of a stored image, or "" when the name isn't one Badger stores
+func ImagePath(name string) string {
+	if !imageNamePattern.MatchString(name) {
+		return ""
+	}
+	return filepath.Join(ImagesDir(), name)
+}
+
+// SaveImage stores an uploaded image and its thumbnails under their SHA-256, with URLs under baseURL
+func SaveImage(data []byte, baseURL string) (UploadedImage, error) {
+	config, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return UploadedImage{}, fmt.Errorf("unsupported image, use PNG, JPEG or GIF: %v", err)
+	}
+	extension, ok := imageExtensions[format]
+	if !ok {
+		return UploadedImage{}, fmt.Errorf("unsupported image format %s", format)
+	}
+	if config.Width > maxImageDimension || config.Height > maxImageDimension {
+		return UploadedImage{}, fmt.Errorf("image is %dx%d, the limit is %dx%d", config.Width, config.Height, maxImageDimension, maxImageDimension)
+	}
+
+	decoded, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return UploadedImage{}, fmt.Errorf("failed to decode image: %v", err)
+	}
+
+	if err := os.MkdirAll(ImagesDir(), 0700); err != nil {
+		return UploadedImage{}, fmt.Errorf("failed to create images directory: %v", err)
+	}
+
+	var uploaded UploadedImage
+	uploaded.Image, err = storeImage(data, extension, config.Width, config.Height, baseURL)
+	if err != nil {
+		return UploadedImage{}, err
+	}
+
+	// Only scale down, a small original is used as its own thumbnail
+	for _, size := range ThumbnailSizes {
+		if size >= config.Width && size >= config.Height {
+			continue
+		}
+		thumbnail := resizeToFit(decoded, size)
+
+		var encoded bytes.Buffer
+		if err := png.Encode(&encoded, thumbnail); err != nil {
+			return UploadedImage{}, fmt.Errorf("failed to encode thumbnail: %v", err)
+		}
+		bounds := thumbnail.Bounds()
+		stored, err := storeImage(encoded.Bytes(), "png", bounds.Dx(), bounds.Dy(), baseURL)
+		if err != nil {
+			return UploadedImage{}, err
+		}
+		uploaded.Thumbs = append(uploaded.Thumbs, stored)
+	}
+	if len(uploaded.Thumbs) == 0 {
+		uploaded.Thumbs = []StoredImage{uploaded.Image}
+	}
+
+	return uploaded, nil
+}
+
+// storeImage writes the file once, the same content always gets the same name
+func storeImage(data []byte, extension string, width, height int, baseURL string) (StoredImage, error) {
+	sum := sha256.Sum256(data)
+	name := hex.EncodeToString(sum[:]) + "." + extension
+	path := filepath.Join(ImagesDir(), name)
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		// Write then rename, so a half written file is never served
+		tmp := path + ".tmp"
+		if err := os.WriteFile(tmp, data, 0600); err != nil {
+			return StoredImage{}, fmt.Errorf("failed to save image: %v", err)
+		}
+		if err := os.Rename(tmp, path); err != nil {
+			return StoredImage{}, fmt.Errorf("failed to save image: %v", err)
+		}
+	}
+
+	return StoredImage{
+		URL:        baseURL + "/images/" + name,
+		Width:      width,
+		Height:     height,
+		Dimensions: fmt.Sprintf("%dx%d", width, height),
+	}, nil
+}
+
+// resizeToFit scales an image down to fit a size x size box, averaging the source pixels under each
+// destination pixel
+func resizeToFit(src image.Image, size int) *image.NRGBA {
+	bounds := src.Bounds()
+	width, height := size, size
+	if bounds.Dx() > bounds.Dy() {
+		height = max(1, bounds.Dy()*size/bounds.Dx())
+	} else if bounds.Dy() > bounds.Dx() {
+		width = max(1, bounds.Dx()*size/bounds.Dy())
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		y0 := bounds.Min.Y + y*bounds.Dy()/height
+		y1 := max(y0+1, bounds.Min.Y+(y+1)*bounds.Dy()/height)
+		for x := 0; x < width; x++ {
+			x0 := bounds.Min.X + x*bounds.Dx()/width
+			x1 := max(x0+1, bounds.Min.X+(x+1)*bounds.Dx()/width)
+
+			var r, g, b, a, count uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r, g, b, a = r+uint64(pr), g+uint64(pg), b+uint64(pb), a+uint64(pa)
+					count++
+				}
+			}
+
+			// Average in premultiplied alpha, then convert back for the NRGBA image
+			pixel := color.RGBA64{
+				R: uint16(r / count),
+				G: uint16(g / count),
+				B: uint16(b / count),
+				A: uint16(a / count),
+			}
+			dst.Set(x, y, pixel)
+		}
+	}
+	return dst
+}
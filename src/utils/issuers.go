@@ -0,0 +1,400 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"badger/src/relay"
+	"badger/src/store"
+	"badger/src/types"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+	"github.com/nbd-wtf/go-nostr/nip49"
+)
+
+// issuerKeyLogN is the NIP-49 scrypt cost issuer keys are encrypted with
+const issuerKeyLogN = 16
+
+// issuerKeyTTL is how long a decrypted issuer key stays in memory, NIP-49 decryption is deliberately slow
+const issuerKeyTTL = 10 * time.Minute
+
+// ErrNotIssuerMember is returned when a user tries to act as an issuer they don't belong to
+var ErrNotIssuerMember = errors.New("not a member of this issuer")
+
+// issuerEventTags lists the kinds an issuer key signs and the tags each may carry: badge definitions,
+// awards and deletions of those
+var issuerEventTags = map[int][]string{
+	30009: {"d", "name", "description", "image", "thumb", "e"}, // e: the version an update replaces
+	8:     {"a", "p"},
+	5:     {"e", "a", "k"},
+}
+
+// issuerKeys caches decrypted issuer keys by public key, so signing doesn't run scrypt every time
+var issuerKeys = struct {
+	sync.Mutex
+	keys map[string]issuerKey
+}{keys: make(map[string]issuerKey)}
+
+type issuerKey struct {
+	ncryptsec string // The encrypted key this was decrypted from, a new import replaces it
+	secretKey string
+	expires   time.Time
+}
+
+// issuerStore returns the store holding issuers, failing when they can't be used on this instance
+func issuerStore() (*store.Store, error) {
+	if AppConfig.IssuerKeyPassword == "" {
+		return nil, errors.New("organization issuers are disabled, set issuer_key_password to enable them")
+	}
+	if store.Default == nil {
+		return nil, errors.New("organization issuers need the local store")
+	}
+	return store.Default, nil
+}
+
+// ImportIssuer stores an organization key, given as nsec, hex or ncryptsec (with keyPassword), encrypted
+// with the instance's issuer password. Importing a key again renames it and keeps its members.
+func ImportIssuer(name, key, keyPassword, admin string) (store.Issuer, error) {
+	db, err := issuerStore()
+	if err != nil {
+		return store.Issuer{}, err
+	}
+
+	secretKey, err := DecodeSecretKey(key, keyPassword)
+	if err != nil {
+		return store.Issuer{}, err
+	}
+	publicKey, err := nostr.GetPublicKey(secretKey)
+	if err != nil {
+		return store.Issuer{}, fmt.Errorf("invalid secret key: %v", err)
+	}
+
+	encrypted, err := nip49.Encrypt(secretKey, AppConfig.IssuerKeyPassword, issuerKeyLogN, nip49.ClientDoesNotTrackThisData)
+	if err != nil {
+		return store.Issuer{}, fmt.Errorf("failed to encrypt issuer key: %v", err)
+	}
+
+	issuer, found, err := db.LoadIssuer(publicKey)
+	if err != nil {
+		return store.Issuer{}, err
+	}
+	if !found {
+		issuer = store.Issuer{PubKey: publicKey, CreatedAt: time.Now(), CreatedBy: admin}
+	}
+	issuer.Name = strings.TrimSpace(name)
+	if issuer.Name == "" {
+		issuer.Name = ShortNPub(publicKey)
+	}
+	issuer.NCryptSec = encrypted
+
+	if err := db.SaveIssuer(issuer); err != nil {
+		return store.Issuer{}, fmt.Errorf("failed to save issuer: %v", err)
+	}
+	recordIssuerUse(store.IssuerAuditEntry{Issuer: publicKey, Actor: admin, Action: "import", Detail: issuer.Name})
+	return issuer, nil
+}
+
+// DecodeSecretKey accepts an nsec, a hex secret key or an ncryptsec (NIP-49) with its password
+func DecodeSecretKey(key, password string) (string, error) {
+	key = strings.TrimSpace(key)
+	switch {
+	case strings.HasPrefix(key, "ncryptsec1"):
+		secretKey, err := nip49.Decrypt(key, password)
+		if err != nil {
+			return "", fmt.Errorf("failed to decrypt ncryptsec, check its password: %v", err)
+		}
+		return secretKey, nil
+	case strings.HasPrefix(key, "nsec1"):
+		prefix, value, err := nip19.Decode(key)
+		if err != nil || prefix != "nsec" {
+			return "", errors.New("invalid nsec")
+		}
+		return value.(string), nil
+	case nostr.IsValid32ByteHex(strings.ToLower(key)):
+		return strings.ToLower(key), nil
+	}
+	return "", errors.New("expected an nsec, ncryptsec or hex secret key")
+}
+
+// IssuersFor lists the issuers a user may create definitions and awards as
+func IssuersFor(publicKey string) []store.Issuer {
+	db, err := issuerStore()
+	if err != nil {
+		return nil
+	}
+	issuers, err := db.Issuers()
+	if err != nil {
+		Errorf("Failed to list issuers: %v", err)
+		return nil
+	}
+
+	var memberOf []store.Issuer
+	for _, issuer := range issuers {
+		if issuer.HasMember(publicKey) {
+			memberOf = append(memberOf, issuer)
+		}
+	}
+	return memberOf
+}
+
+// CanIssueAs reports whether the user may sign as the issuer
+func CanIssueAs(publicKey, issuerPubKey string) bool {
+	db, err := issuerStore()
+	if err != nil {
+		return false
+	}
+	issuer, found, err := db.LoadIssuer(issuerPubKey)
+	return err == nil && found && issuer.HasMember(publicKey)
+}
+
+// SignAsIssuer signs the event with an issuer key the user is a member of and records the use
+func SignAsIssuer(event *nostr.Event, publicKey string) error {
+	return signWithIssuer(event, publicKey, store.IssuerAuditEntry{Actor: publicKey, Action: "sign"})
+}
+
+// signWithIssuer signs the event as its pubkey when member belongs to that issuer, recording entry in
+// the audit log
+func signWithIssuer(event *nostr.Event, member string, entry store.IssuerAuditEntry) error {
+	db, err := issuerStore()
+	if err != nil {
+		return err
+	}
+	issuer, found, err := db.LoadIssuer(event.PubKey)
+	if err != nil {
+		return err
+	}
+	if !found || !issuer.HasMember(member) {
+		return ErrNotIssuerMember
+	}
+	if err := checkIssuerEvent(*event); err != nil {
+		return err
+	}
+
+	secretKey, err := issuerSecretKey(issuer)
+	if err != nil {
+		return err
+	}
+	if err := event.Sign(secretKey); err != nil {
+		return fmt.Errorf("failed to sign as issuer: %v", err)
+	}
+
+	entry.Issuer = issuer.PubKey
+	entry.Kind = event.Kind
+	entry.EventID = event.ID
+	recordIssuerUse(entry)
+	return nil
+}
+
+// checkIssuerEvent only lets issuer keys sign badge definitions, awards of the issuer's badges and
+// deletions of the issuer's own definitions and awards
+func checkIssuerEvent(event nostr.Event) error {
+	allowed, found := issuerEventTags[event.Kind]
+	if !found {
+		return fmt.Errorf("issuer keys don't sign kind %d events", event.Kind)
+	}
+	for _, tag := range event.Tags {
+		if len(tag) < 2 || !slices.Contains(allowed, tag[0]) {
+			return fmt.Errorf("issuer keys don't sign kind %d events with %q tags", event.Kind, tag.Key())
+		}
+	}
+
+	ownBadge := fmt.Sprintf("30009:%s:", event.PubKey)
+	switch event.Kind {
+	case 30009:
+		if event.Tags.GetD() == "" || event.Tags.GetFirst([]string{"name", ""}) == nil {
+			return errors.New("badge definitions need a d and a name tag")
+		}
+	case 8:
+		badges := event.Tags.GetAll([]string{"a"})
+		if len(badges) != 1 || !strings.HasPrefix(badges[0].Value(), ownBadge) {
+			return errors.New("awards must name exactly one of the issuer's badges")
+		}
+		recipients := event.Tags.GetAll([]string{"p"})
+		if len(recipients) == 0 {
+			return errors.New("awards need at least one recipient")
+		}
+		for _, tag := range recipients {
+			if !nostr.IsValidPublicKeyHex(tag.Value()) {
+				return fmt.Errorf("invalid recipient %q", tag.Value())
+			}
+		}
+	case 5:
+		var ids []string
+		for _, tag := range event.Tags {
+			switch tag[0] {
+			case "a":
+				if !strings.HasPrefix(tag[1], ownBadge) {
+					return errors.New("issuer keys only delete the issuer's own badge definitions")
+				}
+			case "e":
+				ids = append(ids, tag[1])
+			case "k":
+				if tag[1] != "8" && tag[1] != "30009" {
+					return errors.New("issuer keys only delete badge definitions and awards")
+				}
+			}
+		}
+		if len(ids) == 0 && event.Tags.GetFirst([]string{"a", ""}) == nil {
+			return errors.New("the deletion references no event")
+		}
+		if len(ids) > 0 && !issuerOwnsEvents(event.PubKey, ids) {
+			return errors.New("issuer keys only delete the issuer's own badge definitions and awards")
+		}
+	}
+	return nil
+}
+
+// issuerOwnsEvents reports whether every id is a badge definition or award signed by the issuer, looking in
+// the store first and then on the issuer's write relays
+func issuerOwnsEvents(issuer string, ids []string) bool {
+	filter := types.SubscriptionFilter{IDs: ids, Authors: []string{issuer}, Kinds: []int{8, 30009}}
+
+	found := make(map[string]bool)
+	if store.Default != nil {
+		events, err := store.Default.Query(filter)
+		if err != nil {
+			Warnf("Failed to look up issuer events: %v", err)
+		}
+		for _, event := range events {
+			found[event.ID] = true
+		}
+	}
+
+	var missing []string
+	for _, id := range ids {
+		if !found[id] {
+			missing = append(missing, id)
+		}
+	}
+	if len(missing) == 0 {
+		return true
+	}
+
+	relays, err := FetchUserRelays(issuer, AppConfig.BootstrapRelays)
+	if err != nil {
+		return false
+	}
+	filter.IDs = missing
+	events, err := relay.DefaultPool.Query(context.Background(), relays.WriteRelays(), filter)
+	if err != nil {
+		Warnf("Failed to look up issuer events: %v", err)
+		return false
+	}
+	for _, event := range events {
+		if event.PubKey == issuer && (event.Kind == 8 || event.Kind == 30009) {
+			found[event.ID] = true
+		}
+	}
+	for _, id := range missing {
+		if !found[id] {
+			return false
+		}
+	}
+	return true
+}
+
+// issuerSecretKey decrypts the issuer's key, or returns it from the cache
+func issuerSecretKey(issuer store.Issuer) (string, error) {
+	issuerKeys.Lock()
+	defer issuerKeys.Unlock()
+
+	if cached, found := issuerKeys.keys[issuer.PubKey]; found && cached.ncryptsec == issuer.NCryptSec && time.Now().Before(cached.expires) {
+		return cached.secretKey, nil
+	}
+
+	// Held under the lock so concurrent signatures wait for one decryption instead of each running scrypt
+	secretKey, err := nip49.Decrypt(issuer.NCryptSec, AppConfig.IssuerKeyPassword)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt issuer key: %v", err)
+	}
+	for pubKey, cached := range issuerKeys.keys {
+		if time.Now().After(cached.expires) {
+			delete(issuerKeys.keys, pubKey)
+		}
+	}
+	issuerKeys.keys[issuer.PubKey] = issuerKey{ncryptsec: issuer.NCryptSec, secretKey: secretKey, expires: time.Now().Add(issuerKeyTTL)}
+	return secretKey, nil
+}
+
+// forgetIssuerKey drops the issuer's decrypted key from memory
+func forgetIssuerKey(issuerPubKey string) {
+	issuerKeys.Lock()
+	delete(issuerKeys.keys, issuerPubKey)
+	issuerKeys.Unlock()
+}
+
+// SetIssuerMember adds or removes a user allowed to act as the issuer
+func SetIssuerMember(issuerPubKey, member string, allowed bool, admin string) error {
+	db, err := issuerStore()
+	if err != nil {
+		return err
+	}
+	issuer, found, err := db.LoadIssuer(issuerPubKey)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return fmt.Errorf("issuer %s not found", issuerPubKey)
+	}
+
+	var members []string
+	for _, existing := range issuer.Members {
+		if existing != member {
+			members = append(members, existing)
+		}
+	}
+	action := "remove-member"
+	if allowed {
+		members = append(members, member)
+		action = "add-member"
+	}
+	issuer.Members = members
+
+	if err := db.SaveIssuer(issuer); err != nil {
+		return fmt.Errorf("failed to save issuer: %v", err)
+	}
+	recordIssuerUse(store.IssuerAuditEntry{Issuer: issuerPubKey, Actor: admin, Action: action, Detail: member})
+	return nil
+}
+
+// DeleteIssuer removes an issuer and its encrypted key
+func DeleteIssuer(issuerPubKey, admin string) error {
+	db, err := issuerStore()
+	if err != nil {
+		return err
+	}
+	if err := db.DeleteIssuer(issuerPubKey); err != nil {
+		return fmt.Errorf("failed to delete issuer: %v", err)
+	}
+	forgetIssuerKey(issuerPubKey)
+	recordIssuerUse(store.IssuerAuditEntry{Issuer: issuerPubKey, Actor: admin, Action: "delete"})
+	return nil
+}
+
+// RecordIssuerPublish logs where an event signed by an issuer was published
+func RecordIssuerPublish(event nostr.Event, publicKey string, results []RelayResult) {
+	recordIssuerUse(store.IssuerAuditEntry{
+		Issuer:  event.PubKey,
+		Actor:   publicKey,
+		Action:  "publish",
+		Kind:    event.Kind,
+		EventID: event.ID,
+		Detail:  fmt.Sprintf("accepted by %d of %d relays", CountAccepted(results), len(results)),
+	})
+}
+
+func recordIssuerUse(entry store.IssuerAuditEntry) {
+	entry.Time = time.Now()
+	if store.Default == nil {
+		return
+	}
+	if err := store.Default.AppendIssuerAudit(entry); err != nil {
+		Errorf("Failed to record issuer %s %s by %s: %v", entry.Issuer, entry.Action, entry.Actor, err)
+	}
+}
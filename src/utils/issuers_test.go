@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"badger/src/store"
+	"badger/src/types"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// testIssuer imports a fresh organization key with one member into a temporary store, without any relays
+func testIssuer(t *testing.T) (issuerPubKey, issuerSecretKey, member string) {
+	t.Helper()
+	db, err := store.Open(filepath.Join(t.TempDir(), "events.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	savedStore, savedConfig := store.Default, *AppConfig
+	t.Cleanup(func() {
+		store.Default = savedStore
+		*AppConfig = savedConfig
+		db.Close()
+	})
+	store.Default = db
+	AppConfig.IssuerKeyPassword = "correct horse battery"
+	AppConfig.BootstrapRelays = nil
+	AppConfig.FallbackRelays = nil
+
+	issuerSecretKey = nostr.GeneratePrivateKey()
+	issuer, err := ImportIssuer("Org", issuerSecretKey, "", "admin")
+	if err != nil {
+		t.Fatal(err)
+	}
+	member, _ = nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	if err := SetIssuerMember(issuer.PubKey, member, true, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	return issuer.PubKey, issuerSecretKey, member
+}
+
+func awardEvent(issuer, recipient string) *nostr.Event {
+	return &nostr.Event{
+		PubKey:    issuer,
+		CreatedAt: nostr.Now(),
+		Kind:      8,
+		Tags:      nostr.Tags{{"a", "30009:" + issuer + ":badge"}, {"p", recipient}},
+	}
+}
+
+func TestSignAsIssuerMembership(t *testing.T) {
+	issuer, _, member := testIssuer(t)
+	outsider, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	formerMember, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	if err := SetIssuerMember(issuer, formerMember, true, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetIssuerMember(issuer, formerMember, false, "admin"); err != nil {
+		t.Fatal(err)
+	}
+	unknownIssuer, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+
+	tests := []struct {
+		name   string
+		signer string
+		event  *nostr.Event
+		err    error
+	}{
+		{"member", member, awardEvent(issuer, member), nil},
+		{"non-member", outsider, awardEvent(issuer, outsider), ErrNotIssuerMember},
+		{"removed member", formerMember, awardEvent(issuer, formerMember), ErrNotIssuerMember},
+		{"the issuer itself is no member", issuer, awardEvent(issuer, member), ErrNotIssuerMember},
+		{"unknown issuer", member, awardEvent(unknownIssuer, member), ErrNotIssuerMember},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := SignAsIssuer(test.event, test.signer)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("SignAsIssuer = %v, want %v", err, test.err)
+			}
+			if err != nil {
+				if test.event.Sig != "" {
+					t.Fatal("a rejected event was signed")
+				}
+				return
+			}
+			if test.event.PubKey != issuer || types.FromNostrEvent(*test.event).Verify() != nil {
+				t.Fatal("the event is not correctly signed by the issuer")
+			}
+		})
+	}
+
+	if !CanIssueAs(member, issuer) || CanIssueAs(outsider, issuer) || CanIssueAs(formerMember, issuer) {
+		t.Fatal("CanIssueAs disagrees with the membership")
+	}
+}
+
+func TestSignAsIssuerDisabled(t *testing.T) {
+	issuer, _, member := testIssuer(t)
+	AppConfig.IssuerKeyPassword = ""
+	if err := SignAsIssuer(awardEvent(issuer, member), member); err == nil || errors.Is(err, ErrNotIssuerMember) {
+		t.Fatalf("signed with issuers disabled: %v", err)
+	}
+}
+
+func TestSignAsIssuerAudit(t *testing.T) {
+	issuer, _, member := testIssuer(t)
+	event := awardEvent(issuer, member)
+	if err := SignAsIssuer(event, member); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := store.Default.IssuerAudit(issuer, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.Action == "sign" && entry.Actor == member && entry.EventID == event.ID {
+			return
+		}
+	}
+	t.Fatalf("no audit entry for the signature in %+v", entries)
+}
+
+func TestCheckIssuerEvent(t *testing.T) {
+	issuer, issuerSecretKey, member := testIssuer(t)
+	other, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	ownBadge := "30009:" + issuer + ":badge"
+
+	// An award the issuer already published, which it may delete
+	published := awardEvent(issuer, member)
+	published.Sign(issuerSecretKey)
+	if err := store.Default.Save(types.FromNostrEvent(*published)); err != nil {
+		t.Fatal(err)
+	}
+	// Someone else's award, which it may not
+	foreign := awardEvent(other, member)
+	foreign.Sign(nostr.GeneratePrivateKey())
+
+	tests := []struct {
+		name  string
+		kind  int
+		tags  nostr.Tags
+		valid bool
+	}{
+		{"definition", 30009, nostr.Tags{{"d", "badge"}, {"name", "Badge"}, {"description", "x"}, {"image", "https://img", "1024x1024"}, {"thumb", "https://t"}}, true},
+		{"definition update", 30009, nostr.Tags{{"d", "badge"}, {"name", "Badge"}, {"e", published.ID}}, true},
+		{"definition without name", 30009, nostr.Tags{{"d", "badge"}}, false},
+		{"definition without d", 30009, nostr.Tags{{"name", "Badge"}}, false},
+		{"definition with a p tag", 30009, nostr.Tags{{"d", "badge"}, {"name", "Badge"}, {"p", member}}, false},
+		{"award", 8, nostr.Tags{{"a", ownBadge}, {"p", member}, {"p", other}}, true},
+		{"award of another issuer's badge", 8, nostr.Tags{{"a", "30009:" + other + ":badge"}, {"p", member}}, false},
+		{"award of two badges", 8, nostr.Tags{{"a", ownBadge}, {"a", ownBadge + "2"}, {"p", member}}, false},
+		{"award without recipients", 8, nostr.Tags{{"a", ownBadge}}, false},
+		{"award to an invalid key", 8, nostr.Tags{{"a", ownBadge}, {"p", "npub1xyz"}}, false},
+		{"award with relay hint", 8, nostr.Tags{{"a", ownBadge}, {"p", member, "wss://relay"}}, true},
+		{"award with other tags", 8, nostr.Tags{{"a", ownBadge}, {"p", member}, {"t", "spam"}}, false},
+		{"empty tag", 8, nostr.Tags{{"a", ownBadge}, {"p", member}, {"p"}}, false},
+		{"delete own definition", 5, nostr.Tags{{"a", ownBadge}, {"k", "30009"}}, true},
+		{"delete own award", 5, nostr.Tags{{"e", published.ID}, {"k", "8"}}, true},
+		{"delete another issuer's definition", 5, nostr.Tags{{"a", "30009:" + other + ":badge"}}, false},
+		{"delete someone else's award", 5, nostr.Tags{{"e", foreign.ID}}, false},
+		{"delete an unknown event", 5, nostr.Tags{{"e", strings.Repeat("0", 64)}}, false},
+		{"delete other kinds", 5, nostr.Tags{{"a", ownBadge}, {"k", "1"}}, false},
+		{"delete nothing", 5, nostr.Tags{{"k", "8"}}, false},
+		{"note", 1, nostr.Tags{}, false},
+		{"metadata", 0, nostr.Tags{}, false},
+		{"relay list", 10002, nostr.Tags{{"r", "wss://relay"}}, false},
+		{"profile badges", 30008, nostr.Tags{{"d", "profile_badges"}}, false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			event := nostr.Event{PubKey: issuer, CreatedAt: nostr.Now(), Kind: test.kind, Tags: test.tags}
+			if err := checkIssuerEvent(event); (err == nil) != test.valid {
+				t.Fatalf("checkIssuerEvent = %v, want valid %v", err, test.valid)
+			}
+			// Members get the same answer when signing
+			err := SignAsIssuer(&event, member)
+			if (err == nil) != test.valid {
+				t.Fatalf("SignAsIssuer = %v, want valid %v", err, test.valid)
+			}
+		})
+	}
+}
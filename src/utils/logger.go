@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"log"
+	"strings"
+)
+
+// logLevels orders the accepted log_level values, lower is more verbose
+var logLevels = map[string]int{
+	"debug": 0,
+	"info":  1,
+	"warn":  2,
+	"error": 3,
+}
+
+var currentLogLevel = logLevels["info"]
+
+// SetLogLevel changes which of Debugf, Infof, Warnf and Errorf are written. Debug also adds file and
+// line to every log line.
+func SetLogLevel(level string) {
+	value, found := logLevels[strings.ToLower(level)]
+	if !found {
+		return
+	}
+	currentLogLevel = value
+
+	if value == logLevels["debug"] {
+		log.SetFlags(log.LstdFlags | log.Lshortfile)
+	} else {
+		log.SetFlags(log.LstdFlags)
+	}
+}
+
+// Debugf logs request tracing that is only useful while developing
+func Debugf(format string, args ...interface{}) {
+	logAt("debug", format, args...)
+}
+
+// Infof logs normal operation such as startup settings
+func Infof(format string, args ...interface{}) {
+	logAt("info", format, args...)
+}
+
+// Warnf logs problems Badger recovers from
+func Warnf(format string, args ...interface{}) {
+	logAt("warn", format, args...)
+}
+
+// Errorf logs failures such as a request Badger couldn't serve
+func Errorf(format string, args ...interface{}) {
+	logAt("error", format, args...)
+}
+
+func logAt(level, format string, args ...interface{}) {
+	if logLevels[level] < currentLogLevel {
+		return
+	}
+	log.Printf(strings.ToUpper(level)+" "+format, args...)
+}
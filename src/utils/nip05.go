@@ -0,0 +1,217 @@
+package utils
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"badger/src/relay"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip05"
+)
+
+const (
+	nip05Timeout       = 5 * time.Second // For each lookup
+	nip05FailureTTL    = time.Minute     // How long an unreachable domain isn't asked again
+	nip05CacheSize     = 10000           // Default number of remembered identifiers
+	nip05LookupWorkers = 8               // Lookups VerifyAwarders runs at once
+)
+
+// NIP05Verifier looks up NIP-05 identifiers in /.well-known/nostr.json and remembers the answers
+type NIP05Verifier struct {
+	Client     *http.Client  // Replaceable so tests can answer with a local stand-in
+	TTL        time.Duration // How long answers are reused, AppConfig.MetadataTTL when zero
+	MaxEntries int           // Identifiers remembered at most, nip05CacheSize when zero
+
+	mu    sync.Mutex
+	cache map[string]nip05CacheEntry
+}
+
+type nip05CacheEntry struct {
+	pointer *nostr.ProfilePointer
+	err     error
+	expires time.Time
+}
+
+// DefaultNIP05Verifier is used for recipient lookups and issuer verification. Domains come from
+// profiles anyone can publish, so it only connects to public addresses, without a proxy.
+var DefaultNIP05Verifier = NewNIP05Verifier(&http.Client{
+	Timeout: nip05Timeout,
+	Transport: &http.Transport{
+		DialContext:         (&net.Dialer{Timeout: nip05Timeout, Control: relay.PublicOnly}).DialContext,
+		TLSHandshakeTimeout: nip05Timeout,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+	},
+	// NIP-05 forbids following redirects
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}, 0)
+
+func NewNIP05Verifier(client *http.Client, ttl time.Duration) *NIP05Verifier {
+	return &NIP05Verifier{
+		Client: client,
+		TTL:    ttl,
+		cache:  make(map[string]nip05CacheEntry),
+	}
+}
+
+func (v *NIP05Verifier) ttl() time.Duration {
+	if v.TTL > 0 {
+		return v.TTL
+	}
+	return seconds(AppConfig.MetadataTTL)
+}
+
+// Lookup resolves an identifier such as bob@example.com to its public key and relays, giving up after
+// nip05Timeout
+func (v *NIP05Verifier) Lookup(ctx context.Context, identifier string) (*nostr.ProfilePointer, error) {
+	name, domain, err := nip05.ParseIdentifier(strings.ToLower(strings.TrimSpace(identifier)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid NIP-05 identifier %q", identifier)
+	}
+	key := name + "@" + domain
+
+	v.mu.Lock()
+	entry, found := v.cache[key]
+	v.mu.Unlock()
+	if found && time.Now().Before(entry.expires) {
+		return entry.pointer, entry.err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, nip05Timeout)
+	defer cancel()
+	pointer, unreachable, err := v.fetch(ctx, name, domain)
+
+	// Answers and missing names are kept for the TTL, an unreachable domain is only left alone briefly
+	ttl := v.ttl()
+	if unreachable {
+		ttl = nip05FailureTTL
+	}
+	v.mu.Lock()
+	v.remember(key, nip05CacheEntry{pointer: pointer, err: err, expires: time.Now().Add(ttl)})
+	v.mu.Unlock()
+	return pointer, err
+}
+
+// Cached returns an earlier answer for the identifier without asking its domain, found is false when
+// there's no unexpired answer
+func (v *NIP05Verifier) Cached(identifier string) (pointer *nostr.ProfilePointer, found bool) {
+	name, domain, err := nip05.ParseIdentifier(strings.ToLower(strings.TrimSpace(identifier)))
+	if err != nil {
+		return nil, false
+	}
+
+	v.mu.Lock()
+	entry, found := v.cache[name+"@"+domain]
+	v.mu.Unlock()
+	if !found || !time.Now().Before(entry.expires) || entry.err != nil {
+		return nil, false
+	}
+	return entry.pointer, true
+}
+
+// remember caches an entry, first dropping expired entries and then the ones closest to expiring when
+// the cache is full. Must be called with v.mu held.
+func (v *NIP05Verifier) remember(key string, entry nip05CacheEntry) {
+	maxEntries := v.MaxEntries
+	if maxEntries <= 0 {
+		maxEntries = nip05CacheSize
+	}
+	if _, found := v.cache[key]; !found && len(v.cache) >= maxEntries {
+		now := time.Now()
+		for cached, e := range v.cache {
+			if now.After(e.expires) {
+				delete(v.cache, cached)
+			}
+		}
+		for len(v.cache) >= maxEntries {
+			var oldest string
+			for cached, e := range v.cache {
+				if oldest == "" || e.expires.Before(v.cache[oldest].expires) {
+					oldest = cached
+				}
+			}
+			delete(v.cache, oldest)
+		}
+	}
+	v.cache[key] = entry
+}
+
+// fetch asks the domain for the name, unreachable is true when the failure says nothing about the name
+// (network errors, timeouts, server errors)
+func (v *NIP05Verifier) fetch(ctx context.Context, name, domain string) (pointer *nostr.ProfilePointer, unreachable bool, err error) {
+	wellKnown := fmt.Sprintf("https://%s/.well-known/nostr.json?name=%s", domain, url.QueryEscape(name))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, wellKnown, nil)
+	if err != nil {
+		return nil, false, err
+	}
+
+	res, err := v.Client.Do(req)
+	if err != nil {
+		return nil, true, fmt.Errorf("failed to fetch %s: %w", wellKnown, err)
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		unreachable := res.StatusCode >= 500 || res.StatusCode == http.StatusTooManyRequests
+		return nil, unreachable, fmt.Errorf("%s answered with status %d", wellKnown, res.StatusCode)
+	}
+
+	var document nip05.WellKnownResponse
+	if err := json.NewDecoder(res.Body).Decode(&document); err != nil {
+		return nil, ctx.Err() != nil, fmt.Errorf("failed to decode %s: %w", wellKnown, err)
+	}
+
+	publicKey, found := document.Names[name]
+	if !found || !nostr.IsValidPublicKeyHex(publicKey) {
+		return nil, false, fmt.Errorf("no valid public key for %s@%s", name, domain)
+	}
+	return &nostr.ProfilePointer{PublicKey: publicKey, Relays: document.Relays[publicKey]}, false, nil
+}
+
+// Verify reports whether the identifier currently points at the public key
+func (v *NIP05Verifier) Verify(ctx context.Context, identifier, publicKey string) bool {
+	pointer, err := v.Lookup(ctx, identifier)
+	return err == nil && pointer.PublicKey == publicKey
+}
+
+// VerifyAwarders checks the NIP-05 of every awarder, a few at a time, and records the outcome
+func (v *NIP05Verifier) VerifyAwarders(awarders map[string]Awarder) {
+	var pending []Awarder
+	var publicKeys []string
+	for publicKey, awarder := range awarders {
+		if awarder.NIP05 != "" {
+			pending = append(pending, awarder)
+			publicKeys = append(publicKeys, publicKey)
+		}
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	workers := make(chan struct{}, nip05LookupWorkers)
+	for i, awarder := range pending {
+		publicKey := publicKeys[i]
+		wg.Add(1)
+		workers <- struct{}{}
+		go func(publicKey string, awarder Awarder) {
+			defer func() {
+				<-workers
+				wg.Done()
+			}()
+			awarder.NIP05Verified = v.Verify(context.Background(), awarder.NIP05, publicKey)
+
+			mu.Lock()
+			awarders[publicKey] = awarder
+			mu.Unlock()
+		}(publicKey, awarder)
+	}
+	wg.Wait()
+}
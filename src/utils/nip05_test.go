@@ -0,0 +1,173 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// roundTripFunc answers requests without a network
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return f(r)
+}
+
+func answer(status int, body string) *http.Response {
+	return &http.Response{StatusCode: status, Body: io.NopCloser(strings.NewReader(body)), Header: http.Header{}}
+}
+
+const bobKey = "82341f882b6eabcd2ba7f1ef90aad961cf074af15b9ef44a09f9d2a8fbfbe6a2"
+
+// testVerifier serves the well-known documents of a few domains and counts the requests
+func testVerifier(calls *atomic.Int32) *NIP05Verifier {
+	client := &http.Client{Transport: roundTripFunc(func(r *http.Request) (*http.Response, error) {
+		calls.Add(1)
+		switch r.URL.Host {
+		case "example.com":
+			return answer(http.StatusOK, fmt.Sprintf(`{"names":{"bob":%q},"relays":{%q:["wss://bob.relay"]}}`, bobKey, bobKey)), nil
+		case "broken.example":
+			return answer(http.StatusOK, `{"names":{"bob":"not a key"}}`), nil
+		case "missing.example":
+			return answer(http.StatusNotFound, ""), nil
+		case "busy.example":
+			return answer(http.StatusServiceUnavailable, ""), nil
+		}
+		return nil, errors.New("connection refused")
+	})}
+	return NewNIP05Verifier(client, time.Hour)
+}
+
+func TestNIP05Lookup(t *testing.T) {
+	tests := []struct {
+		name        string
+		identifier  string
+		publicKey   string
+		cachedFor   time.Duration
+		shouldError bool
+	}{
+		{"found", "bob@example.com", bobKey, time.Hour, false},
+		{"case and spaces", "  Bob@Example.com ", bobKey, time.Hour, false},
+		{"unknown name", "alice@example.com", "", time.Hour, true},
+		{"invalid key", "bob@broken.example", "", time.Hour, true},
+		{"not found", "bob@missing.example", "", time.Hour, true},
+		{"server error", "bob@busy.example", "", nip05FailureTTL, true},
+		{"unreachable", "bob@offline.example", "", nip05FailureTTL, true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var calls atomic.Int32
+			verifier := testVerifier(&calls)
+
+			for i := 0; i < 2; i++ {
+				pointer, err := verifier.Lookup(context.Background(), test.identifier)
+				if (err != nil) != test.shouldError {
+					t.Fatalf("error = %v, want error %v", err, test.shouldError)
+				}
+				if err == nil && pointer.PublicKey != test.publicKey {
+					t.Fatalf("public key = %s, want %s", pointer.PublicKey, test.publicKey)
+				}
+			}
+			if calls.Load() != 1 {
+				t.Errorf("%d requests, the second lookup should be cached", calls.Load())
+			}
+
+			key := strings.ToLower(strings.TrimSpace(test.identifier))
+			expires := verifier.cache[key].expires
+			if remaining := time.Until(expires); remaining > test.cachedFor || remaining < test.cachedFor-time.Minute/2 {
+				t.Errorf("cached for %v, want %v", remaining, test.cachedFor)
+			}
+		})
+	}
+}
+
+func TestNIP05LookupRelays(t *testing.T) {
+	var calls atomic.Int32
+	pointer, err := testVerifier(&calls).Lookup(context.Background(), "bob@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pointer.Relays) != 1 || pointer.Relays[0] != "wss://bob.relay" {
+		t.Fatalf("relays = %v", pointer.Relays)
+	}
+}
+
+func TestNIP05Cached(t *testing.T) {
+	var calls atomic.Int32
+	verifier := testVerifier(&calls)
+
+	if _, found := verifier.Cached("bob@example.com"); found {
+		t.Fatal("found an identifier that was never looked up")
+	}
+	verifier.Lookup(context.Background(), "bob@example.com")
+	verifier.Lookup(context.Background(), "alice@example.com")
+
+	if pointer, found := verifier.Cached("Bob@Example.com"); !found || pointer.PublicKey != bobKey {
+		t.Fatalf("cached answer = %v, %v", pointer, found)
+	}
+	if _, found := verifier.Cached("alice@example.com"); found {
+		t.Fatal("a failed lookup was returned as found")
+	}
+	if calls.Load() != 2 {
+		t.Fatalf("%d requests, Cached must not fetch", calls.Load())
+	}
+}
+
+func TestNIP05ClientRefusesPrivateAddresses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"names":{}}`))
+	}))
+	defer server.Close()
+
+	res, err := DefaultNIP05Verifier.Client.Get(server.URL + "/.well-known/nostr.json")
+	if err == nil {
+		res.Body.Close()
+		t.Fatal("the NIP-05 client connected to a loopback address")
+	}
+	if !strings.Contains(err.Error(), "non-public address") {
+		t.Fatalf("error = %v, want a refused non-public address", err)
+	}
+}
+
+func TestNIP05CacheSize(t *testing.T) {
+	var calls atomic.Int32
+	verifier := testVerifier(&calls)
+	verifier.MaxEntries = 3
+
+	for i := 0; i < 10; i++ {
+		verifier.Lookup(context.Background(), fmt.Sprintf("user%d@example.com", i))
+	}
+	if len(verifier.cache) != 3 {
+		t.Fatalf("cache holds %d entries, want 3", len(verifier.cache))
+	}
+	if _, found := verifier.cache["user9@example.com"]; !found {
+		t.Fatal("the newest lookup was not cached")
+	}
+}
+
+func TestNIP05Verify(t *testing.T) {
+	var calls atomic.Int32
+	verifier := testVerifier(&calls)
+	tests := []struct {
+		identifier string
+		publicKey  string
+		valid      bool
+	}{
+		{"bob@example.com", bobKey, true},
+		{"bob@example.com", strings.Repeat("0", 64), false},
+		{"bob@offline.example", bobKey, false},
+		{"not an identifier", bobKey, false},
+	}
+	for _, test := range tests {
+		if got := verifier.Verify(context.Background(), test.identifier, test.publicKey); got != test.valid {
+			t.Errorf("Verify(%s, %s) = %v, want %v", test.identifier, test.publicKey, got, test.valid)
+		}
+	}
+}
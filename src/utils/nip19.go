@@ -0,0 +1,117 @@
+package utils
+
+import (
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+// maxNAddrRelayHints keeps shared naddr links short enough to paste anywhere
+const maxNAddrRelayHints = 3
+
+// DecodePubKey turns a hex public key, npub or nprofile into a hex public key plus any relay hints
+func DecodePubKey(input string) (string, []string, error) {
+	input = strings.TrimPrefix(strings.TrimSpace(input), "nostr:")
+	if nostr.IsValidPublicKeyHex(strings.ToLower(input)) {
+		return strings.ToLower(input), nil, nil
+	}
+
+	prefix, value, err := nip19.Decode(input)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid public key %q: %w", input, err)
+	}
+	switch prefix {
+	case "npub":
+		return value.(string), nil, nil
+	case "nprofile":
+		pointer := value.(nostr.ProfilePointer)
+		return pointer.PublicKey, pointer.Relays, nil
+	}
+	return "", nil, fmt.Errorf("expected an npub or nprofile, got %s", prefix)
+}
+
+// DecodeEventID turns a hex event id, note or nevent into a hex event id plus any relay hints
+func DecodeEventID(input string) (string, []string, error) {
+	input = strings.TrimPrefix(strings.TrimSpace(input), "nostr:")
+	if nostr.IsValid32ByteHex(strings.ToLower(input)) {
+		return strings.ToLower(input), nil, nil
+	}
+
+	prefix, value, err := nip19.Decode(input)
+	if err != nil {
+		return "", nil, fmt.Errorf("invalid event id %q: %w", input, err)
+	}
+	switch prefix {
+	case "note":
+		return value.(string), nil, nil
+	case "nevent":
+		pointer := value.(nostr.EventPointer)
+		return pointer.ID, pointer.Relays, nil
+	}
+	return "", nil, fmt.Errorf("expected a note or nevent, got %s", prefix)
+}
+
+// DecodeBadgeAddress turns an naddr or a "30009:<pubkey>:<d>" a tag into the definition's issuer,
+// d tag and relay hints
+func DecodeBadgeAddress(input string) (string, string, []string, error) {
+	input = strings.TrimPrefix(strings.TrimSpace(input), "nostr:")
+
+	if strings.HasPrefix(input, "naddr1") {
+		prefix, value, err := nip19.Decode(input)
+		if err != nil || prefix != "naddr" {
+			return "", "", nil, fmt.Errorf("invalid badge address %q", input)
+		}
+		pointer := value.(nostr.EntityPointer)
+		if pointer.Kind != 30009 {
+			return "", "", nil, fmt.Errorf("address %q is kind %d, not a badge definition", input, pointer.Kind)
+		}
+		return pointer.PublicKey, pointer.Identifier, pointer.Relays, nil
+	}
+
+	coordinate, err := ParseATag(input)
+	if err != nil {
+		return "", "", nil, err
+	}
+	return coordinate.PubKey, coordinate.DTag, nil, nil
+}
+
+// EncodeNPub returns the npub for a hex public key, or the input unchanged if it can't be encoded
+func EncodeNPub(publicKey string) string {
+	npub, err := nip19.EncodePublicKey(publicKey)
+	if err != nil {
+		return publicKey
+	}
+	return npub
+}
+
+// ShortNPub abbreviates an npub for display, e.g. npub1abcd…wxyz
+func ShortNPub(publicKey string) string {
+	npub := EncodeNPub(publicKey)
+	if len(npub) <= 20 {
+		return npub
+	}
+	return npub[:10] + "…" + npub[len(npub)-6:]
+}
+
+// BadgeNAddr encodes a badge definition as an naddr carrying a few of the issuer's write relays as hints
+func BadgeNAddr(publicKey, dTag string, relays RelayList) string {
+	hints := relays.WriteRelays()
+	if len(hints) > maxNAddrRelayHints {
+		hints = hints[:maxNAddrRelayHints]
+	}
+
+	naddr, err := nip19.EncodeEntity(publicKey, 30009, dTag, hints)
+	if err != nil {
+		return ""
+	}
+	return naddr
+}
+
+// TemplateFuncs are available to every page and component template
+var TemplateFuncs = template.FuncMap{
+	"npub":      EncodeNPub,
+	"shortNPub": ShortNPub,
+}
@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"badger/src/relay"
+	"badger/src/types"
+)
+
+// MaxHintRelays caps how many relay hints of a link a public page dials
+const MaxHintRelays = 3
+
+// MaxUserRelays caps how many relays of one user's relay list a public page dials
+const MaxUserRelays = 8
+
+// ReadRelays are the user's inbox: where they read, so where others send them awards. Falls back to the
+// bootstrap relays when the user has no relay list.
+func (r RelayList) ReadRelays() []string {
+	return withBootstrapRelays(append(append([]string{}, r.Read...), r.Both...))
+}
+
+// WriteRelays are the user's outbox: where they publish, so where others look for their events. Falls
+// back to the bootstrap relays when the user has no relay list.
+func (r RelayList) WriteRelays() []string {
+	return withBootstrapRelays(append(append([]string{}, r.Write...), r.Both...))
+}
+
+// AllRelays merges every relay of the list
+func (r RelayList) AllRelays() []string {
+	return withBootstrapRelays(append(append(append([]string{}, r.Read...), r.Write...), r.Both...))
+}
+
+// WithFallbackRelays returns the relays, or the fallback relays when there are none to ask
+func WithFallbackRelays(relays []string) []string {
+	relays = relay.Unique(relays)
+	if len(relays) == 0 {
+		return append([]string{}, AppConfig.FallbackRelays...)
+	}
+	return relays
+}
+
+func withBootstrapRelays(relays []string) []string {
+	relays = relay.Unique(relays)
+	if len(relays) == 0 {
+		return append([]string{}, AppConfig.BootstrapRelays...)
+	}
+	return relays
+}
+
+// FetchRelayLists looks up the NIP-65 relay lists of many users on the bootstrap relays with a single
+// REQ. Users without a relay list are missing from the result.
+func FetchRelayLists(publicKeys []string) map[string]RelayList {
+	relayLists := make(map[string]RelayList)
+	if len(publicKeys) == 0 {
+		return relayLists
+	}
+
+	filter := types.SubscriptionFilter{
+		Authors: publicKeys,
+		Kinds:   []int{10002}, // Kind 10002 corresponds to relay list (NIP-65)
+	}
+
+	events, err := queryRelays("relaylists", AppConfig.BootstrapRelays, filter)
+	if err != nil {
+		Warnf("Failed to fetch relay lists: %v", err)
+		return relayLists
+	}
+
+	// Relay lists are replaceable, keep the newest event of each author
+	newest := make(map[string]types.NostrEvent)
+	for _, event := range events {
+		if existing, found := newest[event.PubKey]; !found || event.CreatedAt > existing.CreatedAt {
+			newest[event.PubKey] = event
+		}
+	}
+	for publicKey, event := range newest {
+		relayLists[publicKey] = parseRelayList(event)
+	}
+	return relayLists
+}
+
+// OutboxRelays returns the write relays of every user, for reading what they published
+func OutboxRelays(publicKeys []string) []string {
+	var relays []string
+	for _, relayList := range FetchRelayLists(publicKeys) {
+		relays = append(relays, relayList.Write...)
+		relays = append(relays, relayList.Both...)
+	}
+	return relay.Unique(relays)
+}
+
+// MaxRecipientRelays caps how many read relays of each recipient an award is sent to
+const MaxRecipientRelays = 4
+
+// MaxAwardRelays caps how many recipient relays one award is sent to in total
+const MaxAwardRelays = 50
+
+// AwardRelays returns where a kind 8 award goes: the issuer's write relays, so the award can be found
+// with the issuer's badges, and a few public read relays of every recipient, so recipients see it.
+// Recipients choose their relays, release them with ReleaseRelays after publishing.
+func AwardRelays(issuer RelayList, recipients []string) []string {
+	var inboxes []string
+	for _, relayList := range FetchRelayLists(recipients) {
+		read := append(append([]string{}, relayList.Read...), relayList.Both...)
+		inboxes = append(inboxes, relay.PublicURLs(read, MaxRecipientRelays)...)
+	}
+	inboxes = relay.Unique(inboxes)
+	if len(inboxes) > MaxAwardRelays {
+		inboxes = inboxes[:MaxAwardRelays]
+	}
+	return relay.Unique(append(issuer.WriteRelays(), inboxes...))
+}
+
+// PublicPageRelays is where a page anyone can open looks for a user's events: a few public relay hints
+// of the link and a few public relays of the user's outbox, or the fallback relays when there are none.
+// The requester picks them, so release them with ReleaseRelays once the page is done.
+func PublicPageRelays(hints []string, publicKey string) []string {
+	relays := relay.PublicURLs(hints, MaxHintRelays)
+	relays = append(relays, relay.PublicURLs(OutboxRelays([]string{publicKey}), MaxUserRelays)...)
+	return WithFallbackRelays(relays)
+}
+
+// PublicInboxRelays is where a page anyone can open looks for what was sent to a user: a few public
+// relays of the user's inbox, or the fallback relays when there are none. Release them with ReleaseRelays.
+func PublicInboxRelays(publicKey string) []string {
+	var relays []string
+	for _, relayList := range FetchRelayLists([]string{publicKey}) {
+		relays = append(relays, relayList.Read...)
+		relays = append(relays, relayList.Both...)
+	}
+	return WithFallbackRelays(relay.PublicURLs(relays, MaxUserRelays))
+}
+
+// ReleaseRelays closes the idle connections to relays a request chose, the relays from the config stay
+// open for the next one
+func ReleaseRelays(relays []string) {
+	relay.DefaultPool.Release(relays...)
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestWithFallbackRelays(t *testing.T) {
+	saved := AppConfig.FallbackRelays
+	defer func() { AppConfig.FallbackRelays = saved }()
+	AppConfig.FallbackRelays = []string{"wss://fallback.example"}
+
+	tests := []struct {
+		name   string
+		relays []string
+		want   []string
+	}{
+		{"own relays", []string{"wss://a.example", "wss://a.example/", "wss://b.example"}, []string{"wss://a.example", "wss://b.example"}},
+		{"none", nil, []string{"wss://fallback.example"}},
+		{"only invalid", []string{""}, []string{"wss://fallback.example"}},
+	}
+	for _, test := range tests {
+		if got := WithFallbackRelays(test.relays); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: WithFallbackRelays(%v) = %v, want %v", test.name, test.relays, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package utils
+
+import (
+	"context"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip05"
+)
+
+// recipientWorkers caps how many recipients are resolved at once, NIP-05 lookups hit the network
+const recipientWorkers = 8
+
+// ParseRecipients reads a CSV or newline separated list of npubs, nprofiles, hex public keys or NIP-05
+// identifiers (optionally followed by a relay hint) and returns the deduplicated recipients along with
+// every entry that could not be resolved. A first row that holds neither, such as "pubkey,relay", is
+// taken as a header and skipped. Malformed CSV is an error.
+func ParseRecipients(input string, defaultRelay string) ([]AwardRecipient, []string, error) {
+	reader := csv.NewReader(strings.NewReader(input))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	reader.LazyQuotes = true
+
+	type entry struct {
+		identifier string
+		relayHint  string
+	}
+	var entries []entry
+	header := true
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read recipients: %v", err)
+		}
+
+		// Newline lists may use whitespace instead of commas between the key and relay hint
+		if len(record) == 1 {
+			record = strings.Fields(record[0])
+		}
+		if len(record) == 0 || strings.HasPrefix(record[0], "#") {
+			continue
+		}
+
+		e := entry{identifier: strings.TrimSpace(record[0]), relayHint: defaultRelay}
+		if header {
+			header = false
+			if _, _, err := DecodePubKey(e.identifier); err != nil && !nip05.IsValidIdentifier(e.identifier) {
+				continue
+			}
+		}
+		if len(record) > 1 && nostr.IsValidRelayURL(strings.TrimSpace(record[1])) {
+			e.relayHint = strings.TrimSpace(record[1])
+		}
+		if e.identifier != "" {
+			entries = append(entries, e)
+		}
+	}
+
+	// Resolve the entries with a few workers
+	resolved := make([]string, len(entries))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for worker := 0; worker < recipientWorkers && worker < len(entries); worker++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				resolved[i] = ResolvePubKey(entries[i].identifier)
+			}
+		}()
+	}
+	for i := range entries {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var recipients []AwardRecipient
+	var invalid []string
+	seen := make(map[string]bool)
+	for i, e := range entries {
+		pubKey := resolved[i]
+		if pubKey == "" {
+			invalid = append(invalid, e.identifier)
+			continue
+		}
+		if seen[pubKey] {
+			continue
+		}
+		seen[pubKey] = true
+		recipients = append(recipients, AwardRecipient{PubKey: pubKey, RelayHint: e.relayHint})
+	}
+
+	return recipients, invalid, nil
+}
+
+// ResolvePubKey turns an npub, nprofile, hex public key or NIP-05 identifier into a hex public key,
+// returning an empty string if it cannot be resolved
+func ResolvePubKey(identifier string) string {
+	if publicKey, _, err := DecodePubKey(identifier); err == nil {
+		return publicKey
+	}
+
+	if !nip05.IsValidIdentifier(identifier) {
+		return ""
+	}
+	pointer, err := DefaultNIP05Verifier.Lookup(context.Background(), identifier)
+	if err != nil {
+		return ""
+	}
+	return pointer.PublicKey
+}
+
+// ResolveCachedPubKey is ResolvePubKey for requests anyone can make: NIP-05 identifiers are only
+// resolved from earlier lookups, never fetched
+func ResolveCachedPubKey(identifier string) string {
+	if publicKey, _, err := DecodePubKey(identifier); err == nil {
+		return publicKey
+	}
+
+	pointer, found := DefaultNIP05Verifier.Cached(identifier)
+	if !found {
+		return ""
+	}
+	return pointer.PublicKey
+}
+
+// BatchRecipients splits recipients into groups of at most size entries, one group per award event
+func BatchRecipients(recipients []AwardRecipient, size int) [][]AwardRecipient {
+	if size <= 0 {
+		size = len(recipients)
+	}
+
+	var batches [][]AwardRecipient
+	for start := 0; start < len(recipients); start += size {
+		end := start + size
+		if end > len(recipients) {
+			end = len(recipients)
+		}
+		batches = append(batches, recipients[start:end])
+	}
+	return batches
+}
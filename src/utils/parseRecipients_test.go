@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/nbd-wtf/go-nostr"
+	"github.com/nbd-wtf/go-nostr/nip19"
+)
+
+func TestParseRecipients(t *testing.T) {
+	alice, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	bob, _ := nostr.GetPublicKey(nostr.GeneratePrivateKey())
+	aliceNPub, _ := nip19.EncodePublicKey(alice)
+
+	tests := []struct {
+		name       string
+		input      string
+		recipients []AwardRecipient
+		invalid    []string
+	}{
+		{
+			name:       "newline list",
+			input:      aliceNPub + "\n" + bob,
+			recipients: []AwardRecipient{{PubKey: alice, RelayHint: "wss://default"}, {PubKey: bob, RelayHint: "wss://default"}},
+		},
+		{
+			name:       "csv with relay hints",
+			input:      aliceNPub + ",wss://alice.relay\n" + bob + ", not a relay",
+			recipients: []AwardRecipient{{PubKey: alice, RelayHint: "wss://alice.relay"}, {PubKey: bob, RelayHint: "wss://default"}},
+		},
+		{
+			name:       "whitespace separated hint",
+			input:      bob + "  wss://bob.relay",
+			recipients: []AwardRecipient{{PubKey: bob, RelayHint: "wss://bob.relay"}},
+		},
+		{
+			name:       "duplicates and comments",
+			input:      "# attendees\n" + alice + "\n\n" + aliceNPub,
+			recipients: []AwardRecipient{{PubKey: alice, RelayHint: "wss://default"}},
+		},
+		{
+			name:       "csv header row",
+			input:      "pubkey,relay\n" + aliceNPub + ",wss://alice.relay\n" + bob,
+			recipients: []AwardRecipient{{PubKey: alice, RelayHint: "wss://alice.relay"}, {PubKey: bob, RelayHint: "wss://default"}},
+		},
+		{
+			name:       "header after a comment",
+			input:      "# attendees\nnpub relay\n" + bob,
+			recipients: []AwardRecipient{{PubKey: bob, RelayHint: "wss://default"}},
+		},
+		{
+			name:       "only the first row is a header",
+			input:      bob + "\npubkey,relay",
+			recipients: []AwardRecipient{{PubKey: bob, RelayHint: "wss://default"}},
+			invalid:    []string{"pubkey"},
+		},
+		{
+			name:       "invalid entries",
+			input:      alice + "\nnot-a-key\nnpub1invalid",
+			recipients: []AwardRecipient{{PubKey: alice, RelayHint: "wss://default"}},
+			invalid:    []string{"not-a-key", "npub1invalid"},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			recipients, invalid, err := ParseRecipients(test.input, "wss://default")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(recipients, test.recipients) {
+				t.Errorf("recipients = %+v, want %+v", recipients, test.recipients)
+			}
+			if !reflect.DeepEqual(invalid, test.invalid) {
+				t.Errorf("invalid = %v, want %v", invalid, test.invalid)
+			}
+		})
+	}
+}
+
+func TestBatchRecipients(t *testing.T) {
+	recipients := make([]AwardRecipient, 5)
+	tests := []struct {
+		size  int
+		sizes []int
+	}{
+		{2, []int{2, 2, 1}},
+		{5, []int{5}},
+		{10, []int{5}},
+		{0, []int{5}},
+	}
+	for _, test := range tests {
+		var sizes []int
+		for _, batch := range BatchRecipients(recipients, test.size) {
+			sizes = append(sizes, len(batch))
+		}
+		if !reflect.DeepEqual(sizes, test.sizes) {
+			t.Errorf("BatchRecipients(5, %d) = %v, want %v", test.size, sizes, test.sizes)
+		}
+	}
+}
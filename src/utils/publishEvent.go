@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"badger/src/relay"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// Publish outcomes reported for each relay
+const (
+	PublishAccepted = "accepted"
+	PublishRejected = "rejected"
+	PublishTimeout  = "timeout"
+	PublishFailed   = "failed"
+)
+
+// RelayResult is the outcome of publishing an event to a single relay
+type RelayResult struct {
+	Relay    string `json:"relay"`
+	Status   string `json:"status"`
+	Accepted bool   `json:"accepted"`
+	Message  string `json:"message,omitempty"`
+}
+
+// PublishEvent sends the signed event to every relay concurrently and waits, bounded by the pool's
+// publish timeout, for each relay to accept or reject it
+func PublishEvent(event nostr.Event, relays []string) []RelayResult {
+	relays = relay.Unique(relays)
+	results := make([]RelayResult, len(relays))
+
+	var wg sync.WaitGroup
+	for i, relayURL := range relays {
+		wg.Add(1)
+		go func(i int, relayURL string) {
+			defer wg.Done()
+			result, err := relay.DefaultPool.Publish(context.Background(), relayURL, event)
+			switch {
+			case errors.Is(err, context.DeadlineExceeded):
+				results[i] = RelayResult{Relay: relayURL, Status: PublishTimeout, Message: "no response from relay"}
+			case err != nil:
+				results[i] = RelayResult{Relay: relayURL, Status: PublishFailed, Message: err.Error()}
+			case result.Accepted:
+				results[i] = RelayResult{Relay: relayURL, Status: PublishAccepted, Accepted: true, Message: result.Message}
+			default:
+				results[i] = RelayResult{Relay: relayURL, Status: PublishRejected, Message: result.Message}
+			}
+		}(i, relayURL)
+	}
+	wg.Wait()
+
+	return results
+}
+
+// CountAccepted returns how many relays accepted the event
+func CountAccepted(results []RelayResult) int {
+	accepted := 0
+	for _, result := range results {
+		if result.Accepted {
+			accepted++
+		}
+	}
+	return accepted
+}
@@ -0,0 +1,48 @@
+package utils
+
+import (
+	"fmt"
+	"strings"
+
+	qrcode "github.com/skip2/go-qrcode"
+)
+
+// QR code sizes in pixels, the SVG scales freely and only uses the size for its width and height
+const (
+	DefaultQRSize = 256
+	MinQRSize     = 64
+	MaxQRSize     = 1024
+)
+
+// QRCodePNG renders content as a size x size PNG
+func QRCodePNG(content string, size int) ([]byte, error) {
+	code, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %v", err)
+	}
+	return code.PNG(size)
+}
+
+// QRCodeSVG renders content as an SVG drawing one path of square modules, so it prints sharply at any size
+func QRCodeSVG(content string, size int) ([]byte, error) {
+	code, err := qrcode.New(content, qrcode.Medium)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode QR code: %v", err)
+	}
+	bitmap := code.Bitmap() // Includes the quiet zone around the code
+
+	var path strings.Builder
+	for y, row := range bitmap {
+		for x, dark := range row {
+			if dark {
+				fmt.Fprintf(&path, "M%d %dh1v1h-1z", x, y)
+			}
+		}
+	}
+
+	modules := len(bitmap)
+	svg := fmt.Sprintf(`<svg xmlns="http://www.w3.org/2000/svg" width="%d" height="%d" viewBox="0 0 %d %d" shape-rendering="crispEdges">`+
+		`<rect width="%d" height="%d" fill="#fff"/><path fill="#000" d="%s"/></svg>`,
+		size, size, modules, modules, modules, modules, path.String())
+	return []byte(svg), nil
+}
@@ -0,0 +1,59 @@
+package utils
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"strings"
+
+	"badger/src/relay"
+	"badger/src/store"
+	"badger/src/types"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// queryRelays fetches events through the local store when it is enabled, so repeated loads are served
+// from disk and only events newer than the last sync are requested from relays. owner prefixes the
+// sync key (usually the logged in pubkey) so a user's refresh can invalidate just their queries.
+// Every event returned is correctly signed, so callers can trust its pubkey: the pool checks events as
+// they arrive and the store checks them once when they are saved.
+func queryRelays(owner string, relays []string, filter types.SubscriptionFilter) ([]types.NostrEvent, error) {
+	fetch := func(f types.SubscriptionFilter) ([]types.NostrEvent, error) {
+		return relay.DefaultPool.Query(context.Background(), relays, f)
+	}
+	if store.Default == nil {
+		return fetch(filter)
+	}
+
+	ttl := seconds(AppConfig.CacheTTL)
+	return store.Default.Sync(syncKey(owner, relays, filter), ttl, filter, fetch)
+}
+
+// syncKey identifies a query by its owner, filter and relay set
+func syncKey(owner string, relays []string, filter types.SubscriptionFilter) string {
+	filterJSON, _ := json.Marshal(filter)
+	hash := sha256.Sum256([]byte(string(filterJSON) + strings.Join(relay.Unique(relays), ",")))
+	return owner + ":" + hex.EncodeToString(hash[:8])
+}
+
+// InvalidateCache makes the next load of the user's badges go back to relays for everything
+func InvalidateCache(publicKey string) {
+	if store.Default == nil {
+		return
+	}
+	store.Default.Invalidate(publicKey + ":")
+}
+
+// StoreEvent records an event Badger published itself so it shows up without waiting for a refresh
+func StoreEvent(event nostr.Event) {
+	if store.Default == nil {
+		return
+	}
+
+	err := store.Default.Save(types.FromNostrEvent(event))
+	if err != nil {
+		Warnf("Failed to store published event %s: %v", event.ID, err)
+	}
+}
@@ -0,0 +1,144 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"badger/src/types"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// BadgeCoordinate identifies a badge definition by the parts of an "a" tag: "30009:pubkey:dtag"
+type BadgeCoordinate struct {
+	Kind   int
+	PubKey string
+	DTag   string
+}
+
+func (c BadgeCoordinate) String() string {
+	return fmt.Sprintf("%d:%s:%s", c.Kind, c.PubKey, c.DTag)
+}
+
+// ParseATag splits an "a" tag value into its coordinate, only accepting badge definitions
+func ParseATag(value string) (BadgeCoordinate, error) {
+	parts := strings.SplitN(value, ":", 3)
+	if len(parts) != 3 {
+		return BadgeCoordinate{}, fmt.Errorf("invalid badge coordinate %q", value)
+	}
+	kind, err := strconv.Atoi(parts[0])
+	if err != nil || kind != 30009 {
+		return BadgeCoordinate{}, fmt.Errorf("coordinate %q is not a badge definition", value)
+	}
+	if !nostr.IsValidPublicKeyHex(parts[1]) || parts[2] == "" {
+		return BadgeCoordinate{}, fmt.Errorf("invalid badge coordinate %q", value)
+	}
+	return BadgeCoordinate{Kind: kind, PubKey: parts[1], DTag: parts[2]}, nil
+}
+
+// ResolveBadgeDefinitions finds the newest definition of each coordinate, keyed by coordinate string. It
+// asks the hint relays and the issuers' NIP-65 write relays for every coordinate, keeping the newest
+// version so a stale copy on a hint relay can't hide an update, and only asks the fallback relays for
+// the definitions the issuers' relays didn't have.
+func ResolveBadgeDefinitions(coordinates []BadgeCoordinate, hintRelays, fallbackRelays []string) map[string]types.BadgeDefinition {
+	definitions := make(map[string]types.BadgeDefinition)
+
+	coordinates = uniqueCoordinates(coordinates)
+	if len(coordinates) == 0 {
+		return definitions
+	}
+
+	// 1. Relays named in the award or profile badges tags
+	if len(hintRelays) > 0 {
+		queryBadgeDefinitions(coordinates, hintRelays, definitions)
+	}
+
+	// 2. Where each issuer says they publish, the authoritative copy
+	notOnOutbox := queryBadgeDefinitions(coordinates, issuerWriteRelays(coordinates), definitions)
+
+	// 3. The relays of the user looking at the badges
+	if len(notOnOutbox) > 0 {
+		queryBadgeDefinitions(notOnOutbox, fallbackRelays, definitions)
+	}
+
+	for _, coordinate := range coordinates {
+		if _, found := definitions[coordinate.String()]; !found {
+			Debugf("Badge definition %s not found", coordinate)
+		}
+	}
+	return definitions
+}
+
+func uniqueCoordinates(coordinates []BadgeCoordinate) []BadgeCoordinate {
+	var unique []BadgeCoordinate
+	seen := make(map[BadgeCoordinate]bool)
+	for _, coordinate := range coordinates {
+		if seen[coordinate] {
+			continue
+		}
+		seen[coordinate] = true
+		unique = append(unique, coordinate)
+	}
+	return unique
+}
+
+// queryBadgeDefinitions requests every coordinate in a single REQ, adds the exact matches newer than
+// what definitions already holds and returns the coordinates these relays didn't have
+func queryBadgeDefinitions(coordinates []BadgeCoordinate, relays []string, definitions map[string]types.BadgeDefinition) []BadgeCoordinate {
+	if len(relays) == 0 {
+		return coordinates
+	}
+
+	wanted := make(map[string]bool)
+	var authors, dTags []string
+	for _, coordinate := range coordinates {
+		wanted[coordinate.String()] = true
+		authors = append(authors, coordinate.PubKey)
+		dTags = append(dTags, coordinate.DTag)
+	}
+
+	filter := types.SubscriptionFilter{
+		Kinds:   []int{30009},                    // Badge definition event
+		Authors: authors,                         // Only the issuers may define their badges
+		Tags:    map[string][]string{"d": dTags}, // The dtags of the badges
+	}
+
+	events, err := queryRelays("definitions", relays, filter)
+	if err != nil {
+		Warnf("Failed to fetch badge definitions: %v", err)
+	}
+
+	// An author/d combination from the filter isn't necessarily a wanted pair, so match the full coordinate
+	found := make(map[string]bool)
+	for _, event := range events {
+		badgeDef := ParseBadgeDefinition(event)
+		key := BadgeATag(badgeDef.PubKey, badgeDef.DTag)
+		if !wanted[key] {
+			continue
+		}
+		found[key] = true
+		// Keep the newest version of each replaceable definition
+		if existing, found := definitions[key]; found && existing.CreatedAt >= badgeDef.CreatedAt {
+			continue
+		}
+		definitions[key] = badgeDef
+	}
+
+	var missing []BadgeCoordinate
+	for _, coordinate := range coordinates {
+		if !found[coordinate.String()] {
+			missing = append(missing, coordinate)
+		}
+	}
+	return missing
+}
+
+// issuerWriteRelays returns the NIP-65 outbox relays of the coordinates' issuers
+func issuerWriteRelays(coordinates []BadgeCoordinate) []string {
+	var issuers []string
+	for _, coordinate := range coordinates {
+		issuers = append(issuers, coordinate.PubKey)
+	}
+	return OutboxRelays(issuers)
+}
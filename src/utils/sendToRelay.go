@@ -1,37 +1,24 @@
 package utils
 
 import (
+	"context"
 	"fmt"
-	"log"
 
-	"github.com/gorilla/websocket"
+	"badger/src/relay"
+
 	"github.com/nbd-wtf/go-nostr"
 )
 
 // SendToRelay sends the signed Nostr event to the specified WebSocket relay
 func SendToRelay(relayURL string, event nostr.Event) error {
-	// Open a WebSocket connection to the relay
-	conn, _, err := websocket.DefaultDialer.Dial(relayURL, nil)
-	if err != nil {
-		return fmt.Errorf("failed to connect to relay: %v", err)
-	}
-	defer conn.Close()
-
-
-	// Prepare the message as per Nostr protocol: ["EVENT", <event JSON>]
-	message := []interface{}{"EVENT", event}
-	err = conn.WriteJSON(message)
+	result, err := relay.DefaultPool.Publish(context.Background(), relayURL, event)
 	if err != nil {
 		return fmt.Errorf("failed to send event to relay: %v", err)
 	}
+	Debugf("Response from relay %s: accepted=%t %s", relayURL, result.Accepted, result.Message)
 
-	// Optionally, wait for an acknowledgment from the relay (if required)
-	_, reply, err := conn.ReadMessage()
-	if err != nil {
-		log.Printf("Failed to read response from relay: %v", err)
-	} else {
-		log.Printf("Response from relay: %s", reply)
+	if !result.Accepted {
+		return fmt.Errorf("relay rejected event: %s", result.Message)
 	}
-
 	return nil
 }
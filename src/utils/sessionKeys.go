@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// sessionSecretFile holds the generated secret, under data_dir, when none is configured
+const sessionSecretFile = "session_secret"
+
+// sameSiteModes maps cookie_same_site to the cookie attribute
+var sameSiteModes = map[string]http.SameSite{
+	"lax":    http.SameSiteLaxMode,
+	"strict": http.SameSiteStrictMode,
+	"none":   http.SameSiteNoneMode,
+}
+
+// validEncryptionKey accepts no key, or one of the AES-128, AES-192 or AES-256 key sizes
+func validEncryptionKey(key string) bool {
+	switch len(key) {
+	case 0, 16, 24, 32:
+		return true
+	}
+	return false
+}
+
+// SessionKeyPairs returns the signing and encryption key pairs for the cookie store, current key first
+// so new cookies use it while cookies made with previous_session_keys still decode
+func (c *Config) SessionKeyPairs() ([][]byte, error) {
+	secret := c.SessionSecret
+	if secret == "" {
+		var err error
+		if secret, err = loadSessionSecret(c.DataDir); err != nil {
+			return nil, err
+		}
+	}
+
+	pairs := [][]byte{[]byte(secret), encryptionKeyBytes(c.SessionEncryptionKey)}
+	for _, key := range c.PreviousSessionKeys {
+		pairs = append(pairs, []byte(key.Secret), encryptionKeyBytes(key.EncryptionKey))
+	}
+	return pairs, nil
+}
+
+// SameSite is the cookie attribute for cookie_same_site
+func (c *Config) SameSite() http.SameSite {
+	return sameSiteModes[c.CookieSameSite]
+}
+
+// encryptionKeyBytes leaves cookies unencrypted (nil) when no key is set
+func encryptionKeyBytes(key string) []byte {
+	if key == "" {
+		return nil
+	}
+	return []byte(key)
+}
+
+// loadSessionSecret reads the secret generated on an earlier run, or generates and saves a new one
+func loadSessionSecret(dataDir string) (string, error) {
+	path := filepath.Join(dataDir, sessionSecretFile)
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		secret := strings.TrimSpace(string(data))
+		if len(secret) < 32 {
+			return "", fmt.Errorf("session secret in %s is shorter than 32 characters", path)
+		}
+		return secret, nil
+	}
+	if !os.IsNotExist(err) {
+		return "", fmt.Errorf("failed to read session secret: %w", err)
+	}
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", fmt.Errorf("failed to generate session secret: %w", err)
+	}
+	secret := hex.EncodeToString(random)
+
+	if err := os.MkdirAll(dataDir, 0700); err != nil {
+		return "", fmt.Errorf("failed to create data directory: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(secret+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("failed to save session secret: %w", err)
+	}
+	Infof("Generated a new session secret in %s", path)
+	return secret, nil
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"badger/src/store"
 	"badger/src/types"
 	"html/template"
 	"net/http"
@@ -8,17 +9,52 @@ import (
 )
 
 type PageData struct {
-	Title            string
-	Theme            string
-	PublicKey        string
-	DisplayName      string
-	Picture          string
-	About            string
-	Relays           RelayList
-	AwardedBadges    []AwardedBadge
-	ProfileBadges    []ProfileBadgesEvent
-	BadgeDefinitions map[string]types.BadgeDefinition
-	CreatedBadges    []types.BadgeDefinition
+	Title              string
+	Theme              string
+	PublicKey          string
+	RemoteSigner       bool // Logged in with a NIP-46 bunker, events are signed on the server
+	DisplayName        string
+	Picture            string
+	About              string
+	Relays             RelayList
+	AwardedBadges      []AwardedBadge
+	ProfileBadges      []ProfileBadgesEvent
+	BadgeDefinitions   map[string]types.BadgeDefinition
+	CreatedBadges      []BadgeLink
+	Badge              types.BadgeDefinition
+	MaxAwardRecipients int
+	OpenGraph          *OpenGraph
+	Profile            types.UserMetadata
+	ProfileNPub        string
+	PublicBadges       []BadgeLink
+	Recipients         []string           // npubs of the people awarded a badge
+	Awarders           map[string]Awarder // Profiles of badge issuers by pubkey
+	Sessions           []store.Session    // Active logins shown to admins
+	CurrentSessionKey  string             // Store key of the session viewing the page
+	Issuers            []store.Issuer     // Organization issuers the user may act as, or all of them for admins
+	IssuerAudit        []store.IssuerAuditEntry
+	IssuersEnabled     bool                  // issuer_key_password is set
+	NAddr              string                // Address of the badge a page is about
+	BaseURL            string                // Where this instance is reached, for links shared outside it
+	ClaimCampaigns     []store.ClaimCampaign // The user's claim campaigns
+	ClaimCode          string
+	ClaimStatus        string // Why a claim code can't be redeemed, empty when it can
+}
+
+// OpenGraph holds the link preview meta tags of a public page
+type OpenGraph struct {
+	Title       string
+	Description string
+	Image       string
+	URL         string
+}
+
+// BadgeLink is a badge definition with the NIP-19 identifiers public pages link to
+type BadgeLink struct {
+	types.BadgeDefinition
+	NAddr      string
+	IssuerNPub string
+	IssuerName string // Set for badges of an organization issuer
 }
 
 // Define the base directories for views and templates
@@ -48,6 +84,7 @@ func RenderTemplate(w http.ResponseWriter, data PageData, view string, useLoginL
 		filepath.Join(viewsDir, "components", "awarded-badges.html"),
 		filepath.Join(viewsDir, "components", "profile-badges.html"),
 		filepath.Join(viewsDir, "components", "created-badges.html"),
+		filepath.Join(viewsDir, "components", "awarder.html"),
 	}
 
 	var templates []string
@@ -59,7 +96,7 @@ func RenderTemplate(w http.ResponseWriter, data PageData, view string, useLoginL
 	templates = append(templates, componentTemplates...)
 
 	// Parse all templates
-	tmpl, err := template.ParseFiles(templates...)
+	tmpl, err := template.New("").Funcs(TemplateFuncs).ParseFiles(templates...)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
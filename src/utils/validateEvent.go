@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/nbd-wtf/go-nostr"
+)
+
+// EventValidationError describes why a signed event was refused, along with the HTTP status to return
+type EventValidationError struct {
+	Status  int    `json:"-"`
+	Code    string `json:"error"`
+	Message string `json:"message"`
+}
+
+func (e *EventValidationError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Code, e.Message)
+}
+
+// requiredTags lists the tags that must be present for each badge related kind (NIP-58 / NIP-09)
+var requiredTags = map[int][]string{
+	5:     {"e"},
+	8:     {"a", "p"},
+	30008: {"d"},
+	30009: {"d", "name", "image"},
+}
+
+// ValidateSignedEvent checks a browser signed event before it is broadcast: the id must match the
+// serialized event, the Schnorr signature must verify, the author must be the logged in user, the kind
+// must be the one the endpoint expects and the kind's required tags must be present
+func ValidateSignedEvent(event nostr.Event, sessionPubKey string, expectedKind int) *EventValidationError {
+	if sessionPubKey == "" {
+		return &EventValidationError{http.StatusUnauthorized, "not_authenticated", "user not logged in"}
+	}
+	if event.Kind != expectedKind {
+		return &EventValidationError{http.StatusBadRequest, "wrong_kind", fmt.Sprintf("expected kind %d, got %d", expectedKind, event.Kind)}
+	}
+	if event.PubKey != sessionPubKey {
+		return &EventValidationError{http.StatusForbidden, "pubkey_mismatch", "event pubkey does not match the logged in user"}
+	}
+	if event.GetID() != event.ID {
+		return &EventValidationError{http.StatusBadRequest, "invalid_id", "event id does not match the event hash"}
+	}
+	if ok, err := event.CheckSignature(); err != nil || !ok {
+		return &EventValidationError{http.StatusBadRequest, "invalid_signature", "event signature could not be verified"}
+	}
+
+	for _, name := range requiredTags[expectedKind] {
+		tag := event.Tags.GetFirst([]string{name, ""})
+		if tag == nil || tag.Value() == "" {
+			return &EventValidationError{http.StatusUnprocessableEntity, "missing_tag", fmt.Sprintf("required %q tag is missing", name)}
+		}
+	}
+	if expectedKind == 8 && !strings.HasPrefix(event.Tags.GetFirst([]string{"a", ""}).Value(), BadgeATag(event.PubKey, "")) {
+		return &EventValidationError{http.StatusUnprocessableEntity, "invalid_tag", "awards may only reference the issuer's own badge definitions"}
+	}
+	if expectedKind == 30008 && event.Tags.GetD() != "profile_badges" {
+		return &EventValidationError{http.StatusUnprocessableEntity, "invalid_tag", `profile badges must use d tag "profile_badges"`}
+	}
+
+	return nil
+}
+
+// WriteValidationError sends a validation failure to the client as a structured JSON error
+func WriteValidationError(w http.ResponseWriter, err *EventValidationError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(err.Status)
+	json.NewEncoder(w).Encode(err)
+}
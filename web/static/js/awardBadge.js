@@ -0,0 +1,51 @@
+document.getElementById("award-badge-form").onsubmit = async function (event) {
+  event.preventDefault();
+
+  const status = document.getElementById("award-status");
+  status.textContent = "Preparing award events...";
+
+  try {
+    // Step 1: Fetch the unsigned award events (one per batch) from the backend
+    const response = await fetch("/award-badge", {
+      method: "POST",
+      body: new FormData(event.target),
+    });
+    if (!response.ok) {
+      throw new Error(await response.text());
+    }
+
+    const unsignedEvents = await response.json();
+
+    // Step 2: Sign every batch with the remote signer or the Nostr extension
+    const signedEvents = [];
+    for (const [i, unsignedEvent] of unsignedEvents.entries()) {
+      status.textContent = `Signing award event ${i + 1} of ${unsignedEvents.length}...`;
+      signedEvents.push(await signEvent(unsignedEvent));
+    }
+
+    // Step 3: Send the signed events to the backend for broadcasting
+    status.textContent = "Broadcasting award events...";
+    const result = await fetch("/award-signed-badges", {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+      },
+      body: JSON.stringify(signedEvents),
+    });
+    if (!result.ok) {
+      throw new Error(await result.text());
+    }
+
+    const batches = await result.json();
+    status.replaceChildren(
+      ...batches.map((batch) => {
+        const line = document.createElement("p");
+        line.textContent = `Batch ${batch.batch}: ${batch.recipients} recipients, accepted by ${batch.accepted} of ${batch.relays.length} relays`;
+        return line;
+      })
+    );
+  } catch (err) {
+    console.error("Failed to award badge:", err);
+    status.textContent = `Failed to award badge: ${err.message}`;
+  }
+};
@@ -5,8 +5,6 @@ document.getElementById("badge-form").onsubmit = async function (event) {
   const uniqueName = document.getElementById("unique-name").value;
   const badgeName = document.getElementById("badge-name").value;
   const badgeDescription = document.getElementById("badge-description").value;
-  const badgeImage = document.getElementById("badge-image").value;
-  const badgeThumb = document.getElementById("badge-thumb").value;
 
   const badgeEvent = {
     kind: 30009, // Badge Definition kind
@@ -14,35 +12,44 @@ document.getElementById("badge-form").onsubmit = async function (event) {
       ["d", uniqueName],
       ["name", badgeName],
       ["description", badgeDescription],
-      ["image", badgeImage, "1024x1024"],
-      ["thumb", badgeThumb, "256x256"],
+      ...(await badgeImageTags()), // image and thumb tags with real dimensions
     ],
     created_at: Math.floor(Date.now() / 1000),
     content: "",
   };
 
-  if (window.nostr) {
-    console.log("Nostr extension is available.");
+  // Badges issued as an organization are signed by the server with its key
+  const issuer = document.getElementById("badge-issuer");
+  if (issuer && issuer.value) {
+    badgeEvent.pubkey = issuer.value;
+  }
 
-    try {
-      const signedEvent = await window.nostr.signEvent(badgeEvent);
-      console.log("Signed Event:", signedEvent);
+  try {
+    const signedEvent = await signEvent(badgeEvent);
 
-      // Send signed event to Go backend
-      fetch("/create-badge", {
-        method: "POST",
-        headers: {
-          "Content-Type": "application/json",
-        },
-        body: JSON.stringify(signedEvent),
+    // Send signed event to Go backend and show how each relay answered
+    const results = document.getElementById("publish-results");
+    results.textContent = "Broadcasting badge...";
+    fetch("/create-badge", {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+        Accept: "text/html",
+      },
+      body: JSON.stringify(signedEvent),
+    })
+      .then(async (response) => {
+        if (!response.ok) {
+          throw new Error(await response.text());
+        }
+        results.outerHTML = await response.text();
       })
-        .then((response) => response.json())
-        .then((data) => console.log("Badge sent:", data))
-        .catch((error) => console.error("Error:", error));
-    } catch (err) {
-      console.error("Failed to sign event:", err);
-    }
-  } else {
-    alert("Nostr extension not available.");
+      .catch((error) => {
+        console.error("Error:", error);
+        results.textContent = `Failed to broadcast badge: ${error.message}`;
+      });
+  } catch (err) {
+    console.error("Failed to sign event:", err);
+    alert(`Failed to sign the badge: ${err.message}`);
   }
 };
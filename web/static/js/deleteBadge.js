@@ -1,7 +1,12 @@
-async function deleteBadge(badgeID) {
+// issuer is the organization issuer that published the badge, empty for the user's own badges
+async function deleteBadge(badgeID, issuer = "") {
   try {
     // Step 1: Fetch the unsigned deletion event from the backend
-    const response = await fetch(`/delete-badge?badge_id=${badgeID}`);
+    const params = new URLSearchParams({ badge_id: badgeID });
+    if (issuer) {
+      params.set("issuer", issuer);
+    }
+    const response = await fetch(`/delete-badge?${params}`);
     if (!response.ok) {
       throw new Error(`Failed to fetch unsigned event: ${response.statusText}`);
     }
@@ -9,18 +14,12 @@ async function deleteBadge(badgeID) {
     const unsignedEvent = await response.json();
     console.log("Unsigned Deletion Event:", unsignedEvent); // Log the unsigned event
 
-    // Step 2: Ensure the Nostr extension is available
-    if (!window.nostr) {
-      alert("Nostr extension not available.");
-      return;
-    }
-
-    // Step 3: Sign the event using the Nostr extension
+    // Step 2: Sign the event with the remote signer or the Nostr extension
     try {
-      const signedEvent = await window.nostr.signEvent(unsignedEvent);
+      const signedEvent = await signEvent(unsignedEvent);
       console.log("Signed Deletion Event:", signedEvent);
 
-      // Step 4: Send the signed event to the backend for broadcasting
+      // Step 3: Send the signed event to the backend for broadcasting
       const result = await fetch("/delete-signed-badge", {
         method: "POST",
         headers: {
@@ -36,7 +35,13 @@ async function deleteBadge(badgeID) {
 
       const data = await result.json();
       console.log("Deletion event broadcasted:", data);
-      alert("Badge deleted successfully.");
+      if (data.accepted === 0) {
+        alert("No relay accepted the deletion event.");
+      } else {
+        alert(
+          `Badge deleted, accepted by ${data.accepted} of ${data.relays.length} relays.`
+        );
+      }
     } catch (err) {
       console.error("Failed to sign deletion event:", err);
       alert(`Failed to sign the deletion event: ${err.message}`);
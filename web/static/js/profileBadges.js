@@ -0,0 +1,122 @@
+// Profile badges editor: the order of cards in #profile-badge-list becomes the kind 30008 event
+
+function markProfileUnsaved() {
+  const notice = document.getElementById("profile-unsaved");
+  if (notice) {
+    notice.classList.remove("hidden");
+  }
+  const empty = document.getElementById("profile-empty");
+  if (empty) {
+    empty.remove();
+  }
+  updateProfileControls();
+}
+
+// Show reorder/remove buttons on profile cards and accept/hide buttons on available cards
+function updateProfileControls() {
+  document.querySelectorAll("#profile-badges .badge-card").forEach((card) => {
+    const inProfile = card.parentElement.id === "profile-badge-list";
+    card
+      .querySelector(".profile-controls")
+      .classList.toggle("hidden", !inProfile);
+    card
+      .querySelector(".available-controls")
+      .classList.toggle("hidden", inProfile);
+  });
+}
+
+function moveProfileBadge(button, direction) {
+  const card = button.closest(".badge-card");
+  if (direction < 0 && card.previousElementSibling) {
+    card.parentElement.insertBefore(card, card.previousElementSibling);
+  } else if (direction > 0 && card.nextElementSibling) {
+    card.parentElement.insertBefore(card.nextElementSibling, card);
+  }
+  markProfileUnsaved();
+}
+
+function removeProfileBadge(button) {
+  const card = button.closest(".badge-card");
+  document.getElementById("available-badge-list").prepend(card);
+  markProfileUnsaved();
+}
+
+function acceptProfileBadge(button) {
+  const card = button.closest(".badge-card");
+  document.getElementById("profile-badge-list").append(card);
+  markProfileUnsaved();
+}
+
+// Hidden awards are remembered in this browser only
+function hideProfileBadge(button) {
+  const card = button.closest(".badge-card");
+  const hidden = JSON.parse(localStorage.getItem("hiddenBadges") || "[]");
+  hidden.push(card.dataset.e);
+  localStorage.setItem("hiddenBadges", JSON.stringify(hidden));
+  card.remove();
+}
+
+function applyHiddenBadges() {
+  const hidden = new Set(JSON.parse(localStorage.getItem("hiddenBadges") || "[]"));
+  document
+    .querySelectorAll("#available-badge-list .badge-card")
+    .forEach((card) => {
+      if (hidden.has(card.dataset.e)) {
+        card.remove();
+      }
+    });
+}
+
+async function saveProfileBadges() {
+  const results = document.getElementById("profile-publish-results");
+  const badges = Array.from(
+    document.querySelectorAll("#profile-badge-list .badge-card")
+  ).map((card) => ({
+    a: card.dataset.a,
+    e: card.dataset.e,
+    relay: card.dataset.relay,
+  }));
+
+  try {
+    // Step 1: Fetch the unsigned profile badges event from the backend
+    const response = await fetch("/profile-badges-event", {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+      },
+      body: JSON.stringify(badges),
+    });
+    if (!response.ok) {
+      throw new Error(await response.text());
+    }
+    const unsignedEvent = await response.json();
+
+    // Step 2: Sign the event with the remote signer or the Nostr extension
+    const signedEvent = await signEvent(unsignedEvent);
+
+    // Step 3: Send the signed event to the backend for broadcasting
+    const result = await fetch("/profile-badges-signed", {
+      method: "POST",
+      headers: {
+        "Content-Type": "application/json",
+        Accept: "text/html",
+      },
+      body: JSON.stringify(signedEvent),
+    });
+    if (!result.ok) {
+      throw new Error(await result.text());
+    }
+    results.innerHTML = await result.text();
+    document.getElementById("profile-unsaved").classList.add("hidden");
+  } catch (err) {
+    console.error("Failed to save profile badges:", err);
+    results.textContent = `Failed to save profile badges: ${err.message}`;
+  }
+}
+
+document.addEventListener("htmx:afterSwap", function () {
+  if (document.getElementById("profile-badges")) {
+    applyHiddenBadges();
+    updateProfileControls();
+  }
+});
@@ -0,0 +1,23 @@
+// signEvent signs with the session's NIP-46 remote signer when the user logged in with one,
+// otherwise with the NIP-07 browser extension
+async function signEvent(unsignedEvent) {
+  const response = await fetch("/sign-event", {
+    method: "POST",
+    headers: {
+      "Content-Type": "application/json",
+    },
+    body: JSON.stringify(unsignedEvent),
+  });
+  if (response.ok) {
+    return await response.json();
+  }
+  if (response.status !== 409) {
+    throw new Error(await response.text());
+  }
+
+  // 409: no remote signer in this session
+  if (!window.nostr) {
+    throw new Error("Nostr extension not available.");
+  }
+  return await window.nostr.signEvent(unsignedEvent);
+}
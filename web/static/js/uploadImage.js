@@ -0,0 +1,91 @@
+// Uploads a badge image to Badger and fills the image and thumbnail fields with the hosted URLs.
+// The real dimensions and every generated thumbnail are kept on the inputs for badgeImageTags.
+document.getElementById("badge-image-file").onchange = async function (event) {
+  const file = event.target.files[0];
+  if (!file) {
+    return;
+  }
+
+  const status = document.getElementById("badge-image-status");
+  status.textContent = "Uploading image...";
+
+  const form = new FormData();
+  form.append("image", file);
+  try {
+    const response = await fetch("/upload-image", {
+      method: "POST",
+      body: form,
+    });
+    if (!response.ok) {
+      throw new Error(await response.text());
+    }
+    const uploaded = await response.json();
+
+    const imageInput = document.getElementById("badge-image");
+    imageInput.value = uploaded.image.url;
+    imageInput.dataset.dimensions = uploaded.image.dimensions;
+
+    const thumbInput = document.getElementById("badge-thumb");
+    const thumb =
+      uploaded.thumbs.find((t) => t.width <= 256 && t.height <= 256) ||
+      uploaded.thumbs[uploaded.thumbs.length - 1];
+    thumbInput.value = thumb.url;
+    thumbInput.dataset.thumbs = JSON.stringify(uploaded.thumbs);
+
+    status.textContent = `Uploaded ${uploaded.image.dimensions} image with ${uploaded.thumbs.length} thumbnails.`;
+  } catch (err) {
+    console.error("Image upload failed:", err);
+    status.textContent = `Image upload failed: ${err.message}`;
+  }
+};
+
+// Typing a URL by hand drops what was known about the uploaded image
+for (const id of ["badge-image", "badge-thumb"]) {
+  document.getElementById(id).addEventListener("input", function (event) {
+    delete event.target.dataset.dimensions;
+    delete event.target.dataset.thumbs;
+  });
+}
+
+// imageDimensions loads an image in the browser to read its real size, "" when it can't be loaded
+function imageDimensions(url) {
+  return new Promise((resolve) => {
+    const img = new Image();
+    const timeout = setTimeout(() => resolve(""), 5000);
+    img.onload = () => {
+      clearTimeout(timeout);
+      resolve(`${img.naturalWidth}x${img.naturalHeight}`);
+    };
+    img.onerror = () => {
+      clearTimeout(timeout);
+      resolve("");
+    };
+    img.src = url;
+  });
+}
+
+// badgeImageTags builds the NIP-58 image and thumb tags with the images' real dimensions
+async function badgeImageTags() {
+  const imageInput = document.getElementById("badge-image");
+  const thumbInput = document.getElementById("badge-thumb");
+  const tags = [];
+
+  const imageDims =
+    imageInput.dataset.dimensions || (await imageDimensions(imageInput.value));
+  tags.push(
+    imageDims ? ["image", imageInput.value, imageDims] : ["image", imageInput.value]
+  );
+
+  if (thumbInput.dataset.thumbs) {
+    // Every uploaded thumbnail size, so clients can pick the one they need
+    for (const thumb of JSON.parse(thumbInput.dataset.thumbs)) {
+      tags.push(["thumb", thumb.url, thumb.dimensions]);
+    }
+  } else if (thumbInput.value) {
+    const thumbDims = await imageDimensions(thumbInput.value);
+    tags.push(
+      thumbDims ? ["thumb", thumbInput.value, thumbDims] : ["thumb", thumbInput.value]
+    );
+  }
+  return tags;
+}